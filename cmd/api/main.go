@@ -4,6 +4,7 @@ import (
 	"context"
 	"log"
 	"os"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -17,7 +18,15 @@ import (
 	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/middleware"
 	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/models"
 	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/realtime"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/clearance"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/commission"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/gateway"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/invoice"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/ledger"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/reconciliation"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/refund"
 	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/tripay"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/voucher"
 	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/wallet"
 )
 
@@ -48,6 +57,29 @@ func main() {
 		&models.JobOffer{},
 		&models.Transaction{},
 		&models.WalletTransaction{},
+		&models.Withdrawal{},
+		&models.PayoutAccount{},
+		&models.PayoutAccountView{},
+		&models.LedgerAccount{},
+		&models.JournalEntry{},
+		&models.JournalLine{},
+		&models.ReconciliationReport{},
+		&models.RefundRequest{},
+		&models.PaymentCallback{},
+		&models.CommissionRule{},
+		&models.Invoice{},
+		&models.InvoiceSequence{},
+		&models.Voucher{},
+		&models.VoucherRedemption{},
+		&models.EarningClearance{},
+		&models.Revision{},
+		&models.RevisionPurchase{},
+		&models.Milestone{},
+		&models.Dispute{},
+		&models.DisputeEvidence{},
+		&models.DeadlineExtension{},
+		&models.Delivery{},
+		&models.CounterOffer{},
 		&models.Review{}); err != nil {
 		log.Fatal(err)
 	}
@@ -71,8 +103,15 @@ func main() {
 	app.Static("/uploads", "./uploads")
 
 	// Services
-	tripayService := tripay.NewTripayService()
+	paymentGateway, simulator := newPaymentGateway(cfg)
 	walletService := wallet.NewWalletService(gdb)
+	ledgerService := ledger.NewLedgerService(gdb)
+	refundService := refund.NewRefundService(gdb, walletService, ledgerService)
+	commissionService := commission.NewCommissionService(gdb, cfg.DefaultCommissionBps)
+	invoiceService := invoice.NewInvoiceService(gdb)
+	voucherService := voucher.NewVoucherService(gdb)
+	reconciliationService := reconciliation.NewReconciliationService(gdb, time.Duration(cfg.ReconcileStuckDays)*24*time.Hour)
+	reconciliationService.StartNightlyWorker(cfg.ReconcileHour)
 
 	// Handlers
 	authH := &handlers.AuthHandler{
@@ -84,12 +123,26 @@ func main() {
 	// freelancerH not available/used, skipping
 	productH := handlers.NewProductHandler(gdb)
 	categoryH := handlers.NewCategoryHandler(gdb)
-	offerH := handlers.NewJobOfferHandler(gdb, hub, rdb, walletService)
+	clearanceService := clearance.NewClearanceService(gdb, walletService, ledgerService, time.Duration(cfg.EarningClearanceDays)*24*time.Hour)
+	clearanceService.StartWorker(1 * time.Hour)
+	offerH := handlers.NewJobOfferHandler(gdb, hub, rdb, walletService, ledgerService, refundService, commissionService, clearanceService, voucherService, time.Duration(cfg.OfferValidityDays)*24*time.Hour)
 	offerH.StartAutoCompletionWorker()
-	paymentH := handlers.NewPaymentHandler(gdb, tripayService, hub, walletService)
+	offerH.StartDeadlineWorker(15 * time.Minute)
+	offerH.StartOfferExpiryWorker(15 * time.Minute)
+	channelCache := gateway.NewChannelCache(paymentGateway, rdb, time.Duration(cfg.ChannelCacheMinutes)*time.Minute)
+	paymentH := handlers.NewPaymentHandler(gdb, channelCache, hub, walletService, ledgerService, invoiceService, voucherService, clearanceService)
+	productOrderH := handlers.NewProductOrderHandler(gdb, offerH, paymentH)
+	paymentH.StartStatusPollingWorker(time.Duration(cfg.PaymentPollMinutes) * time.Minute)
 
 	// Public Callbacks (Root level to avoid middleware issues)
 	app.Post("/tripay/callback", paymentH.HandleCallback)
+	app.Post("/payment/callback", paymentH.HandleCallback)
+
+	if simulator != nil {
+		simulatorH := handlers.NewPaymentSimulatorHandler(simulator)
+		app.Get("/simulator/checkout/:reference", simulatorH.Checkout)
+		app.Post("/simulator/checkout/:reference", simulatorH.Complete)
+	}
 
 	fOnboard := handlers.NewFreelancerOnboardingHandler(
 		gdb,
@@ -110,6 +163,16 @@ func main() {
 	}
 
 	dashboardH := handlers.NewFreelancerDashboardHandler(gdb)
+	withdrawalH := handlers.NewWithdrawalHandler(gdb, walletService, ledgerService, cfg.MinWithdrawalAmount, cfg.PayoutEncryptKey)
+	ledgerH := handlers.NewLedgerHandler(gdb)
+	commissionH := handlers.NewCommissionRuleHandler(gdb, commissionService)
+	invoiceH := handlers.NewInvoiceHandler(gdb, invoiceService)
+	voucherH := handlers.NewVoucherHandler(gdb)
+	payoutAccountH := handlers.NewPayoutAccountHandler(gdb, cfg.PayoutEncryptKey)
+	clientWalletH := handlers.NewClientWalletHandler(gdb)
+	topUpH := handlers.NewTopUpHandler(gdb, channelCache, cfg.MinTopUpAmount, cfg.MaxTopUpAmount)
+	reconciliationH := handlers.NewReconciliationHandler(gdb, reconciliationService)
+	refundH := handlers.NewRefundHandler(gdb, paymentGateway, refundService, cfg.PayoutEncryptKey, cfg.RefundTransferFee)
 
 	api := app.Group("/api")
 
@@ -196,6 +259,11 @@ func main() {
 		middleware.RequireRoles("client"),
 		func(c *fiber.Ctx) error { return c.JSON(fiber.Map{"msg": "client orders"}) },
 	)
+	protected.Get("/client/wallet", middleware.RequireRoles("client", "freelancer"), clientWalletH.GetWallet) // freelancers keep the client balance they had before onboarding
+	protected.Get("/client/topups", middleware.RequireRoles("client", "freelancer"), topUpH.ListMyTopUps)
+	protected.Post("/client/topups", middleware.RequireRoles("client", "freelancer"), topUpH.CreateTopUp)
+	protected.Get("/client/refunds", middleware.RequireRoles("client", "freelancer"), refundH.ListMyRefunds)
+	protected.Post("/client/refunds/:id/submit", middleware.RequireRoles("client", "freelancer"), refundH.SubmitRefund)
 
 	// freelancer only
 	protected.Get("/freelancer/jobs",
@@ -220,6 +288,23 @@ func main() {
 	protected.Put("/freelancer/profile", middleware.RequireRoles("freelancer"), dashboardH.UpdateSettings)
 	protected.Put("/freelancer/profile/photo", middleware.RequireRoles("freelancer"), dashboardH.UpdatePhoto)
 
+	// Freelancer-funded vouchers
+	protected.Get("/freelancer/vouchers", middleware.RequireRoles("freelancer"), voucherH.ListMyVouchers)
+	protected.Post("/freelancer/vouchers", middleware.RequireRoles("freelancer"), voucherH.CreateMyVoucher)
+	protected.Put("/freelancer/vouchers/:id", middleware.RequireRoles("freelancer"), voucherH.UpdateMyVoucher)
+
+	// Freelancer Payout Accounts
+	protected.Get("/freelancer/payout-accounts", middleware.RequireRoles("freelancer"), payoutAccountH.ListPayoutAccounts)
+	protected.Post("/freelancer/payout-accounts", middleware.RequireRoles("freelancer"), payoutAccountH.CreatePayoutAccount)
+	protected.Put("/freelancer/payout-accounts/:id", middleware.RequireRoles("freelancer"), payoutAccountH.UpdatePayoutAccount)
+	protected.Patch("/freelancer/payout-accounts/:id/primary", middleware.RequireRoles("freelancer"), payoutAccountH.SetPrimaryPayoutAccount)
+	protected.Delete("/freelancer/payout-accounts/:id", middleware.RequireRoles("freelancer"), payoutAccountH.DeletePayoutAccount)
+
+	// Freelancer Withdrawals
+	protected.Post("/freelancer/withdrawals", middleware.RequireRoles("freelancer"), withdrawalH.CreateWithdrawal)
+	protected.Get("/freelancer/withdrawals", middleware.RequireRoles("freelancer"), withdrawalH.ListMyWithdrawals)
+	protected.Post("/freelancer/withdrawals/:id/cancel", middleware.RequireRoles("freelancer"), withdrawalH.CancelWithdrawal)
+
 	chat := protected.Group("/chat")
 
 	// Job Offer Handler (using offerH from above)
@@ -240,13 +325,44 @@ func main() {
 	protected.Patch("/job-offers/:id/status", offerH.UpdateStatus)
 	protected.Put("/job-offers/:id", offerH.UpdateOffer)          // Update offer
 	protected.Post("/job-offers/:id/deliver", offerH.DeliverWork) // Deliver work
+	protected.Get("/job-offers/:id/deliveries", offerH.ListDeliveries)
+	protected.Get("/job-offers/:id/deliveries/:version", offerH.GetDelivery)
+	protected.Post("/job-offers/:id/revision", offerH.RequestRevision)
+	protected.Get("/job-offers/:id/revisions", offerH.ListRevisions)
+	protected.Post("/job-offers/:id/extra-revisions", offerH.OfferExtraRevisions)
+	protected.Post("/job-offers/:id/extra-revisions/:purchaseId/accept", offerH.AcceptExtraRevisions)
+	protected.Post("/job-offers/:id/extra-revisions/:purchaseId/decline", offerH.DeclineExtraRevisions)
+	protected.Get("/job-offers/:id/milestones", offerH.ListMilestones)
+	protected.Post("/job-offers/:id/milestones/:milestoneId/fund", offerH.FundMilestone)
+	protected.Post("/job-offers/:id/milestones/:milestoneId/deliver", offerH.DeliverMilestone)
+	protected.Post("/job-offers/:id/milestones/:milestoneId/revision", offerH.RequestMilestoneRevision)
+	protected.Post("/job-offers/:id/milestones/:milestoneId/complete", offerH.CompleteMilestone)
+	protected.Post("/job-offers/:id/dispute", offerH.OpenDispute)
+	protected.Get("/job-offers/:id/disputes", offerH.ListOfferDisputes)
+	protected.Post("/job-offers/:id/dispute/evidence", offerH.SubmitDisputeEvidence)
+	protected.Post("/job-offers/:id/dispute/withdraw", offerH.WithdrawDispute)
 	protected.Post("/job-offers/:id/complete", offerH.CompleteOrder)
 	protected.Post("/job-offers/:id/cancel", offerH.CancelOrder)
+	protected.Post("/job-offers/:id/withdraw", offerH.WithdrawOffer)
+	protected.Post("/job-offers/:id/decline", offerH.DeclineOffer)
+	protected.Get("/job-offers/:id/counter-offers", offerH.ListCounterOffers)
+	protected.Post("/job-offers/:id/counter-offers", offerH.CreateCounterOffer)
+	protected.Post("/job-offers/:id/counter-offers/:counterId/accept", offerH.AcceptCounterOffer)
+	protected.Post("/job-offers/:id/counter-offers/:counterId/reject", offerH.RejectCounterOffer)
+	protected.Post("/job-offers/:id/cancel-overdue", offerH.CancelOverdueOrder)
+	protected.Get("/job-offers/:id/extensions", offerH.ListExtensions)
+	protected.Post("/job-offers/:id/extensions", offerH.RequestExtension)
+	protected.Post("/job-offers/:id/extensions/:extensionId/accept", offerH.AcceptExtension)
+	protected.Post("/job-offers/:id/extensions/:extensionId/reject", offerH.RejectExtension)
 	protected.Post("/job-offers/:id/review", offerH.SubmitReview)
+	protected.Get("/orders/:id/invoice", invoiceH.GetInvoice)
+	protected.Get("/orders/:id/receipt", invoiceH.GetEarningsReceipt)
 
 	// Payments
 	protected.Get("/payments/channels", paymentH.GetChannels)
+	protected.Get("/payments/quote", paymentH.GetQuote)
 	protected.Post("/payments/create", paymentH.CreatePayment)
+	protected.Post("/products/:id/order", productOrderH.OrderPackage) // Direct checkout of a product package
 
 	// Public Callbacks
 	api.Post("/payments/callback", paymentH.HandleCallback) // WebSocket endpoint (tanpa JWT middleware, autentikasi via query param)
@@ -279,6 +395,58 @@ func main() {
 		func(c *fiber.Ctx) error { return c.JSON(fiber.Map{"msg": "admin users"}) },
 	)
 
+	admin := protected.Group("/admin", middleware.RequireRoles("admin"))
+	admin.Get("/withdrawals", withdrawalH.AdminListWithdrawals)
+	admin.Get("/withdrawals/:id/account", withdrawalH.AdminGetWithdrawalAccount)
+	admin.Post("/withdrawals/:id/approve", withdrawalH.ApproveWithdrawal)
+	admin.Post("/withdrawals/:id/reject", withdrawalH.RejectWithdrawal)
+	admin.Post("/withdrawals/:id/transferred", withdrawalH.MarkWithdrawalTransferred)
+	admin.Post("/withdrawals/:id/failed", withdrawalH.MarkWithdrawalFailed)
+
+	// Payments
+	admin.Post("/payments/:reference/recheck", paymentH.RecheckTransaction)
+	admin.Get("/payment-callbacks", paymentH.ListCallbacks)
+	admin.Get("/payment-callbacks/:id", paymentH.GetCallback)
+	admin.Post("/payment-callbacks/:id/replay", paymentH.ReplayCallback)
+
+	// Refunds
+	admin.Get("/refunds", refundH.AdminListRefunds)
+	admin.Post("/refunds", refundH.CreatePartialRefund)
+	admin.Get("/refunds/:id/account", refundH.AdminGetRefundAccount)
+	admin.Post("/refunds/:id/approve", refundH.ApproveRefund)
+	admin.Post("/refunds/:id/reject", refundH.RejectRefund)
+	admin.Post("/refunds/:id/completed", refundH.MarkRefundCompleted)
+	admin.Post("/refunds/:id/failed", refundH.MarkRefundFailed)
+
+	// Disputes
+	admin.Get("/disputes", offerH.AdminListDisputes)
+	admin.Get("/disputes/:id", offerH.AdminGetDispute)
+	admin.Post("/disputes/:id/resolve", offerH.ResolveDispute)
+
+	// Commission rules
+	admin.Get("/commission-rules", commissionH.ListRules)
+	admin.Get("/commission-rules/preview", commissionH.PreviewRule)
+	admin.Post("/commission-rules", commissionH.CreateRule)
+	admin.Put("/commission-rules/:id", commissionH.UpdateRule)
+	admin.Delete("/commission-rules/:id", commissionH.DeleteRule)
+	admin.Patch("/freelancers/:userId/level", commissionH.SetFreelancerLevel)
+
+	// Vouchers
+	admin.Get("/vouchers", voucherH.AdminListVouchers)
+	admin.Post("/vouchers", voucherH.AdminCreateVoucher)
+	admin.Put("/vouchers/:id", voucherH.AdminUpdateVoucher)
+
+	// Ledger (finance)
+	admin.Get("/ledger/accounts", ledgerH.ListAccounts)
+	admin.Get("/ledger/accounts/:code/lines", ledgerH.GetAccountLines)
+	admin.Get("/ledger/entries", ledgerH.ListEntries)
+	admin.Get("/ledger/trial-balance", ledgerH.GetTrialBalance)
+
+	// Reconciliation (finance)
+	admin.Get("/reconciliation/reports", reconciliationH.ListReports)
+	admin.Get("/reconciliation/reports/:id", reconciliationH.GetReport)
+	admin.Post("/reconciliation/run", reconciliationH.RunNow)
+
 	onb := protected.Group("/freelancer/onboarding", middleware.RequireRoles("client"))
 
 	onb.Get("/", fOnboard.Get)
@@ -295,3 +463,27 @@ func main() {
 	}
 	log.Fatal(app.Listen(":" + port))
 }
+
+// newPaymentGateway picks the payment gateway from PAYMENT_GATEWAY.
+// The simulator is also returned separately so its checkout routes can be mounted.
+func newPaymentGateway(cfg config.Config) (gateway.PaymentGateway, *gateway.Simulator) {
+	switch cfg.PaymentGateway {
+	case "simulator":
+		baseURL := cfg.AppBaseURL
+		if baseURL == "" {
+			baseURL = "http://localhost:" + cfg.AppPort
+		}
+		sim := gateway.NewSimulator(baseURL, baseURL+"/payment/callback", cfg.SimulatorSecret)
+		log.Println("Payment gateway: SIMULATOR (offline) ⚠️")
+		return sim, sim
+	case "tripay":
+		callbackURL := ""
+		if cfg.AppBaseURL != "" {
+			callbackURL = cfg.AppBaseURL + "/tripay/callback"
+		}
+		return tripay.NewTripayService(callbackURL), nil
+	default:
+		log.Fatalf("unknown PAYMENT_GATEWAY: %s", cfg.PaymentGateway)
+		return nil, nil
+	}
+}
@@ -0,0 +1,100 @@
+// Command cli runs maintenance jobs against the platform database.
+//
+//	go run ./cmd/cli reconcile
+//	go run ./cmd/cli replay-callback <callback-id>
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joho/godotenv"
+
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/config"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/db"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/handlers"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/models"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/realtime"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/clearance"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/invoice"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/ledger"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/reconciliation"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/voucher"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/wallet"
+)
+
+const usage = `usage: cli <command>
+
+commands:
+  reconcile                 run wallet/escrow reconciliation now and store the report
+  replay-callback <id>      re-process a journaled payment callback (idempotent)`
+
+func main() {
+	_ = godotenv.Load()
+
+	if len(os.Args) < 2 {
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+
+	cfg := config.Load()
+	gdb, err := db.Connect(cfg.DBDSN)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch os.Args[1] {
+	case "reconcile":
+		if err := gdb.AutoMigrate(&models.ReconciliationReport{}); err != nil {
+			log.Fatal(err)
+		}
+
+		svc := reconciliation.NewReconciliationService(gdb, time.Duration(cfg.ReconcileStuckDays)*24*time.Hour)
+		report, err := svc.Run("cli")
+		if report == nil {
+			log.Fatal(err)
+		}
+
+		fmt.Printf("report %s: status=%s issues=%d\n", report.ID, report.Status, report.IssueCount)
+		for _, issue := range reconciliation.DecodeIssues(report.Issues) {
+			fmt.Printf("  [%s] %s expected=%d actual=%d diff=%d - %s\n",
+				issue.Check, issue.EntityID, issue.Expected, issue.Actual, issue.Diff, issue.Detail)
+		}
+
+		if err != nil {
+			log.Fatal(err)
+		}
+		if report.Status != models.ReconciliationClean {
+			os.Exit(1)
+		}
+	case "replay-callback":
+		if len(os.Args) < 3 {
+			fmt.Println(usage)
+			os.Exit(2)
+		}
+		id, err := uuid.Parse(os.Args[2])
+		if err != nil {
+			log.Fatalf("invalid callback id: %v", err)
+		}
+
+		// Replays never talk to the gateway; websocket clients are not connected to this
+		// process, so only the persisted system messages are visible to users.
+		walletService, ledgerService := wallet.NewWalletService(gdb), ledger.NewLedgerService(gdb)
+		clearanceService := clearance.NewClearanceService(gdb, walletService, ledgerService, time.Duration(cfg.EarningClearanceDays)*24*time.Hour)
+		paymentH := handlers.NewPaymentHandler(gdb, nil, realtime.NewHub(), walletService, ledgerService, invoice.NewInvoiceService(gdb), voucher.NewVoucherService(gdb), clearanceService)
+		replay, err := paymentH.ReplayStoredCallback(id)
+		if replay != nil {
+			fmt.Printf("replay %s of %s: reference=%s status=%s outcome=%s event=%s\n",
+				replay.ID, id, replay.Reference, replay.Status, replay.Outcome, replay.Event)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	default:
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+}
@@ -1,8 +1,11 @@
 package config
 
 import (
+	"log"
 	"os"
 	"strconv"
+
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/commission"
 )
 
 type Config struct {
@@ -14,10 +17,44 @@ type Config struct {
 	GoogleSecret    string
 	GoogleRedirect  string
 	FrontendBaseURL string
+	AppBaseURL      string // Public URL of this API (used for payment callbacks)
+
+	PaymentGateway  string // "tripay" (default) or "simulator"
+	SimulatorSecret string
+
+	PaymentPollMinutes  int // Interval of the UNPAID transaction status poller
+	ChannelCacheMinutes int // TTL of the cached payment channel list
+
+	MinTopUpAmount int64
+	MaxTopUpAmount int64
+
+	EarningClearanceDays int // Days completed-order earnings stay pending before they can be withdrawn
+
+	MinWithdrawalAmount int64
+	PayoutEncryptKey    string
+	RefundTransferFee   int64 // Deducted from refunds paid to a bank account / e-wallet
+
+	DefaultCommissionBps int // Platform fee when no commission rule matches (1000 = 10%)
+
+	OfferValidityDays int // Days a client has to pay an offer when the freelancer sets no validity
+
+	ReconcileHour      int // Local hour (0-23) of the nightly reconciliation run
+	ReconcileStuckDays int // Days an order may stay "paid" before it is reported as stuck
 }
 
 func Load() Config {
 	expires, _ := strconv.Atoi(get("JWT_EXPIRES_MIN", "10080"))
+	minTopUp, _ := strconv.ParseInt(get("MIN_TOPUP_AMOUNT", "10000"), 10, 64)
+	maxTopUp, _ := strconv.ParseInt(get("MAX_TOPUP_AMOUNT", "10000000"), 10, 64)
+	clearanceDays, _ := strconv.Atoi(get("EARNING_CLEARANCE_DAYS", "7"))
+	minWithdrawal, _ := strconv.ParseInt(get("MIN_WITHDRAWAL_AMOUNT", "50000"), 10, 64)
+	refundTransferFee, _ := strconv.ParseInt(get("REFUND_TRANSFER_FEE", "2500"), 10, 64)
+	pollMinutes := intInRange("PAYMENT_POLL_MINUTES", 5, 1, 24*60) // time.NewTicker panics on 0
+	channelCacheMinutes, _ := strconv.Atoi(get("PAYMENT_CHANNEL_CACHE_MINUTES", "60"))
+	reconcileHour, _ := strconv.Atoi(get("RECONCILE_HOUR", "2"))
+	stuckDays, _ := strconv.Atoi(get("RECONCILE_STUCK_DAYS", "3"))
+	commissionBps := intInRange("DEFAULT_COMMISSION_BPS", commission.DefaultRateBps, 0, 10000)
+	offerValidityDays, _ := strconv.Atoi(get("OFFER_VALIDITY_DAYS", "3"))
 	return Config{
 		AppPort:         get("APP_PORT", "8080"),
 		DBDSN:           must("DB_DSN"),
@@ -27,6 +64,29 @@ func Load() Config {
 		GoogleSecret:    get("GOOGLE_CLIENT_SECRET", ""),
 		GoogleRedirect:  get("GOOGLE_REDIRECT_URL", ""),
 		FrontendBaseURL: get("FRONTEND_BASE_URL", "http://localhost:3000"),
+		AppBaseURL:      get("APP_BASE_URL", ""),
+
+		PaymentGateway:  get("PAYMENT_GATEWAY", "tripay"),
+		SimulatorSecret: get("SIMULATOR_SECRET", "simulator-secret"),
+
+		PaymentPollMinutes:  pollMinutes,
+		ChannelCacheMinutes: channelCacheMinutes,
+
+		MinTopUpAmount: minTopUp,
+		MaxTopUpAmount: maxTopUp,
+
+		EarningClearanceDays: clearanceDays,
+
+		MinWithdrawalAmount: minWithdrawal,
+		PayoutEncryptKey:    mustAESKey("PAYOUT_ENCRYPT_KEY"),
+		RefundTransferFee:   refundTransferFee,
+
+		DefaultCommissionBps: commissionBps,
+
+		OfferValidityDays: offerValidityDays,
+
+		ReconcileHour:      reconcileHour,
+		ReconcileStuckDays: stuckDays,
 	}
 }
 
@@ -44,3 +104,27 @@ func must(k string) string {
 	}
 	return v
 }
+
+// intInRange reads an integer setting, falling back to def with a warning when it is not a
+// number or lies outside lo..hi
+func intInRange(k string, def, lo, hi int) int {
+	v := os.Getenv(k)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < lo || n > hi {
+		log.Printf("Warning: invalid %s %q (expected %d..%d), using %d", k, v, lo, hi, def)
+		return def
+	}
+	return n
+}
+
+// mustAESKey reads a required AES key: 16, 24 or 32 bytes (AES-128/192/256)
+func mustAESKey(k string) string {
+	v := must(k)
+	if n := len(v); n != 16 && n != 24 && n != 32 {
+		panic(k + " must be 16, 24 or 32 bytes long, got " + strconv.Itoa(n))
+	}
+	return v
+}
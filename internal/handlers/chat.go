@@ -322,15 +322,16 @@ func (h *ChatHandler) GetUnreadTotal(c *fiber.Ctx) error {
 
 // MessageResponse DTO untuk response message
 type MessageResponse struct {
-	ID             string    `json:"id"`
-	ConversationID string    `json:"conversation_id"`
-	SenderID       string    `json:"sender_id"`
-	Type           string    `json:"type"`
-	Text           string    `json:"text"`
-	FileUrl        string    `json:"file_url,omitempty"`
-	FileName       string    `json:"file_name,omitempty"`
-	IsRead         bool      `json:"is_read"`
-	CreatedAt      time.Time `json:"created_at"`
+	ID             string     `json:"id"`
+	ConversationID string     `json:"conversation_id"`
+	SenderID       string     `json:"sender_id"`
+	Type           string     `json:"type"`
+	Text           string     `json:"text"`
+	FileUrl        string     `json:"file_url,omitempty"`
+	FileName       string     `json:"file_name,omitempty"`
+	DeliveryID     *uuid.UUID `json:"delivery_id,omitempty"` // Set on "delivery" messages
+	IsRead         bool       `json:"is_read"`
+	CreatedAt      time.Time  `json:"created_at"`
 }
 
 // GetMessages returns messages for a conversation
@@ -422,6 +423,7 @@ func (h *ChatHandler) GetMessages(c *fiber.Ctx) error {
 			Text:           msg.Text,
 			FileUrl:        msg.FileUrl,
 			FileName:       msg.FileName,
+			DeliveryID:     msg.DeliveryID,
 			IsRead:         msg.IsRead,
 			CreatedAt:      msg.CreatedAt,
 		})
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"math"
+
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type ClientWalletHandler struct {
+	DB *gorm.DB
+}
+
+func NewClientWalletHandler(db *gorm.DB) *ClientWalletHandler {
+	return &ClientWalletHandler{DB: db}
+}
+
+// GetWallet returns the client's balance and paginated wallet history
+func (h *ClientWalletHandler) GetWallet(c *fiber.Ctx) error {
+	userID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var user models.User
+	if err := h.DB.Select("id", "balance").First(&user, "id = ?", userID).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "message": "User not found"})
+	}
+
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 20)
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 20
+	}
+	offset := (page - 1) * limit
+
+	q := h.DB.Model(&models.WalletTransaction{}).
+		Where("user_id = ? AND wallet = ?", userID, models.WalletClient)
+
+	if trxType := c.Query("type"); trxType != "" {
+		q = q.Where("type = ?", trxType)
+	}
+
+	var total int64
+	q.Count(&total)
+
+	var history []models.WalletTransaction
+	if err := q.Order("created_at DESC").Limit(limit).Offset(offset).Find(&history).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to fetch wallet history"})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"balance": user.Balance,
+			"history": history,
+		},
+		"meta": fiber.Map{
+			"page":        page,
+			"limit":       limit,
+			"total_items": total,
+			"total_pages": int(math.Ceil(float64(total) / float64(limit))),
+		},
+	})
+}
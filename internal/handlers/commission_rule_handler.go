@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/models"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/commission"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CommissionRuleHandler lets admins manage the platform fee rules
+type CommissionRuleHandler struct {
+	DB         *gorm.DB
+	Commission *commission.CommissionService
+}
+
+func NewCommissionRuleHandler(db *gorm.DB, commissionService *commission.CommissionService) *CommissionRuleHandler {
+	return &CommissionRuleHandler{DB: db, Commission: commissionService}
+}
+
+type CommissionRuleRequest struct {
+	Name            string `json:"name"`
+	Priority        int    `json:"priority"`
+	IsActive        *bool  `json:"is_active"`
+	Category        string `json:"category"`
+	FreelancerLevel string `json:"freelancer_level"`
+	FreelancerID    string `json:"freelancer_id"`
+	MinPrice        int64  `json:"min_price"`
+	MaxPrice        int64  `json:"max_price"`
+	StartsAt        string `json:"starts_at"` // RFC3339, optional
+	EndsAt          string `json:"ends_at"`   // RFC3339, optional
+	RateBps         int    `json:"rate_bps"`  // 1000 = 10%
+	FlatFee         int64  `json:"flat_fee"`
+}
+
+type SetFreelancerLevelRequest struct {
+	Level string `json:"level"`
+}
+
+// toRule validates the request and copies it onto rule
+func (req *CommissionRuleRequest) toRule(rule *models.CommissionRule) error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return fiber.NewError(400, "Name is required")
+	}
+	if req.RateBps < 0 || req.RateBps > 10000 {
+		return fiber.NewError(400, "rate_bps must be between 0 and 10000")
+	}
+	if req.FlatFee < 0 || req.MinPrice < 0 || req.MaxPrice < 0 {
+		return fiber.NewError(400, "Amounts cannot be negative")
+	}
+	if req.MaxPrice > 0 && req.MaxPrice < req.MinPrice {
+		return fiber.NewError(400, "max_price must be greater than min_price")
+	}
+	level := models.FreelancerLevel(req.FreelancerLevel)
+	if level != "" && !models.IsValidFreelancerLevel(level) {
+		return fiber.NewError(400, "Invalid freelancer level")
+	}
+
+	var freelancerID *uuid.UUID
+	if req.FreelancerID != "" {
+		id, err := uuid.Parse(req.FreelancerID)
+		if err != nil {
+			return fiber.NewError(400, "Invalid freelancer ID")
+		}
+		freelancerID = &id
+	}
+
+	var startsAt, endsAt *time.Time
+	if req.StartsAt != "" {
+		t, err := time.Parse(time.RFC3339, req.StartsAt)
+		if err != nil {
+			return fiber.NewError(400, "starts_at must be RFC3339")
+		}
+		startsAt = &t
+	}
+	if req.EndsAt != "" {
+		t, err := time.Parse(time.RFC3339, req.EndsAt)
+		if err != nil {
+			return fiber.NewError(400, "ends_at must be RFC3339")
+		}
+		endsAt = &t
+	}
+	if startsAt != nil && endsAt != nil && !endsAt.After(*startsAt) {
+		return fiber.NewError(400, "ends_at must be after starts_at")
+	}
+
+	rule.Name = req.Name
+	rule.Priority = req.Priority
+	if req.IsActive != nil {
+		rule.IsActive = *req.IsActive
+	}
+	rule.Category = strings.TrimSpace(req.Category)
+	rule.FreelancerLevel = level
+	rule.FreelancerID = freelancerID
+	rule.MinPrice = req.MinPrice
+	rule.MaxPrice = req.MaxPrice
+	rule.StartsAt = startsAt
+	rule.EndsAt = endsAt
+	rule.RateBps = req.RateBps
+	rule.FlatFee = req.FlatFee
+	return nil
+}
+
+// ListRules returns all commission rules, highest priority first
+func (h *CommissionRuleHandler) ListRules(c *fiber.Ctx) error {
+	q := h.DB.Model(&models.CommissionRule{})
+	if c.Query("active") == "true" {
+		q = q.Where("is_active")
+	}
+
+	var rules []models.CommissionRule
+	if err := q.Order("priority DESC, created_at DESC").Find(&rules).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to fetch commission rules"})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"rules":            rules,
+			"default_rate_bps": h.Commission.DefaultRateBps,
+		},
+	})
+}
+
+// CreateRule adds a commission rule
+func (h *CommissionRuleHandler) CreateRule(c *fiber.Ctx) error {
+	adminID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var req CommissionRuleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid request body"})
+	}
+
+	rule := models.CommissionRule{ID: uuid.New(), IsActive: true, CreatedBy: &adminID}
+	if err := req.toRule(&rule); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": err.Error()})
+	}
+
+	if err := h.DB.Create(&rule).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to create commission rule"})
+	}
+
+	return c.Status(201).JSON(fiber.Map{"success": true, "message": "Commission rule created", "data": rule})
+}
+
+// UpdateRule replaces a commission rule. Offers created earlier keep their snapshot.
+func (h *CommissionRuleHandler) UpdateRule(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid rule ID"})
+	}
+
+	var req CommissionRuleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid request body"})
+	}
+
+	var rule models.CommissionRule
+	if err := h.DB.First(&rule, "id = ?", id).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "message": "Commission rule not found"})
+	}
+	if err := req.toRule(&rule); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": err.Error()})
+	}
+
+	if err := h.DB.Save(&rule).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to update commission rule"})
+	}
+
+	return c.JSON(fiber.Map{"success": true, "message": "Commission rule updated", "data": rule})
+}
+
+// DeleteRule removes a commission rule. Offers keep the snapshotted rate and fee.
+func (h *CommissionRuleHandler) DeleteRule(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid rule ID"})
+	}
+
+	res := h.DB.Delete(&models.CommissionRule{}, "id = ?", id)
+	if res.Error != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to delete commission rule"})
+	}
+	if res.RowsAffected == 0 {
+		return c.Status(404).JSON(fiber.Map{"success": false, "message": "Commission rule not found"})
+	}
+
+	return c.JSON(fiber.Map{"success": true, "message": "Commission rule deleted"})
+}
+
+// PreviewRule shows which rule and fee apply to a freelancer/category/price combination
+func (h *CommissionRuleHandler) PreviewRule(c *fiber.Ctx) error {
+	price := int64(c.QueryInt("price", 0))
+	if price <= 0 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "price is required"})
+	}
+
+	cr := commission.Criteria{
+		Category:        c.Query("category"),
+		FreelancerLevel: models.FreelancerLevel(c.Query("level")),
+		Price:           price,
+		At:              time.Now(),
+	}
+	if freelancerID := c.Query("freelancer_id"); freelancerID != "" {
+		id, err := uuid.Parse(freelancerID)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid freelancer ID"})
+		}
+		loaded, err := h.Commission.CriteriaFor(id, nil, price)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to load freelancer"})
+		}
+		cr.FreelancerID = id
+		if cr.FreelancerLevel == "" {
+			cr.FreelancerLevel = loaded.FreelancerLevel
+		}
+	}
+
+	quote, err := h.Commission.Resolve(cr)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to resolve commission"})
+	}
+
+	return c.JSON(fiber.Map{"success": true, "data": quote})
+}
+
+// SetFreelancerLevel sets the seller level used by level-based commission rules
+func (h *CommissionRuleHandler) SetFreelancerLevel(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("userId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid user ID"})
+	}
+
+	var req SetFreelancerLevelRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid request body"})
+	}
+	level := models.FreelancerLevel(req.Level)
+	if !models.IsValidFreelancerLevel(level) {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid freelancer level"})
+	}
+
+	res := h.DB.Model(&models.FreelancerProfile{}).Where("user_id = ?", userID).Update("level", level)
+	if res.Error != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to update freelancer level"})
+	}
+	if res.RowsAffected == 0 {
+		return c.Status(404).JSON(fiber.Map{"success": false, "message": "Freelancer profile not found"})
+	}
+
+	return c.JSON(fiber.Map{"success": true, "message": "Freelancer level updated", "data": fiber.Map{"user_id": userID, "level": level}})
+}
@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/models"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// StartDeadlineWorker periodically flags paid and in-progress orders whose delivery date has
+// passed (end of the day, Asia/Jakarta)
+func (h *JobOfferHandler) StartDeadlineWorker(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			h.scanOverdueOrders()
+		}
+	}()
+}
+
+func (h *JobOfferHandler) scanOverdueOrders() {
+	now := time.Now()
+	var offers []models.JobOffer
+	err := h.DB.Where("status IN ? AND NOT is_overdue AND delivery_date < ?",
+		[]models.JobOfferStatus{models.OfferStatusPaid, models.OfferStatusWorking}, utils.StartOfDay(now)).
+		Find(&offers).Error
+	if err != nil {
+		log.Printf("[DeadlineWorker] Error fetching overdue orders: %v", err)
+		return
+	}
+
+	for _, o := range offers {
+		var offer models.JobOffer
+		flagged := false
+		err := h.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&offer, "id = ?", o.ID).Error; err != nil {
+				return err
+			}
+			// Idempotency: delivered, extended or flagged in the meantime
+			if offer.IsOverdue || !isOverdueCandidate(&offer, now) {
+				return nil
+			}
+			offer.IsOverdue = true
+			offer.OverdueAt = &now
+			flagged = true
+			return tx.Save(&offer).Error
+		})
+		if err != nil {
+			log.Printf("[DeadlineWorker] Failed to flag order %s: %v", o.OrderCode, err)
+			continue
+		}
+		if !flagged {
+			continue
+		}
+
+		log.Printf("[DeadlineWorker] Order %s is overdue (due %s)", offer.OrderCode, utils.FormatDate(offer.DeliveryDate))
+		text := "Pesanan #" + offer.OrderCode + " telah melewati batas waktu pengiriman (" + utils.FormatDate(offer.DeliveryDate) + " WIB).\n\nPembeli dapat membatalkan pesanan dengan pengembalian dana penuh, atau Freelancer dapat mengajukan perpanjangan waktu."
+		h.postOrderMessage(&offer, offer.FreelancerID, "system", text)
+		for _, userID := range []uuid.UUID{offer.ClientID, offer.FreelancerID} {
+			h.notify(userID, map[string]interface{}{
+				"type":          "order_overdue",
+				"offer_id":      offer.ID.String(),
+				"order_code":    offer.OrderCode,
+				"delivery_date": utils.FormatDate(offer.DeliveryDate),
+			})
+		}
+	}
+}
+
+// isOverdueCandidate tells whether an order still owes a delivery whose date has passed
+func isOverdueCandidate(offer *models.JobOffer, now time.Time) bool {
+	active := offer.Status == models.OfferStatusPaid || offer.Status == models.OfferStatusWorking
+	return active && !now.Before(utils.Deadline(offer.DeliveryDate))
+}
+
+// notify publishes a push notification for a user (see ChatHandler.SendMessage)
+func (h *JobOfferHandler) notify(userID uuid.UUID, notif map[string]interface{}) {
+	if h.RDB == nil {
+		return
+	}
+	payload, _ := json.Marshal(notif)
+	h.RDB.Publish(context.Background(), "notifications:"+userID.String(), payload)
+}
+
+// CancelOverdueOrder lets the client cancel an order flagged overdue. The whole escrow left,
+// payment fee included, is refunded to a destination the client chooses.
+func (h *JobOfferHandler) CancelOverdueOrder(c *fiber.Ctx) error {
+	userID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var offer models.JobOffer
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&offer, "id = ?", c.Params("id")).Error; err != nil {
+			return fiber.NewError(404, "Offer not found")
+		}
+		if offer.ClientID != userID {
+			return fiber.NewError(403, "Only the client can cancel an overdue order")
+		}
+		if !offer.IsOverdue || !isOverdueCandidate(&offer, time.Now()) {
+			return fiber.NewError(400, "Only overdue orders that were not delivered can be cancelled")
+		}
+
+		if err := offer.Transition(models.OfferStatusCancelled, models.OfferActorClient); err != nil {
+			return fiber.NewError(400, err.Error())
+		}
+		if remaining := offer.EscrowRemaining(); remaining > 0 {
+			reason := "Pembatalan pesanan #" + offer.OrderCode + " karena melewati batas waktu"
+			if _, err := h.Refunds.Open(tx, &offer, remaining, true, reason, &userID); err != nil {
+				return err
+			}
+		}
+		if err := tx.Save(&offer).Error; err != nil {
+			return err
+		}
+		if err := cancelOpenMilestones(tx, offer.ID); err != nil {
+			return err
+		}
+		return cancelPendingExtensions(tx, offer.ID)
+	})
+	if err != nil {
+		if e, ok := err.(*fiber.Error); ok {
+			return c.Status(e.Code).JSON(fiber.Map{"success": false, "message": e.Message})
+		}
+		log.Printf("Failed to cancel overdue order: %v", err)
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to cancel order"})
+	}
+
+	text := "Pesanan #" + offer.OrderCode + " dibatalkan oleh pembeli karena melewati batas waktu pengiriman. Dana akan dikembalikan sepenuhnya, silakan pilih tujuan pengembalian dana (saldo Jokiin, metode pembayaran asal, atau rekening bank)."
+	h.postOrderMessage(&offer, userID, "system", text)
+
+	return c.JSON(fiber.Map{"success": true, "data": toJobOfferResponse(&offer)})
+}
+
+type RequestExtensionRequest struct {
+	NewDate string `json:"new_date"` // ISO format: 2026-01-10 (Asia/Jakarta)
+	Reason  string `json:"reason"`
+}
+
+// RequestExtension lets the freelancer propose a later delivery date; the client answers it in chat
+func (h *JobOfferHandler) RequestExtension(c *fiber.Ctx) error {
+	userID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var req RequestExtensionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid request body"})
+	}
+	newDate, err := utils.ParseDate(req.NewDate)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "New date is required (YYYY-MM-DD)"})
+	}
+	if newDate.Before(utils.StartOfDay(time.Now())) {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "New date cannot be in the past"})
+	}
+
+	var offer models.JobOffer
+	var ext models.DeadlineExtension
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&offer, "id = ?", c.Params("id")).Error; err != nil {
+			return fiber.NewError(404, "Offer not found")
+		}
+		if offer.FreelancerID != userID {
+			return fiber.NewError(403, "Only the assigned freelancer can request an extension")
+		}
+		if offer.Status != models.OfferStatusPaid && offer.Status != models.OfferStatusWorking {
+			return fiber.NewError(400, "Extensions can only be requested on orders in progress")
+		}
+		if !newDate.After(utils.StartOfDay(offer.DeliveryDate)) {
+			return fiber.NewError(400, "New date must be after the current delivery date")
+		}
+
+		var pending int64
+		tx.Model(&models.DeadlineExtension{}).
+			Where("job_offer_id = ? AND status = ?", offer.ID, models.ExtensionPending).Count(&pending)
+		if pending > 0 {
+			return fiber.NewError(400, "An extension request is already waiting for the client")
+		}
+
+		ext = models.DeadlineExtension{
+			JobOfferID:   offer.ID,
+			RequestedBy:  userID,
+			CurrentDate:  offer.DeliveryDate,
+			ProposedDate: newDate,
+			Reason:       req.Reason,
+			Status:       models.ExtensionPending,
+		}
+		return tx.Create(&ext).Error
+	})
+	if err != nil {
+		if e, ok := err.(*fiber.Error); ok {
+			return c.Status(e.Code).JSON(fiber.Map{"success": false, "message": e.Message})
+		}
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to request extension"})
+	}
+
+	text := "Freelancer mengajukan perpanjangan batas waktu pesanan #" + offer.OrderCode + " dari " +
+		utils.FormatDate(ext.CurrentDate) + " menjadi " + utils.FormatDate(ext.ProposedDate) + " (WIB)."
+	if req.Reason != "" {
+		text += "\n\nAlasan: " + req.Reason
+	}
+	h.postOrderMessage(&offer, userID, "extension_request", text)
+	h.notify(offer.ClientID, map[string]interface{}{
+		"type":          "extension_request",
+		"offer_id":      offer.ID.String(),
+		"extension_id":  ext.ID.String(),
+		"proposed_date": utils.FormatDate(ext.ProposedDate),
+	})
+
+	return c.Status(201).JSON(fiber.Map{"success": true, "data": ext})
+}
+
+// ListExtensions returns the extension requests of an order, newest first
+func (h *JobOfferHandler) ListExtensions(c *fiber.Ctx) error {
+	userID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var offer models.JobOffer
+	if err := h.DB.First(&offer, "id = ?", c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "message": "Offer not found"})
+	}
+	if offer.ClientID != userID && offer.FreelancerID != userID && c.Locals("role") != string(models.RoleAdmin) {
+		return c.Status(403).JSON(fiber.Map{"success": false, "message": "Access denied"})
+	}
+
+	var extensions []models.DeadlineExtension
+	if err := h.DB.Where("job_offer_id = ?", offer.ID).Order("created_at DESC").Find(&extensions).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to fetch extension requests"})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": extensions})
+}
+
+// AcceptExtension moves the order's delivery date to the proposed one and clears the overdue flag
+func (h *JobOfferHandler) AcceptExtension(c *fiber.Ctx) error {
+	return h.answerExtension(c, true)
+}
+
+// RejectExtension keeps the current delivery date
+func (h *JobOfferHandler) RejectExtension(c *fiber.Ctx) error {
+	return h.answerExtension(c, false)
+}
+
+func (h *JobOfferHandler) answerExtension(c *fiber.Ctx, accept bool) error {
+	userID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var offer models.JobOffer
+	var ext models.DeadlineExtension
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&offer, "id = ?", c.Params("id")).Error; err != nil {
+			return fiber.NewError(404, "Offer not found")
+		}
+		if offer.ClientID != userID {
+			return fiber.NewError(403, "Only the client can answer extension requests")
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&ext, "id = ? AND job_offer_id = ?", c.Params("extensionId"), offer.ID).Error; err != nil {
+			return fiber.NewError(404, "Extension request not found")
+		}
+		if ext.Status != models.ExtensionPending {
+			return fiber.NewError(400, "Extension request was already answered")
+		}
+
+		now := time.Now()
+		ext.Status = models.ExtensionRejected
+		ext.RespondedAt = &now
+		if accept {
+			if offer.Status != models.OfferStatusPaid && offer.Status != models.OfferStatusWorking {
+				return fiber.NewError(400, "The order is no longer in progress")
+			}
+			ext.Status = models.ExtensionAccepted
+			offer.DeliveryDate = ext.ProposedDate
+			offer.IsOverdue = false
+			offer.OverdueAt = nil
+			if err := tx.Save(&offer).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Save(&ext).Error
+	})
+	if err != nil {
+		if e, ok := err.(*fiber.Error); ok {
+			return c.Status(e.Code).JSON(fiber.Map{"success": false, "message": e.Message})
+		}
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to answer extension request"})
+	}
+
+	text := "Pembeli menolak perpanjangan batas waktu. Batas waktu tetap " + utils.FormatDate(offer.DeliveryDate) + " (WIB)."
+	if accept {
+		text = "Pembeli menyetujui perpanjangan batas waktu. Batas waktu baru: " + utils.FormatDate(offer.DeliveryDate) + " (WIB)."
+	}
+	h.postOrderMessage(&offer, userID, "system", text)
+	h.notify(offer.FreelancerID, map[string]interface{}{
+		"type":         "extension_" + string(ext.Status),
+		"offer_id":     offer.ID.String(),
+		"extension_id": ext.ID.String(),
+	})
+
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"offer": toJobOfferResponse(&offer), "extension": ext}})
+}
+
+// cancelPendingExtensions closes the unanswered extension requests of an order that ended
+func cancelPendingExtensions(tx *gorm.DB, offerID uuid.UUID) error {
+	return tx.Model(&models.DeadlineExtension{}).
+		Where("job_offer_id = ? AND status = ?", offerID, models.ExtensionPending).
+		Update("status", models.ExtensionCancelled).Error
+}
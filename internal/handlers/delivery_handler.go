@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// recordDelivery stores a new delivery version of the offer (or of one of its milestones) and
+// resolves the revision request it answers. The latest offer-level delivery is also mirrored on
+// the offer so existing clients keep reading WorkDeliveryLink/WorkDeliveryFiles.
+func recordDelivery(tx *gorm.DB, offer *models.JobOffer, milestone *models.Milestone, userID uuid.UUID, note, link string, files []string) (*models.Delivery, error) {
+	var last int
+	if err := tx.Model(&models.Delivery{}).Where("job_offer_id = ?", offer.ID).
+		Select("COALESCE(MAX(version), 0)").Scan(&last).Error; err != nil {
+		return nil, err
+	}
+
+	filesJSON, _ := json.Marshal(files)
+	delivery := models.Delivery{
+		ID:          uuid.New(),
+		JobOfferID:  offer.ID,
+		Version:     last + 1,
+		DeliveredBy: userID,
+		Note:        note,
+		Link:        link,
+		Files:       string(filesJSON),
+	}
+
+	revisions := tx.Model(&models.Revision{}).Where("job_offer_id = ? AND status = ?", offer.ID, models.RevisionStatusOpen)
+	if milestone != nil {
+		delivery.MilestoneID = &milestone.ID
+		milestone.WorkDeliveryLink = link
+		milestone.WorkDeliveryFiles = delivery.Files
+		revisions = revisions.Where("milestone_id = ?", milestone.ID)
+	} else {
+		offer.WorkDeliveryLink = link
+		offer.WorkDeliveryFiles = delivery.Files
+		revisions = revisions.Where("milestone_id IS NULL")
+	}
+
+	if err := tx.Create(&delivery).Error; err != nil {
+		return nil, err
+	}
+
+	// This delivery answers the open revision request, if any
+	if err := revisions.Updates(map[string]interface{}{
+		"status":         models.RevisionStatusResolved,
+		"resolved_at":    delivery.CreatedAt,
+		"delivery_id":    delivery.ID,
+		"delivery_link":  link,
+		"delivery_files": delivery.Files,
+	}).Error; err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// loadOfferForParty loads an offer the current user is a party of (admins see every offer)
+func (h *JobOfferHandler) loadOfferForParty(c *fiber.Ctx) (*models.JobOffer, error) {
+	userID, err := getAuth(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var offer models.JobOffer
+	if err := h.DB.First(&offer, "id = ?", c.Params("id")).Error; err != nil {
+		return nil, fiber.NewError(404, "Offer not found")
+	}
+	if offer.ClientID != userID && offer.FreelancerID != userID && c.Locals("role") != string(models.RoleAdmin) {
+		return nil, fiber.NewError(403, "Access denied")
+	}
+	return &offer, nil
+}
+
+// ListDeliveries returns every delivery version of an order, newest first.
+// Optional query: milestone_id to only list the deliveries of one milestone.
+func (h *JobOfferHandler) ListDeliveries(c *fiber.Ctx) error {
+	offer, err := h.loadOfferForParty(c)
+	if err != nil {
+		if e, ok := err.(*fiber.Error); ok {
+			return c.Status(e.Code).JSON(fiber.Map{"success": false, "message": e.Message})
+		}
+		return err
+	}
+
+	query := h.DB.Where("job_offer_id = ?", offer.ID)
+	if milestoneID := c.Query("milestone_id"); milestoneID != "" {
+		query = query.Where("milestone_id = ?", milestoneID)
+	}
+
+	var deliveries []models.Delivery
+	if err := query.Order("version DESC").Find(&deliveries).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to fetch deliveries"})
+	}
+
+	return c.JSON(fiber.Map{"success": true, "data": deliveries})
+}
+
+// GetDelivery returns one delivery version of an order
+func (h *JobOfferHandler) GetDelivery(c *fiber.Ctx) error {
+	offer, err := h.loadOfferForParty(c)
+	if err != nil {
+		if e, ok := err.(*fiber.Error); ok {
+			return c.Status(e.Code).JSON(fiber.Map{"success": false, "message": e.Message})
+		}
+		return err
+	}
+
+	version, err := strconv.Atoi(c.Params("version"))
+	if err != nil || version < 1 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid delivery version"})
+	}
+
+	var delivery models.Delivery
+	if err := h.DB.Where("job_offer_id = ? AND version = ?", offer.ID, version).First(&delivery).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "message": "Delivery not found"})
+	}
+
+	return c.JSON(fiber.Map{"success": true, "data": delivery})
+}
@@ -0,0 +1,447 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/models"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/clearance"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/refund"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OpenDispute lets the client contest a working or delivered order. The order is frozen in
+// the disputed status (no auto-completion) until an admin resolves it or the client withdraws.
+// The reason can be sent as JSON or as a multipart form with "evidence" files.
+func (h *JobOfferHandler) OpenDispute(c *fiber.Ctx) error {
+	userID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var req struct {
+		Reason string `json:"reason" form:"reason"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.Reason == "" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Dispute reason is required"})
+	}
+
+	files, err := saveFormFiles(c, "evidence", "disputes")
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": err.Error()})
+	}
+	filesJSON, _ := json.Marshal(files)
+
+	var offer models.JobOffer
+	var dispute models.Dispute
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&offer, "id = ?", c.Params("id")).Error; err != nil {
+			return fiber.NewError(404, "Offer not found")
+		}
+		if offer.ClientID != userID {
+			return fiber.NewError(403, "Only the client can open a dispute")
+		}
+
+		previous := offer.Status
+		if err := offer.Transition(models.OfferStatusDisputed, models.OfferActorClient); err != nil {
+			return fiber.NewError(400, "Disputes can only be opened on working or delivered orders")
+		}
+		if err := tx.Save(&offer).Error; err != nil {
+			return err
+		}
+
+		dispute = models.Dispute{
+			JobOfferID:     offer.ID,
+			OrderCode:      offer.OrderCode,
+			OpenedBy:       userID,
+			Reason:         req.Reason,
+			Status:         models.DisputeStatusOpen,
+			PreviousStatus: previous,
+			Evidence: []models.DisputeEvidence{{
+				SubmittedBy: userID,
+				Role:        models.OfferActorClient,
+				Text:        req.Reason,
+				Files:       string(filesJSON),
+			}},
+		}
+		return tx.Create(&dispute).Error
+	})
+	if err != nil {
+		if e, ok := err.(*fiber.Error); ok {
+			return c.Status(e.Code).JSON(fiber.Map{"success": false, "message": e.Message})
+		}
+		log.Printf("Failed to open dispute: %v", err)
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to open dispute"})
+	}
+
+	text := "Pembeli membuka sengketa untuk pesanan #" + offer.OrderCode + ". Penyelesaian otomatis dihentikan sampai admin memberikan keputusan. Kedua pihak dapat mengirimkan bukti pendukung.\n\nAlasan: " + req.Reason
+	h.postOrderMessage(&offer, userID, "system", text)
+
+	return c.Status(201).JSON(fiber.Map{"success": true, "message": "Dispute opened", "data": dispute})
+}
+
+// ListOfferDisputes returns the disputes of an order with their evidence, newest first
+func (h *JobOfferHandler) ListOfferDisputes(c *fiber.Ctx) error {
+	userID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var offer models.JobOffer
+	if err := h.DB.First(&offer, "id = ?", c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "message": "Offer not found"})
+	}
+	if offer.ClientID != userID && offer.FreelancerID != userID && c.Locals("role") != string(models.RoleAdmin) {
+		return c.Status(403).JSON(fiber.Map{"success": false, "message": "Access denied"})
+	}
+
+	var disputes []models.Dispute
+	if err := h.DB.Preload("Evidence", func(db *gorm.DB) *gorm.DB { return db.Order("created_at ASC") }).
+		Where("job_offer_id = ?", offer.ID).Order("created_at DESC").Find(&disputes).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to fetch disputes"})
+	}
+
+	return c.JSON(fiber.Map{"success": true, "data": disputes})
+}
+
+// SubmitDisputeEvidence adds a statement with optional "files" to the open dispute of an
+// order, from either party or an admin
+func (h *JobOfferHandler) SubmitDisputeEvidence(c *fiber.Ctx) error {
+	userID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var req struct {
+		Text string `json:"text" form:"text"`
+	}
+	_ = c.BodyParser(&req)
+
+	files, err := saveFormFiles(c, "files", "disputes")
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": err.Error()})
+	}
+	if req.Text == "" && len(files) == 0 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Evidence text or files are required"})
+	}
+	filesJSON, _ := json.Marshal(files)
+
+	var offer models.JobOffer
+	var evidence models.DisputeEvidence
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&offer, "id = ?", c.Params("id")).Error; err != nil {
+			return fiber.NewError(404, "Offer not found")
+		}
+		role := offer.ActorFor(userID)
+		if c.Locals("role") == string(models.RoleAdmin) {
+			role = models.OfferActorAdmin
+		}
+		if role == "" {
+			return fiber.NewError(403, "Access denied")
+		}
+
+		var dispute models.Dispute
+		if err := tx.Where("job_offer_id = ? AND status = ?", offer.ID, models.DisputeStatusOpen).
+			First(&dispute).Error; err != nil {
+			return fiber.NewError(404, "No open dispute on this order")
+		}
+
+		evidence = models.DisputeEvidence{
+			DisputeID:   dispute.ID,
+			SubmittedBy: userID,
+			Role:        role,
+			Text:        req.Text,
+			Files:       string(filesJSON),
+		}
+		return tx.Create(&evidence).Error
+	})
+	if err != nil {
+		if e, ok := err.(*fiber.Error); ok {
+			return c.Status(e.Code).JSON(fiber.Map{"success": false, "message": e.Message})
+		}
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to submit evidence"})
+	}
+
+	return c.Status(201).JSON(fiber.Map{"success": true, "data": evidence})
+}
+
+// WithdrawDispute lets the client close their open dispute; the order goes back to the
+// status it had before and auto-completion resumes
+func (h *JobOfferHandler) WithdrawDispute(c *fiber.Ctx) error {
+	userID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var offer models.JobOffer
+	var dispute models.Dispute
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&offer, "id = ?", c.Params("id")).Error; err != nil {
+			return fiber.NewError(404, "Offer not found")
+		}
+		if offer.ClientID != userID {
+			return fiber.NewError(403, "Only the client can withdraw the dispute")
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("job_offer_id = ? AND status = ?", offer.ID, models.DisputeStatusOpen).
+			First(&dispute).Error; err != nil {
+			return fiber.NewError(404, "No open dispute on this order")
+		}
+
+		if err := offer.Transition(dispute.PreviousStatus, models.OfferActorClient); err != nil {
+			return fiber.NewError(400, err.Error())
+		}
+		if err := tx.Save(&offer).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		dispute.Status = models.DisputeStatusWithdrawn
+		dispute.ResolvedAt = &now
+		return tx.Save(&dispute).Error
+	})
+	if err != nil {
+		if e, ok := err.(*fiber.Error); ok {
+			return c.Status(e.Code).JSON(fiber.Map{"success": false, "message": e.Message})
+		}
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to withdraw dispute"})
+	}
+
+	h.postOrderMessage(&offer, userID, "system", "Pembeli menarik kembali sengketa pesanan #"+offer.OrderCode+". Pesanan dilanjutkan seperti semula.")
+
+	return c.JSON(fiber.Map{"success": true, "data": dispute})
+}
+
+// AdminListDisputes returns disputes for arbitration, oldest open ones first
+func (h *JobOfferHandler) AdminListDisputes(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 20)
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 20
+	}
+	offset := (page - 1) * limit
+
+	q := h.DB.Model(&models.Dispute{})
+	if status := c.Query("status"); status != "" {
+		q = q.Where("status = ?", status)
+	}
+
+	var total int64
+	q.Count(&total)
+
+	var disputes []models.Dispute
+	if err := q.Order("created_at ASC").Limit(limit).Offset(offset).Find(&disputes).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to fetch disputes"})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    disputes,
+		"meta": fiber.Map{
+			"page":        page,
+			"limit":       limit,
+			"total_items": total,
+			"total_pages": int(math.Ceil(float64(total) / float64(limit))),
+		},
+	})
+}
+
+// AdminGetDispute returns a dispute with its evidence and the order behind it
+func (h *JobOfferHandler) AdminGetDispute(c *fiber.Ctx) error {
+	var dispute models.Dispute
+	if err := h.DB.Preload("Evidence", func(db *gorm.DB) *gorm.DB { return db.Order("created_at ASC") }).
+		First(&dispute, "id = ?", c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "message": "Dispute not found"})
+	}
+
+	var offer models.JobOffer
+	h.DB.Preload("Freelancer").Preload("Client").Preload("Product").Preload("Milestones", orderedMilestones).
+		First(&offer, "id = ?", dispute.JobOfferID)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"dispute":          dispute,
+			"offer":            toJobOfferResponse(&offer),
+			"escrow_remaining": offer.EscrowRemaining(),
+		},
+	})
+}
+
+type ResolveDisputeRequest struct {
+	Resolution   models.DisputeResolution `json:"resolution"`    // release, refund or split
+	RefundAmount int64                    `json:"refund_amount"` // Split only: escrow returned to the client, the rest goes to the freelancer
+	Note         string                   `json:"note"`
+}
+
+// ResolveDispute settles a disputed order. A release pays the escrow left to the freelancer
+// and completes the order; a refund returns it to the client and cancels the order; a split
+// does both with the given refund amount and completes the order. Refunds wait for the
+// client to choose a destination, like any other refund.
+func (h *JobOfferHandler) ResolveDispute(c *fiber.Ctx) error {
+	adminID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var req ResolveDisputeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid request body"})
+	}
+	switch req.Resolution {
+	case models.DisputeResolutionRelease, models.DisputeResolutionRefund, models.DisputeResolutionSplit:
+	default:
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Resolution must be 'release', 'refund' or 'split'"})
+	}
+
+	var offer models.JobOffer
+	var dispute models.Dispute
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&dispute, "id = ?", c.Params("id")).Error; err != nil {
+			return fiber.NewError(404, "Dispute not found")
+		}
+		// Lock the order before the dispute, in the same order as OpenDispute/WithdrawDispute.
+		// Concurrent resolutions wait here and then see the dispute closed.
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&offer, "id = ?", dispute.JobOfferID).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&dispute, "id = ?", dispute.ID).Error; err != nil {
+			return err
+		}
+		if dispute.Status != models.DisputeStatusOpen {
+			return fiber.NewError(400, "Dispute is already closed")
+		}
+		if offer.Status != models.OfferStatusDisputed {
+			return fiber.NewError(400, "Order is no longer in dispute")
+		}
+
+		// Milestones the client never paid for are not part of the settlement
+		if err := dropUnfundedMilestones(tx, &offer); err != nil {
+			return err
+		}
+
+		// A full refund also takes back the earnings of completed milestones that have not
+		// cleared yet; cleared ones stay with the freelancer
+		if req.Resolution == models.DisputeResolutionRefund {
+			err := h.Clearance.Reverse(tx, &offer, "Pembatalan pelepasan dana milestone, sengketa pesanan #"+offer.OrderCode)
+			if err != nil && !errors.Is(err, clearance.ErrAlreadyCleared) {
+				return err
+			}
+		}
+
+		escrow := offer.EscrowRemaining()
+		var refundAmount int64
+		switch req.Resolution {
+		case models.DisputeResolutionRefund:
+			refundAmount = escrow
+		case models.DisputeResolutionSplit:
+			if req.RefundAmount <= 0 || req.RefundAmount >= escrow {
+				return fiber.NewError(400, fmt.Sprintf("Split refund amount must be between 0 and the escrow left (%d)", escrow))
+			}
+			refundAmount = req.RefundAmount
+		}
+
+		if refundAmount > 0 {
+			reason := "Keputusan sengketa pesanan #" + offer.OrderCode
+			r, err := h.Refunds.Open(tx, &offer, refundAmount, req.Resolution == models.DisputeResolutionRefund, reason, &adminID)
+			if err != nil {
+				return err
+			}
+			dispute.RefundRequestID = &r.ID
+		}
+
+		if req.Resolution == models.DisputeResolutionRefund {
+			if err := offer.Transition(models.OfferStatusCancelled, models.OfferActorAdmin); err != nil {
+				return fiber.NewError(400, err.Error())
+			}
+			if err := tx.Save(&offer).Error; err != nil {
+				return err
+			}
+			if err := cancelOpenMilestones(tx, offer.ID); err != nil {
+				return err
+			}
+		} else {
+			if err := offer.Transition(models.OfferStatusCompleted, models.OfferActorAdmin); err != nil {
+				return fiber.NewError(400, err.Error())
+			}
+			if err := tx.Model(&models.Milestone{}).
+				Where("job_offer_id = ? AND status IN ?", offer.ID, []models.MilestoneStatus{
+					models.MilestoneStatusFunded,
+					models.MilestoneStatusDelivered,
+				}).
+				Updates(map[string]interface{}{
+					"status":       models.MilestoneStatusCompleted,
+					"completed_at": time.Now(),
+				}).Error; err != nil {
+				return err
+			}
+			released := offer.ReleasedAmount
+			if err := h.releaseEscrow(tx, &offer, offer.Price, "Pelepasan dana sengketa pesanan #"+offer.OrderCode); err != nil {
+				return err
+			}
+			dispute.ReleaseAmount = offer.ReleasedAmount - released
+		}
+
+		now := time.Now()
+		dispute.Status = models.DisputeStatusResolved
+		dispute.Resolution = req.Resolution
+		dispute.RefundAmount = refundAmount
+		dispute.AdminNote = req.Note
+		dispute.ResolvedBy = &adminID
+		dispute.ResolvedAt = &now
+		return tx.Save(&dispute).Error
+	})
+	if err != nil {
+		if errors.Is(err, refund.ErrExceedsEscrow) {
+			return c.Status(400).JSON(fiber.Map{"success": false, "message": err.Error()})
+		}
+		if e, ok := err.(*fiber.Error); ok {
+			return c.Status(e.Code).JSON(fiber.Map{"success": false, "message": e.Message})
+		}
+		log.Printf("[Dispute] Failed to resolve dispute %s: %v", c.Params("id"), err)
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to resolve dispute"})
+	}
+
+	h.postOrderMessage(&offer, adminID, "system", disputeResolvedText(&dispute))
+
+	return c.JSON(fiber.Map{"success": true, "message": "Dispute resolved", "data": dispute})
+}
+
+func disputeResolvedText(d *models.Dispute) string {
+	text := "Sengketa pesanan #" + d.OrderCode + " telah diputuskan oleh admin: "
+	switch d.Resolution {
+	case models.DisputeResolutionRelease:
+		text += fmt.Sprintf("dana escrow Rp %d diteruskan ke saldo tertunda Freelancer. Pesanan dinyatakan selesai.", d.ReleaseAmount)
+	case models.DisputeResolutionRefund:
+		text += fmt.Sprintf("dana Rp %d dikembalikan ke pembeli dan pesanan dibatalkan. Silakan pilih tujuan pengembalian dana.", d.RefundAmount)
+	case models.DisputeResolutionSplit:
+		text += fmt.Sprintf("Rp %d dikembalikan ke pembeli dan Rp %d diteruskan ke saldo tertunda Freelancer. Pesanan dinyatakan selesai. Silakan pilih tujuan pengembalian dana.", d.RefundAmount, d.ReleaseAmount)
+	}
+	if d.AdminNote != "" {
+		text += "\n\nCatatan admin: " + d.AdminNote
+	}
+	return text
+}
+
+// closeOpenDisputes settles the open dispute of an order that was refunded outside arbitration
+// (e.g. by the payment gateway)
+func closeOpenDisputes(tx *gorm.DB, offerID uuid.UUID, note string) error {
+	return tx.Model(&models.Dispute{}).
+		Where("job_offer_id = ? AND status = ?", offerID, models.DisputeStatusOpen).
+		Updates(map[string]interface{}{
+			"status":      models.DisputeStatusResolved,
+			"resolution":  models.DisputeResolutionRefund,
+			"admin_note":  note,
+			"resolved_at": time.Now(),
+		}).Error
+}
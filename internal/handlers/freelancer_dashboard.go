@@ -37,7 +37,7 @@ func (h *FreelancerDashboardHandler) GetDashboardStats(c *fiber.Ctx) error {
 		return err
 	}
 
-	// 1. Active Orders (Pending, Paid, Working, Delivered)
+	// 1. Active Orders (Pending, Paid, Working, Delivered, Disputed)
 	var activeOrders int64
 	if err := h.DB.Model(&models.JobOffer{}).
 		Where("freelancer_id = ?", userID).
@@ -46,6 +46,7 @@ func (h *FreelancerDashboardHandler) GetDashboardStats(c *fiber.Ctx) error {
 			models.OfferStatusPaid,
 			models.OfferStatusWorking,
 			models.OfferStatusDelivered,
+			models.OfferStatusDisputed,
 		}).
 		Count(&activeOrders).Error; err != nil {
 		log.Printf("[DashboardStats] Error counting active orders for user %v: %v", userID, err)
@@ -68,17 +69,23 @@ func (h *FreelancerDashboardHandler) GetDashboardStats(c *fiber.Ctx) error {
 	// 3. Earnings (Sum of Credit transactions)
 	var totalEarnings int64
 	h.DB.Model(&models.WalletTransaction{}).
-		Where("user_id = ?", userID).
+		Where("user_id = ? AND wallet = ?", userID, models.WalletFreelancer).
 		Where("type = ?", models.WalletTrxCredit).
 		Select("COALESCE(SUM(amount), 0)").
 		Scan(&totalEarnings)
 
+	// 4. Balances: earnings still in their clearing period vs. withdrawable
+	var profile models.FreelancerProfile
+	h.DB.Select("balance", "pending_balance").Where("user_id = ?", userID).First(&profile)
+
 	return c.JSON(fiber.Map{
 		"success": true,
 		"data": fiber.Map{
-			"active_orders":  activeOrders,
-			"unread_chats":   unreadChats,
-			"total_earnings": totalEarnings,
+			"active_orders":     activeOrders,
+			"unread_chats":      unreadChats,
+			"total_earnings":    totalEarnings,
+			"pending_balance":   profile.PendingBalance,
+			"available_balance": profile.Balance,
 		},
 	})
 }
@@ -171,20 +178,50 @@ func (h *FreelancerDashboardHandler) GetEarnings(c *fiber.Ctx) error {
 
 	var creditTotal int64
 	h.DB.Model(&models.WalletTransaction{}).
-		Where("user_id = ? AND type = ?", userID, "credit").
+		Where("user_id = ? AND wallet = ? AND type = ?", userID, models.WalletFreelancer, models.WalletTrxCredit).
 		Select("COALESCE(SUM(amount), 0)").
 		Scan(&creditTotal)
 
 	var debitTotal int64
 	h.DB.Model(&models.WalletTransaction{}).
-		Where("user_id = ? AND type = ?", userID, "debit").
+		Where("user_id = ? AND wallet = ? AND type = ?", userID, models.WalletFreelancer, models.WalletTrxDebit).
 		Select("COALESCE(SUM(amount), 0)").
 		Scan(&debitTotal)
 
-	totalEarnings := creditTotal - debitTotal
+	// Refunds on the freelancer wallet are returned withdrawals (rejected, failed or cancelled)
+	var refundTotal int64
+	h.DB.Model(&models.WalletTransaction{}).
+		Where("user_id = ? AND wallet = ? AND type = ?", userID, models.WalletFreelancer, models.WalletTrxRefund).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&refundTotal)
+
+	totalEarnings := creditTotal - debitTotal + refundTotal
+
+	// Withdrawals still holding funds vs. already paid out
+	var pendingWithdrawals int64
+	h.DB.Model(&models.Withdrawal{}).
+		Where("user_id = ? AND status IN ?", userID, []models.WithdrawalStatus{
+			models.WithdrawalStatusPending,
+			models.WithdrawalStatusApproved,
+		}).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&pendingWithdrawals)
+
+	var completedWithdrawals int64
+	h.DB.Model(&models.Withdrawal{}).
+		Where("user_id = ? AND status = ?", userID, models.WithdrawalStatusTransferred).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&completedWithdrawals)
+
+	var profile models.FreelancerProfile
+	h.DB.Select("balance", "pending_balance").Where("user_id = ?", userID).First(&profile)
+
+	// Earnings still clearing, soonest available first
+	var pending []models.EarningClearance
+	h.DB.Where("user_id = ? AND status = ?", userID, models.ClearancePending).Order("available_at ASC").Find(&pending)
 
 	var history []models.WalletTransaction
-	if err := h.DB.Where("user_id = ?", userID).Order("created_at desc").Limit(50).Find(&history).Error; err != nil {
+	if err := h.DB.Where("user_id = ? AND wallet = ?", userID, models.WalletFreelancer).Order("created_at desc").Limit(50).Find(&history).Error; err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"success": false,
 			"message": "Failed to fetch earnings history",
@@ -194,8 +231,14 @@ func (h *FreelancerDashboardHandler) GetEarnings(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{
 		"success": true,
 		"data": fiber.Map{
-			"total_earnings": totalEarnings,
-			"history":        history,
+			"total_earnings":        totalEarnings,
+			"total_income":          creditTotal,
+			"pending_withdrawals":   pendingWithdrawals,
+			"completed_withdrawals": completedWithdrawals,
+			"pending_balance":       profile.PendingBalance,
+			"available_balance":     profile.Balance,
+			"pending_earnings":      pending,
+			"history":               history,
 		},
 	})
 }
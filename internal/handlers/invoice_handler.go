@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/models"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/invoice"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// InvoiceHandler serves client invoices and freelancer earnings receipts of paid orders
+type InvoiceHandler struct {
+	DB       *gorm.DB
+	Invoices *invoice.InvoiceService
+}
+
+func NewInvoiceHandler(db *gorm.DB, invoiceService *invoice.InvoiceService) *InvoiceHandler {
+	return &InvoiceHandler{DB: db, Invoices: invoiceService}
+}
+
+// GetInvoice returns the invoice of an order as JSON, or as a PDF with ?format=pdf.
+// Only the client of the order (or an admin) can see it.
+func (h *InvoiceHandler) GetInvoice(c *fiber.Ctx) error {
+	userID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var offer models.JobOffer
+	if err := h.DB.First(&offer, "id = ?", c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "message": "Order not found"})
+	}
+	if offer.ClientID != userID && c.Locals("role") != string(models.RoleAdmin) {
+		return c.Status(403).JSON(fiber.Map{"success": false, "message": "Access denied"})
+	}
+
+	inv, err := h.Invoices.ForOffer(&offer)
+	if err != nil {
+		if errors.Is(err, invoice.ErrNotPaid) {
+			return c.Status(404).JSON(fiber.Map{"success": false, "message": "Invoice is available once the order is paid"})
+		}
+		log.Printf("Failed to issue invoice for order %s: %v", offer.OrderCode, err)
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to load invoice"})
+	}
+
+	if c.Query("format") == "pdf" {
+		filename := "invoice-" + strings.ReplaceAll(inv.Number, "/", "-") + ".pdf"
+		c.Set(fiber.HeaderContentType, "application/pdf")
+		c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+filename+`"`)
+		return c.Send(invoice.RenderInvoicePDF(inv))
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"invoice": inv,
+			"items":   invoice.Items(inv),
+		},
+	})
+}
+
+// GetEarningsReceipt returns the freelancer's earnings receipt of an order (JSON or ?format=pdf),
+// showing the platform fee deducted from the order price.
+func (h *InvoiceHandler) GetEarningsReceipt(c *fiber.Ctx) error {
+	userID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var offer models.JobOffer
+	if err := h.DB.Preload("Freelancer").Preload("Client").First(&offer, "id = ?", c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "message": "Order not found"})
+	}
+	if offer.FreelancerID != userID && c.Locals("role") != string(models.RoleAdmin) {
+		return c.Status(403).JSON(fiber.Map{"success": false, "message": "Access denied"})
+	}
+	if offer.Status == models.OfferStatusPending {
+		return c.Status(404).JSON(fiber.Map{"success": false, "message": "Receipt is available once the order is paid"})
+	}
+
+	receipt := invoice.Receipt(&offer)
+
+	if c.Query("format") == "pdf" {
+		c.Set(fiber.HeaderContentType, "application/pdf")
+		c.Set(fiber.HeaderContentDisposition, `attachment; filename="receipt-`+offer.OrderCode+`.pdf"`)
+		return c.Send(invoice.RenderReceiptPDF(&receipt))
+	}
+
+	return c.JSON(fiber.Map{"success": true, "data": receipt})
+}
@@ -1,7 +1,8 @@
 package handlers
 
 import (
-	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
@@ -10,11 +11,18 @@ import (
 
 	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/models"
 	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/realtime"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/clearance"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/commission"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/ledger"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/refund"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/voucher"
 	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/wallet"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/utils"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type JobOfferHandler struct {
@@ -22,10 +30,16 @@ type JobOfferHandler struct {
 	Hub           *realtime.Hub
 	RDB           *redis.Client
 	WalletService *wallet.WalletService
+	Ledger        *ledger.LedgerService
+	Refunds       *refund.RefundService
+	Commission    *commission.CommissionService
+	Clearance     *clearance.ClearanceService
+	Vouchers      *voucher.VoucherService
+	OfferValidity time.Duration // Default validity period of a pending offer
 }
 
-func NewJobOfferHandler(db *gorm.DB, hub *realtime.Hub, rdb *redis.Client, walletService *wallet.WalletService) *JobOfferHandler {
-	return &JobOfferHandler{DB: db, Hub: hub, RDB: rdb, WalletService: walletService}
+func NewJobOfferHandler(db *gorm.DB, hub *realtime.Hub, rdb *redis.Client, walletService *wallet.WalletService, ledgerService *ledger.LedgerService, refundService *refund.RefundService, commissionService *commission.CommissionService, clearanceService *clearance.ClearanceService, voucherService *voucher.VoucherService, offerValidity time.Duration) *JobOfferHandler {
+	return &JobOfferHandler{DB: db, Hub: hub, RDB: rdb, WalletService: walletService, Ledger: ledgerService, Refunds: refundService, Commission: commissionService, Clearance: clearanceService, Vouchers: voucherService, OfferValidity: offerValidity}
 }
 
 // CreateOfferRequest is the request body for creating a job offer
@@ -35,10 +49,15 @@ type CreateOfferRequest struct {
 	Title          string `json:"title"`
 	Description    string `json:"description"`
 	RevisionCount  int    `json:"revision_count"`
-	StartDate      string `json:"start_date"`      // ISO format: 2026-01-03
-	DeliveryDate   string `json:"delivery_date"`   // ISO format: 2026-01-05
+	StartDate      string `json:"start_date"`      // ISO format: 2026-01-03 (Asia/Jakarta)
+	DeliveryDate   string `json:"delivery_date"`   // ISO format: 2026-01-05 (Asia/Jakarta)
 	DeliveryFormat string `json:"delivery_format"` // e.g., ".pdf, .png"
 	Notes          string `json:"notes"`
+	ValidDays      int    `json:"valid_days"` // Days the client has to pay; 0 = platform default
+
+	// Optional: split the order into milestones whose amounts add up to the price
+	MilestonePayment string             `json:"milestone_payment"` // upfront (default) or per_milestone
+	Milestones       []MilestoneRequest `json:"milestones"`
 }
 
 // JobOfferResponse is the response DTO for job offer
@@ -49,10 +68,21 @@ type JobOfferResponse struct {
 	FreelancerID   string `json:"freelancer_id"`
 	ClientID       string `json:"client_id"`
 	ProductID      *uint  `json:"product_id,omitempty"`
-
-	Price       int64 `json:"price"`
-	PlatformFee int64 `json:"platform_fee"`
-	NetAmount   int64 `json:"net_amount"`
+	Package        string `json:"package,omitempty"`
+	Addons         string `json:"addons,omitempty"`
+
+	Price             int64  `json:"price"`
+	PlatformFee       int64  `json:"platform_fee"`
+	NetAmount         int64  `json:"net_amount"`
+	CommissionRateBps int    `json:"commission_rate_bps"`
+	CommissionFlatFee int64  `json:"commission_flat_fee"`
+	DiscountAmount    int64  `json:"discount_amount"`
+	DiscountFundedBy  string `json:"discount_funded_by,omitempty"`
+	ExtrasAmount      int64  `json:"extras_amount"`
+
+	MilestonePayment string             `json:"milestone_payment,omitempty"`
+	DeferredAmount   int64              `json:"deferred_amount,omitempty"` // Price not charged at checkout, funded per milestone
+	Milestones       []models.Milestone `json:"milestones,omitempty"`
 
 	Title         string `json:"title"`
 	Description   string `json:"description"`
@@ -60,6 +90,7 @@ type JobOfferResponse struct {
 
 	StartDate      string `json:"start_date"`
 	DeliveryDate   string `json:"delivery_date"`
+	IsOverdue      bool   `json:"is_overdue"`
 	DeliveryFormat string `json:"delivery_format"`
 	Notes          string `json:"notes"`
 
@@ -67,8 +98,14 @@ type JobOfferResponse struct {
 	WorkDeliveryFiles string `json:"work_delivery_files"`
 	UsedRevisionCount int    `json:"used_revision_count"`
 
-	Status    string    `json:"status"`
-	CreatedAt time.Time `json:"created_at"`
+	Status    string     `json:"status"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+
+	Version         int        `json:"version"`
+	PreviousOfferID *uuid.UUID `json:"previous_offer_id,omitempty"`
+	SupersededByID  *uuid.UUID `json:"superseded_by_id,omitempty"`
+	DeclineReason   string     `json:"decline_reason,omitempty"`
 
 	// Optional embedded data
 	Product    *ProductMini `json:"product,omitempty"`
@@ -90,21 +127,37 @@ func toJobOfferResponse(offer *models.JobOffer) JobOfferResponse {
 		FreelancerID:      offer.FreelancerID.String(),
 		ClientID:          offer.ClientID.String(),
 		ProductID:         offer.ProductID,
+		Package:           offer.Package,
+		Addons:            offer.Addons,
 		Price:             offer.Price,
 		PlatformFee:       offer.PlatformFee,
 		NetAmount:         offer.NetAmount,
+		CommissionRateBps: offer.CommissionRateBps,
+		CommissionFlatFee: offer.CommissionFlatFee,
+		DiscountAmount:    offer.DiscountAmount,
+		DiscountFundedBy:  string(offer.DiscountFundedBy),
+		ExtrasAmount:      offer.ExtrasAmount,
+		MilestonePayment:  string(offer.MilestonePayment),
+		DeferredAmount:    offer.DeferredAmount,
+		Milestones:        offer.Milestones,
 		Title:             offer.Title,
 		Description:       offer.Description,
 		RevisionCount:     offer.RevisionCount,
-		StartDate:         offer.StartDate.Format("2006-01-02"),
-		DeliveryDate:      offer.DeliveryDate.Format("2006-01-02"),
+		StartDate:         utils.FormatDate(offer.StartDate),
+		DeliveryDate:      utils.FormatDate(offer.DeliveryDate),
+		IsOverdue:         offer.IsOverdue,
 		DeliveryFormat:    offer.DeliveryFormat,
 		Notes:             offer.Notes,
 		WorkDeliveryLink:  offer.WorkDeliveryLink,
 		WorkDeliveryFiles: offer.WorkDeliveryFiles,
 		UsedRevisionCount: offer.UsedRevisionCount,
 		Status:            string(offer.Status),
+		ExpiresAt:         offer.ExpiresAt,
 		CreatedAt:         offer.CreatedAt,
+		Version:           offer.Version,
+		PreviousOfferID:   offer.PreviousOfferID,
+		SupersededByID:    offer.SupersededByID,
+		DeclineReason:     offer.DeclineReason,
 	}
 
 	if offer.Product != nil {
@@ -218,40 +271,61 @@ func (h *JobOfferHandler) CreateOffer(c *fiber.Ctx) error {
 	}
 
 	// Parse dates
-	startDate, err := time.Parse("2006-01-02", req.StartDate)
+	startDate, err := utils.ParseDate(req.StartDate)
 	if err != nil {
-		startDate = time.Now()
+		startDate = utils.StartOfDay(time.Now())
 	}
 
-	deliveryDate, err := time.Parse("2006-01-02", req.DeliveryDate)
+	deliveryDate, err := utils.ParseDate(req.DeliveryDate)
 	if err != nil {
-		deliveryDate = time.Now().AddDate(0, 0, 7) // Default 7 days
+		deliveryDate = utils.StartOfDay(time.Now()).AddDate(0, 0, 7) // Default 7 days
 	}
 
-	// Calculate platform fee (10%)
-	platformFee := req.Price / 10
-	netAmount := req.Price - platformFee
+	plan, err := buildMilestonePlan(&req)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+	if plan != nil {
+		deliveryDate = plan.lastDueDate()
+	}
 
-	// Generate unique order code
-	var orderCode string
-	for {
-		orderCode = models.GenerateOrderCode()
-		var existing models.JobOffer
-		if h.DB.Where("order_code = ?", orderCode).First(&existing).Error == gorm.ErrRecordNotFound {
-			break
-		}
+	expiresAt, err := h.offerExpiry(req.ValidDays)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+
+	// Resolve platform fee from the commission rules
+	criteria, err := h.Commission.CriteriaFor(userUUID, req.ProductID, req.Price)
+	if err != nil {
+		log.Println("Error loading commission criteria:", err)
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to calculate platform fee",
+		})
+	}
+	quote, err := h.Commission.Resolve(criteria)
+	if err != nil {
+		log.Println("Error resolving commission rule:", err)
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to calculate platform fee",
+		})
 	}
 
 	// Create job offer
 	offer := models.JobOffer{
-		OrderCode:      orderCode,
+		OrderCode:      uniqueOrderCode(h.DB),
 		ConversationID: convUUID,
 		FreelancerID:   userUUID,
 		ClientID:       conv.ClientID,
 		ProductID:      req.ProductID,
 		Price:          req.Price,
-		PlatformFee:    platformFee,
-		NetAmount:      netAmount,
 		Title:          req.Title,
 		Description:    req.Description,
 		RevisionCount:  req.RevisionCount,
@@ -260,7 +334,10 @@ func (h *JobOfferHandler) CreateOffer(c *fiber.Ctx) error {
 		DeliveryFormat: req.DeliveryFormat,
 		Notes:          req.Notes,
 		Status:         models.OfferStatusPending,
+		ExpiresAt:      expiresAt,
 	}
+	quote.Apply(&offer)
+	plan.apply(&offer)
 
 	if err := h.DB.Create(&offer).Error; err != nil {
 		log.Println("Error creating job offer:", err)
@@ -272,14 +349,14 @@ func (h *JobOfferHandler) CreateOffer(c *fiber.Ctx) error {
 
 	// Load relations for response
 	h.DB.Preload("Freelancer").Preload("Freelancer.FreelancerProfile").
-		Preload("Client").Preload("Product").
+		Preload("Client").Preload("Product").Preload("Milestones", orderedMilestones).
 		First(&offer, "id = ?", offer.ID)
 
 	// Create a system message in the conversation
 	msg := models.Message{
 		ConversationID: convUUID,
 		SenderID:       userUUID,
-		Text:           "[OFFER]" + offer.ID.String(), // Special marker for offer messages
+		Text:           offerMarker + offer.ID.String(), // Special marker for offer messages
 		IsRead:         false,
 	}
 
@@ -314,6 +391,17 @@ func (h *JobOfferHandler) CreateOffer(c *fiber.Ctx) error {
 	})
 }
 
+// uniqueOrderCode generates an order code no other offer uses yet
+func uniqueOrderCode(db *gorm.DB) string {
+	for {
+		orderCode := models.GenerateOrderCode()
+		var existing models.JobOffer
+		if db.Where("order_code = ?", orderCode).First(&existing).Error == gorm.ErrRecordNotFound {
+			return orderCode
+		}
+	}
+}
+
 // GetOffers returns all job offers for a conversation
 func (h *JobOfferHandler) GetOffers(c *fiber.Ctx) error {
 	userID := c.Locals("userId")
@@ -435,6 +523,7 @@ func (h *JobOfferHandler) GetOffer(c *fiber.Ctx) error {
 		Preload("Freelancer.FreelancerProfile").
 		Preload("Client").
 		Preload("Product").
+		Preload("Milestones", orderedMilestones).
 		First(&offer, "id = ?", offerUUID).Error; err != nil {
 		return c.Status(404).JSON(fiber.Map{
 			"success": false,
@@ -504,42 +593,33 @@ func (h *JobOfferHandler) UpdateStatus(c *fiber.Ctx) error {
 		})
 	}
 
-	// Validate status
-	validStatuses := map[string]bool{
-		"pending":   true,
-		"paid":      true,
-		"working":   true,
-		"delivered": true,
-		"completed": true,
-		"cancelled": true,
-	}
-
-	if !validStatuses[req.Status] {
-		return c.Status(400).JSON(fiber.Map{
-			"success": false,
-			"message": "Invalid status",
-		})
-	}
-
 	var offer models.JobOffer
-	if err := h.DB.First(&offer, "id = ?", offerUUID).Error; err != nil {
-		return c.Status(404).JSON(fiber.Map{
-			"success": false,
-			"message": "Job offer not found",
-		})
-	}
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&offer, "id = ?", offerUUID).Error; err != nil {
+			return fiber.NewError(404, "Job offer not found")
+		}
 
-	// Verify user is part of this offer
-	if offer.ClientID != userUUID && offer.FreelancerID != userUUID {
-		return c.Status(403).JSON(fiber.Map{
-			"success": false,
-			"message": "Access denied",
-		})
-	}
+		// Verify user is part of this offer
+		actor := offer.ActorFor(userUUID)
+		if actor == "" {
+			return fiber.NewError(403, "Access denied")
+		}
 
-	// Update status
-	offer.Status = models.JobOfferStatus(req.Status)
-	if err := h.DB.Save(&offer).Error; err != nil {
+		// Only side-effect free transitions can be set here; payment, delivery, revision,
+		// completion and cancellation go through their own endpoints
+		to := models.JobOfferStatus(req.Status)
+		if !models.IsDirectTransition(offer.Status, to) {
+			return fiber.NewError(400, fmt.Sprintf("Status cannot be changed from %s to %s here", offer.Status, to))
+		}
+		if err := offer.Transition(to, actor); err != nil {
+			return fiber.NewError(403, err.Error())
+		}
+		return tx.Save(&offer).Error
+	})
+	if err != nil {
+		if e, ok := err.(*fiber.Error); ok {
+			return c.Status(e.Code).JSON(fiber.Map{"success": false, "message": e.Message})
+		}
 		log.Println("Error updating job offer status:", err)
 		return c.Status(500).JSON(fiber.Map{
 			"success": false,
@@ -648,34 +728,82 @@ func (h *JobOfferHandler) UpdateOffer(c *fiber.Ctx) error {
 	}
 
 	// Parse dates
-	startDate, err := time.Parse("2006-01-02", req.StartDate)
+	startDate, err := utils.ParseDate(req.StartDate)
 	if err != nil {
-		startDate = time.Now()
+		startDate = utils.StartOfDay(time.Now())
 	}
 
-	deliveryDate, err := time.Parse("2006-01-02", req.DeliveryDate)
+	deliveryDate, err := utils.ParseDate(req.DeliveryDate)
 	if err != nil {
-		deliveryDate = time.Now().AddDate(0, 0, 7)
-	}
-
-	// Recalculate fees
-	platformFee := req.Price / 10
-	netAmount := req.Price - platformFee
-
-	// Update fields
-	offer.Price = req.Price
-	offer.PlatformFee = platformFee
-	offer.NetAmount = netAmount
-	offer.Title = req.Title
-	offer.Description = req.Description
-	offer.RevisionCount = req.RevisionCount
-	offer.StartDate = startDate
-	offer.DeliveryDate = deliveryDate
-	offer.DeliveryFormat = req.DeliveryFormat
-	offer.Notes = req.Notes
-	offer.ProductID = req.ProductID
-
-	if err := h.DB.Save(&offer).Error; err != nil {
+		deliveryDate = utils.StartOfDay(time.Now()).AddDate(0, 0, 7)
+	}
+
+	plan, err := buildMilestonePlan(&req)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+	if plan != nil {
+		deliveryDate = plan.lastDueDate()
+	}
+
+	// New terms restart the validity period
+	expiresAt, err := h.offerExpiry(req.ValidDays)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+
+	// The milestone plan is replaced as a whole; new terms answer any pending counter-offer
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		// Re-check under the row lock: the client may have paid since the offer was read
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&offer, "id = ?", offer.ID).Error; err != nil {
+			return err
+		}
+		if offer.Status != models.OfferStatusPending {
+			return fiber.NewError(400, "Cannot update offer that is not pending")
+		}
+
+		// Update fields
+		offer.Price = req.Price
+		offer.Title = req.Title
+		offer.Description = req.Description
+		offer.RevisionCount = req.RevisionCount
+		offer.StartDate = startDate
+		offer.DeliveryDate = deliveryDate
+		offer.DeliveryFormat = req.DeliveryFormat
+		offer.Notes = req.Notes
+		offer.ProductID = req.ProductID
+		offer.ExpiresAt = expiresAt
+
+		// Re-resolve the commission for the new terms
+		quote, err := h.Commission.QuoteOffer(&offer)
+		if err != nil {
+			log.Println("Error resolving commission rule:", err)
+			return fiber.NewError(500, "Failed to calculate platform fee")
+		}
+		quote.Apply(&offer)
+		plan.apply(&offer)
+
+		if err := tx.Where("job_offer_id = ?", offer.ID).Delete(&models.Milestone{}).Error; err != nil {
+			return err
+		}
+		if err := cancelPendingCounters(tx, offer.ID); err != nil {
+			return err
+		}
+		return tx.Save(&offer).Error
+	})
+	if err != nil {
+		if e, ok := err.(*fiber.Error); ok {
+			return c.Status(e.Code).JSON(fiber.Map{
+				"success": false,
+				"message": e.Message,
+			})
+		}
 		log.Println("Error updating job offer:", err)
 		return c.Status(500).JSON(fiber.Map{
 			"success": false,
@@ -685,7 +813,7 @@ func (h *JobOfferHandler) UpdateOffer(c *fiber.Ctx) error {
 
 	// Load relations
 	h.DB.Preload("Freelancer").Preload("Freelancer.FreelancerProfile").
-		Preload("Client").Preload("Product").
+		Preload("Client").Preload("Product").Preload("Milestones", orderedMilestones).
 		First(&offer, "id = ?", offer.ID)
 
 	// Broadcast update
@@ -722,65 +850,56 @@ func (h *JobOfferHandler) DeliverWork(c *fiber.Ctx) error {
 	if offer.FreelancerID != userUUID {
 		return c.Status(403).JSON(fiber.Map{"success": false, "message": "Only the assigned freelancer can deliver work"})
 	}
+	if offer.HasMilestones() {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "This order is delivered per milestone"})
+	}
 
 	// Allow delivery if paid, working, or already delivered (for updates)
 	isUpdate := offer.Status == models.OfferStatusDelivered
-	if !isUpdate && offer.Status != models.OfferStatusPaid && offer.Status != models.OfferStatusWorking {
+	if !isUpdate && offer.CanTransition(models.OfferStatusDelivered, models.OfferActorFreelancer) != nil {
 		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Order must be in 'Paid', 'Working', or 'Delivered' status to deliver"})
 	}
 
 	workURL := c.FormValue("work_url")
 
 	// Handle Multiple File Uploads
-	form, err := c.MultipartForm()
-	var filePaths []string
+	filePaths, err := saveFormFiles(c, "files", "deliveries")
 	if err != nil {
-		log.Println("Error parsing multipart form:", err)
-	} else {
-		files := form.File["files"]
-		for _, file := range files {
-			// Limit 25MB check (Fiber usually has a global limit, but we can check individually)
-			if file.Size > 25*1024*1024 {
-				return c.Status(400).JSON(fiber.Map{"success": false, "message": "File " + file.Filename + " exceeds 25MB limit"})
-			}
-
-			// Save file
-			ext := filepath.Ext(file.Filename)
-			filename := uuid.New().String() + ext
-			uploadDir := "./uploads/deliveries"
-			os.MkdirAll(uploadDir, 0755)
-
-			savePath := filepath.Join(uploadDir, filename)
-			if err := c.SaveFile(file, savePath); err != nil {
-				log.Println("Error saving delivery file:", err)
-				continue
-			}
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": err.Error()})
+	}
 
-			publicPath := "/uploads/deliveries/" + filename
-			base := os.Getenv("APP_BASE_URL")
-			if base != "" {
-				publicPath = strings.TrimRight(base, "/") + publicPath
+	// Every delivery is kept as a new version; the offer mirrors the latest one
+	var delivery *models.Delivery
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&offer, "id = ?", offer.ID).Error; err != nil {
+			return err
+		}
+		isUpdate = offer.Status == models.OfferStatusDelivered
+		if !isUpdate {
+			if err := offer.Transition(models.OfferStatusDelivered, models.OfferActorFreelancer); err != nil {
+				return fiber.NewError(400, err.Error())
 			}
-			filePaths = append(filePaths, publicPath)
 		}
-	}
-
-	filesJSON, _ := json.Marshal(filePaths)
 
-	// Update Offer
-	offer.Status = models.OfferStatusDelivered
-	offer.WorkDeliveryLink = workURL
-	offer.WorkDeliveryFiles = string(filesJSON)
-	offer.UpdatedAt = time.Now()
-
-	if err := h.DB.Save(&offer).Error; err != nil {
+		var err error
+		delivery, err = recordDelivery(tx, &offer, nil, userUUID, c.FormValue("note"), workURL, filePaths)
+		if err != nil {
+			return err
+		}
+		offer.UpdatedAt = delivery.CreatedAt
+		return tx.Save(&offer).Error
+	})
+	if err != nil {
+		if e, ok := err.(*fiber.Error); ok {
+			return c.Status(e.Code).JSON(fiber.Map{"success": false, "message": e.Message})
+		}
 		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to update offer status"})
 	}
 
 	// Create Delivery System Message
-	msgText := "Freelancer mengirimkan pekerjaan untuk ditinjau dan disetujui.\n\nPembeli dapat meminta revisi dalam kurun waktu 7 hari. Jika tidak ada respon dalam jangka waktu yang ditentukan, sistem akan secara otomatis menyetujui pekerjaan untuk freelancer."
+	msgText := fmt.Sprintf("Freelancer mengirimkan pekerjaan (versi %d) untuk ditinjau dan disetujui.\n\nPembeli dapat meminta revisi dalam kurun waktu 7 hari. Jika tidak ada respon dalam jangka waktu yang ditentukan, sistem akan secara otomatis menyetujui pekerjaan untuk freelancer.", delivery.Version)
 	if isUpdate {
-		msgText = "Freelancer telah memperbarui hasil pekerjaan.\n\nSilakan tinjau kembali hasil pekerjaan terbaru yang telah dikirimkan."
+		msgText = fmt.Sprintf("Freelancer telah memperbarui hasil pekerjaan (versi %d).\n\nSilakan tinjau kembali hasil pekerjaan terbaru yang telah dikirimkan.", delivery.Version)
 	}
 
 	msg := models.Message{
@@ -789,6 +908,7 @@ func (h *JobOfferHandler) DeliverWork(c *fiber.Ctx) error {
 		SenderID:       userUUID,
 		Text:           msgText,
 		Type:           "delivery", // Special type for custom rendering
+		DeliveryID:     &delivery.ID,
 		IsRead:         false,
 	}
 	h.DB.Create(&msg)
@@ -802,9 +922,11 @@ func (h *JobOfferHandler) DeliverWork(c *fiber.Ctx) error {
 			"sender_id":       msg.SenderID.String(),
 			"text":            msg.Text,
 			"type":            msg.Type,
+			"delivery_id":     msg.DeliveryID,
 			"created_at":      msg.CreatedAt,
 		},
-		"offer": toJobOfferResponse(&offer),
+		"offer":    toJobOfferResponse(&offer),
+		"delivery": delivery,
 	})
 
 	h.Hub.SendToConversation(offer.ClientID, offer.FreelancerID, fiber.Map{
@@ -818,86 +940,6 @@ func (h *JobOfferHandler) DeliverWork(c *fiber.Ctx) error {
 	})
 }
 
-// RequestRevision handles revision request by client
-func (h *JobOfferHandler) RequestRevision(c *fiber.Ctx) error {
-	userID := c.Locals("userId")
-	if userID == nil {
-		return c.Status(401).JSON(fiber.Map{"success": false, "message": "Unauthorized"})
-	}
-
-	userUUID, _ := uuid.Parse(userID.(string))
-	offerID := c.Params("id")
-	offerUUID, err := uuid.Parse(offerID)
-	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid offer ID"})
-	}
-
-	var req struct {
-		Reason string `json:"reason"`
-	}
-	if err := c.BodyParser(&req); err != nil || req.Reason == "" {
-		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Revision reason is required"})
-	}
-
-	var offer models.JobOffer
-	if err := h.DB.First(&offer, "id = ?", offerUUID).Error; err != nil {
-		return c.Status(404).JSON(fiber.Map{"success": false, "message": "Offer not found"})
-	}
-
-	if offer.ClientID != userUUID {
-		return c.Status(403).JSON(fiber.Map{"success": false, "message": "Only the client can request revisions"})
-	}
-
-	if offer.Status != models.OfferStatusDelivered {
-		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Revision can only be requested for delivered work"})
-	}
-
-	if offer.UsedRevisionCount >= offer.RevisionCount {
-		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Revision limit reached. No more revisions available for this offer."})
-	}
-
-	// Update Offer
-	offer.Status = models.OfferStatusWorking
-	offer.UsedRevisionCount++
-	offer.UpdatedAt = time.Now()
-
-	if err := h.DB.Save(&offer).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to update offer status"})
-	}
-
-	// Create Revision Message
-	msg := models.Message{
-		ID:             uuid.New(),
-		ConversationID: offer.ConversationID,
-		SenderID:       userUUID,
-		Text:           req.Reason,
-		Type:           "revision", // Special type for revision info
-		IsRead:         false,
-	}
-	h.DB.Create(&msg)
-
-	// Broadcast Updates
-	h.Hub.SendToConversation(offer.ClientID, offer.FreelancerID, fiber.Map{
-		"type": "new_message",
-		"message": fiber.Map{
-			"id":              msg.ID.String(),
-			"conversation_id": msg.ConversationID.String(),
-			"sender_id":       msg.SenderID.String(),
-			"text":            msg.Text,
-			"type":            msg.Type,
-			"created_at":      msg.CreatedAt,
-		},
-		"offer": toJobOfferResponse(&offer),
-	})
-
-	h.Hub.SendToConversation(offer.ClientID, offer.FreelancerID, fiber.Map{
-		"type":  "offer_status_update",
-		"offer": toJobOfferResponse(&offer),
-	})
-
-	return c.JSON(fiber.Map{"success": true, "data": toJobOfferResponse(&offer)})
-}
-
 // CompleteOrder handles order completion by client
 func (h *JobOfferHandler) CompleteOrder(c *fiber.Ctx) error {
 	userID := c.Locals("userId")
@@ -915,13 +957,16 @@ func (h *JobOfferHandler) CompleteOrder(c *fiber.Ctx) error {
 	err = h.DB.Transaction(func(tx *gorm.DB) error {
 		var offer models.JobOffer
 		// Lock the offer row for update (Idempotency / Race condition prevention)
-		if err := tx.Set("gorm:query_option", "FOR UPDATE").First(&offer, "id = ?", offerUUID).Error; err != nil {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&offer, "id = ?", offerUUID).Error; err != nil {
 			return err
 		}
 
 		if offer.ClientID != userUUID {
 			return fiber.NewError(403, "Only the client can complete the order")
 		}
+		if offer.HasMilestones() {
+			return fiber.NewError(400, "This order completes when all its milestones are approved")
+		}
 
 		// IDEMPOTENCY: If already completed, just return
 		if offer.Status == models.OfferStatusCompleted {
@@ -929,20 +974,16 @@ func (h *JobOfferHandler) CompleteOrder(c *fiber.Ctx) error {
 			return nil
 		}
 
-		if offer.Status != models.OfferStatusDelivered {
+		// 1. Update Offer Status
+		if err := offer.Transition(models.OfferStatusCompleted, models.OfferActorClient); err != nil {
 			return fiber.NewError(400, "Only delivered orders can be completed")
 		}
-
-		// 1. Update Offer Status
-		offer.Status = models.OfferStatusCompleted
-		offer.UpdatedAt = time.Now()
 		if err := tx.Save(&offer).Error; err != nil {
 			return err
 		}
 
-		// 2. ESCROW RELEASE LOGIC via WalletService
-		desc := "Pembayaran pesanan #" + offer.OrderCode
-		if err := h.WalletService.CreditFreelancer(tx, offer.FreelancerID, offer.NetAmount, offer.ID, desc); err != nil {
+		// 2. ESCROW RELEASE LOGIC: earnings sit in the pending balance until they clear
+		if err := h.releaseEscrow(tx, &offer, offer.Price, "Pembayaran pesanan #"+offer.OrderCode); err != nil {
 			log.Printf("Failed to release escrow for offer %s: %v", offer.ID, err)
 			return err
 		}
@@ -952,7 +993,7 @@ func (h *JobOfferHandler) CompleteOrder(c *fiber.Ctx) error {
 			ID:             uuid.New(),
 			ConversationID: offer.ConversationID,
 			SenderID:       userUUID,
-			Text:           "Pesanan telah diselesaikan oleh pembeli. Dana telah diteruskan ke saldo Freelancer. Terima kasih!",
+			Text:           "Pesanan telah diselesaikan oleh pembeli. Dana telah diteruskan ke saldo tertunda Freelancer dan dapat ditarik setelah masa kliring. Terima kasih!",
 			Type:           "system",
 			IsRead:         false,
 			CreatedAt:      time.Now(),
@@ -998,6 +1039,21 @@ func (h *JobOfferHandler) CompleteOrder(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"success": true, "data": toJobOfferResponse(&finalOffer)})
 }
 
+// releaseEscrow pays the freelancer's share of the completed part of an order (completed being
+// the price covered so far, the whole price for a single delivery) out of escrow into their
+// pending balance, and saves the offer
+func (h *JobOfferHandler) releaseEscrow(tx *gorm.DB, offer *models.JobOffer, completed int64, description string) error {
+	share := offer.NextRelease(completed)
+	if err := h.Ledger.RecordEscrowRelease(tx, offer, share, description); err != nil {
+		return err
+	}
+	if _, err := h.Clearance.Hold(tx, offer, share.Net, description); err != nil {
+		return err
+	}
+	offer.MarkReleased(share)
+	return tx.Save(offer).Error
+}
+
 // StartAutoCompletionWorker runs a background job to complete orders after 3 days of delivery
 func (h *JobOfferHandler) StartAutoCompletionWorker() {
 	ticker := time.NewTicker(1 * time.Hour)
@@ -1005,6 +1061,7 @@ func (h *JobOfferHandler) StartAutoCompletionWorker() {
 		for range ticker.C {
 			log.Println("[AutoCompletionWorker] Scanning for delivered orders to auto-complete...")
 			h.scanAndCompleteOrders()
+			h.scanAndCompleteMilestones()
 		}
 	}()
 }
@@ -1026,25 +1083,23 @@ func (h *JobOfferHandler) scanAndCompleteOrders() {
 		h.DB.Transaction(func(tx *gorm.DB) error {
 			// Lock row
 			var currentOffer models.JobOffer
-			if err := tx.Set("gorm:query_option", "FOR UPDATE").First(&currentOffer, "id = ?", offer.ID).Error; err != nil {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&currentOffer, "id = ?", offer.ID).Error; err != nil {
 				return err
 			}
 
-			// Idempotency
-			if currentOffer.Status != models.OfferStatusDelivered {
+			// Idempotency: no longer delivered (revision, completion, ...)
+			if err := currentOffer.Transition(models.OfferStatusCompleted, models.OfferActorSystem); err != nil {
 				return nil
 			}
 
 			// 1. Update status
-			currentOffer.Status = models.OfferStatusCompleted
-			currentOffer.UpdatedAt = time.Now()
 			if err := tx.Save(&currentOffer).Error; err != nil {
 				return err
 			}
 
-			// 2. Release Escrow via WalletService
+			// 2. Release Escrow into the freelancer's pending balance
 			desc := "Penyelesaian otomatis pesanan #" + currentOffer.OrderCode + " (tanpa respon dari pembeli)"
-			if err := h.WalletService.CreditFreelancer(tx, currentOffer.FreelancerID, currentOffer.NetAmount, currentOffer.ID, desc); err != nil {
+			if err := h.releaseEscrow(tx, &currentOffer, currentOffer.Price, desc); err != nil {
 				return err
 			}
 
@@ -1054,7 +1109,7 @@ func (h *JobOfferHandler) scanAndCompleteOrders() {
 				ConversationID: currentOffer.ConversationID,
 				SenderID:       currentOffer.FreelancerID,
 				Type:           "system",
-				Text:           "Pesanan telah diselesaikan secara otomatis oleh sistem (3 hari setelah pengiriman tanpa respon). Dana diteruskan ke Freelancer.",
+				Text:           "Pesanan telah diselesaikan secara otomatis oleh sistem (3 hari setelah pengiriman tanpa respon). Dana diteruskan ke saldo tertunda Freelancer.",
 				CreatedAt:      time.Now(),
 			}
 			if err := tx.Create(&msg).Error; err != nil {
@@ -1109,14 +1164,14 @@ func (h *JobOfferHandler) CancelOrder(c *fiber.Ctx) error {
 	}
 
 	// Allow cancelling pending OR paid orders
-	if offer.Status != models.OfferStatusPending && offer.Status != models.OfferStatusPaid {
+	if offer.Status != models.OfferStatusCancelled && offer.CanTransition(models.OfferStatusCancelled, models.OfferActorFreelancer) != nil {
 		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Hanya pesanan pending atau berstatus paid yang dapat dibatalkan"})
 	}
 
 	err = h.DB.Transaction(func(tx *gorm.DB) error {
 		// Lock row
 		var currentOffer models.JobOffer
-		if err := tx.Set("gorm:query_option", "FOR UPDATE").First(&currentOffer, "id = ?", offer.ID).Error; err != nil {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&currentOffer, "id = ?", offer.ID).Error; err != nil {
 			return err
 		}
 
@@ -1124,27 +1179,39 @@ func (h *JobOfferHandler) CancelOrder(c *fiber.Ctx) error {
 		if currentOffer.Status == models.OfferStatusCancelled {
 			return nil
 		}
+		wasPaid := currentOffer.Status == models.OfferStatusPaid
+		if err := currentOffer.Transition(models.OfferStatusCancelled, models.OfferActorFreelancer); err != nil {
+			return fiber.NewError(400, "Hanya pesanan pending atau berstatus paid yang dapat dibatalkan")
+		}
 
-		// 1. Refund logic if already PAID
-		if currentOffer.Status == models.OfferStatusPaid {
-			desc := "Pengembalian dana untuk pembatalan pesanan #" + currentOffer.OrderCode
-			// Refund to Client's platform balance
-			if err := h.WalletService.CreditClient(tx, currentOffer.ClientID, currentOffer.Price, currentOffer.ID, desc); err != nil {
+		// 1. Refund logic if already PAID: the client chooses where the money goes
+		if wasPaid {
+			reason := "Pembatalan pesanan #" + currentOffer.OrderCode + " oleh freelancer"
+			if _, err := h.Refunds.Open(tx, &currentOffer, currentOffer.EscrowRemaining(), true, reason, &userUUID); err != nil {
 				return err
 			}
+		} else if err := h.closeOfferPayments(tx, &currentOffer, "Pesanan dibatalkan oleh freelancer"); err != nil {
+			return err
 		}
 
 		// 2. Update status
-		currentOffer.Status = models.OfferStatusCancelled
-		currentOffer.UpdatedAt = time.Now()
 		if err := tx.Save(&currentOffer).Error; err != nil {
 			return err
 		}
+		if err := cancelOpenMilestones(tx, currentOffer.ID); err != nil {
+			return err
+		}
+		if err := cancelPendingExtensions(tx, currentOffer.ID); err != nil {
+			return err
+		}
+		if err := cancelPendingCounters(tx, currentOffer.ID); err != nil {
+			return err
+		}
 
 		// 3. Create System Message
 		cancelMsg := "Pesanan #" + currentOffer.OrderCode + " telah dibatalkan oleh freelancer."
-		if currentOffer.Status == models.OfferStatusCancelled && offer.Status == models.OfferStatusPaid {
-			cancelMsg += " Dana telah dikembalikan ke saldo Jokiin Anda."
+		if wasPaid {
+			cancelMsg += " Dana akan dikembalikan, silakan pilih tujuan pengembalian dana (saldo Jokiin, metode pembayaran asal, atau rekening bank)."
 		}
 
 		msg := models.Message{
@@ -1181,6 +1248,9 @@ func (h *JobOfferHandler) CancelOrder(c *fiber.Ctx) error {
 	})
 
 	if err != nil {
+		if e, ok := err.(*fiber.Error); ok {
+			return c.Status(e.Code).JSON(fiber.Map{"success": false, "message": e.Message})
+		}
 		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to cancel order"})
 	}
 
@@ -1255,3 +1325,40 @@ func (h *JobOfferHandler) SubmitReview(c *fiber.Ctx) error {
 		"data":    review,
 	})
 }
+
+// saveFormFiles stores the uploaded files of a multipart field under ./uploads/<dir> and
+// returns their public URLs. A request without a multipart body has no files.
+func saveFormFiles(c *fiber.Ctx, field, dir string) ([]string, error) {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, nil
+	}
+
+	var filePaths []string
+	for _, file := range form.File[field] {
+		// Limit 25MB check (Fiber usually has a global limit, but we can check individually)
+		if file.Size > 25*1024*1024 {
+			return nil, errors.New("File " + file.Filename + " exceeds 25MB limit")
+		}
+
+		// Save file
+		ext := filepath.Ext(file.Filename)
+		filename := uuid.New().String() + ext
+		uploadDir := "./uploads/" + dir
+		os.MkdirAll(uploadDir, 0755)
+
+		savePath := filepath.Join(uploadDir, filename)
+		if err := c.SaveFile(file, savePath); err != nil {
+			log.Println("Error saving uploaded file:", err)
+			continue
+		}
+
+		publicPath := "/uploads/" + dir + "/" + filename
+		base := os.Getenv("APP_BASE_URL")
+		if base != "" {
+			publicPath = strings.TrimRight(base, "/") + publicPath
+		}
+		filePaths = append(filePaths, publicPath)
+	}
+	return filePaths, nil
+}
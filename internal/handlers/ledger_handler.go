@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"math"
+
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// LedgerHandler exposes the double-entry ledger to admins/finance
+type LedgerHandler struct {
+	DB *gorm.DB
+}
+
+func NewLedgerHandler(db *gorm.DB) *LedgerHandler {
+	return &LedgerHandler{DB: db}
+}
+
+// ListAccounts returns ledger accounts with their current balances
+func (h *LedgerHandler) ListAccounts(c *fiber.Ctx) error {
+	q := h.DB.Model(&models.LedgerAccount{})
+
+	if accountType := c.Query("type"); accountType != "" {
+		q = q.Where("type = ?", accountType)
+	}
+	if ownerID := c.Query("owner_id"); ownerID != "" {
+		ownerUUID, err := uuid.Parse(ownerID)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid owner ID"})
+		}
+		q = q.Where("owner_id = ?", ownerUUID)
+	}
+
+	var accounts []models.LedgerAccount
+	if err := q.Order("code ASC").Find(&accounts).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to fetch ledger accounts"})
+	}
+
+	return c.JSON(fiber.Map{"success": true, "data": accounts})
+}
+
+// GetAccountLines returns the journal lines posted to one account, newest first
+func (h *LedgerHandler) GetAccountLines(c *fiber.Ctx) error {
+	var account models.LedgerAccount
+	if err := h.DB.Where("code = ?", c.Params("code")).First(&account).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "message": "Ledger account not found"})
+	}
+
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 50)
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 50
+	}
+	offset := (page - 1) * limit
+
+	q := h.DB.Model(&models.JournalLine{}).Where("account_id = ?", account.ID)
+
+	var total int64
+	q.Count(&total)
+
+	type lineOut struct {
+		models.JournalLine
+		Description   string     `json:"description"`
+		ReferenceType string     `json:"reference_type"`
+		ReferenceID   *uuid.UUID `json:"reference_id,omitempty"`
+	}
+
+	var lines []lineOut
+	if err := q.Select("journal_lines.*, journal_entries.description, journal_entries.reference_type, journal_entries.reference_id").
+		Joins("JOIN journal_entries ON journal_entries.id = journal_lines.entry_id").
+		Order("journal_lines.created_at DESC").
+		Limit(limit).Offset(offset).
+		Scan(&lines).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to fetch ledger lines"})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"account": account,
+			"lines":   lines,
+		},
+		"meta": fiber.Map{
+			"page":        page,
+			"limit":       limit,
+			"total_items": total,
+			"total_pages": int(math.Ceil(float64(total) / float64(limit))),
+		},
+	})
+}
+
+// ListEntries returns journal entries, optionally for a single business reference
+func (h *LedgerHandler) ListEntries(c *fiber.Ctx) error {
+	q := h.DB.Model(&models.JournalEntry{}).Preload("Lines").Preload("Lines.Account")
+
+	if refType := c.Query("reference_type"); refType != "" {
+		q = q.Where("reference_type = ?", refType)
+	}
+	if refID := c.Query("reference_id"); refID != "" {
+		refUUID, err := uuid.Parse(refID)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid reference ID"})
+		}
+		q = q.Where("reference_id = ?", refUUID)
+	}
+
+	limit := c.QueryInt("limit", 50)
+	if limit < 1 {
+		limit = 50
+	}
+
+	var entries []models.JournalEntry
+	if err := q.Order("created_at DESC").Limit(limit).Find(&entries).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to fetch journal entries"})
+	}
+
+	return c.JSON(fiber.Map{"success": true, "data": entries})
+}
+
+// GetTrialBalance proves the books balance: total debits equal total credits and
+// assets + expenses equal liabilities + revenue.
+func (h *LedgerHandler) GetTrialBalance(c *fiber.Ctx) error {
+	var totals struct {
+		Debit  int64
+		Credit int64
+	}
+	h.DB.Model(&models.JournalLine{}).
+		Select("COALESCE(SUM(debit), 0) AS debit, COALESCE(SUM(credit), 0) AS credit").
+		Scan(&totals)
+
+	var byType []struct {
+		Type    string `json:"type"`
+		Balance int64  `json:"balance"`
+	}
+	h.DB.Model(&models.LedgerAccount{}).
+		Select("type, COALESCE(SUM(balance), 0) AS balance").
+		Group("type").
+		Scan(&byType)
+
+	sums := map[string]int64{}
+	for _, t := range byType {
+		sums[t.Type] = t.Balance
+	}
+	debitSide := sums[string(models.LedgerAsset)] + sums[string(models.LedgerExpense)]
+	creditSide := sums[string(models.LedgerLiability)] + sums[string(models.LedgerRevenue)]
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"total_debit":  totals.Debit,
+			"total_credit": totals.Credit,
+			"by_type":      sums,
+			"balanced":     totals.Debit == totals.Credit && debitSide == creditSide,
+		},
+	})
+}
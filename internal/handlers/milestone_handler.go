@@ -0,0 +1,556 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/models"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/commission"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/wallet"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const maxMilestones = 20
+
+// MilestoneRequest is one milestone of a CreateOfferRequest
+type MilestoneRequest struct {
+	Title         string `json:"title"`
+	Description   string `json:"description"`
+	Deliverables  string `json:"deliverables"`
+	Amount        int64  `json:"amount"`
+	DueDate       string `json:"due_date"`       // ISO format: 2026-01-05 (Asia/Jakarta)
+	RevisionCount *int   `json:"revision_count"` // Defaults to the offer's revision count
+}
+
+// milestonePlan is the validated milestone split of an offer request
+type milestonePlan struct {
+	payment    models.MilestonePayment
+	milestones []models.Milestone
+	deferred   int64
+}
+
+// buildMilestonePlan validates the milestones of an offer request; nil means a single delivery
+func buildMilestonePlan(req *CreateOfferRequest) (*milestonePlan, error) {
+	if len(req.Milestones) == 0 {
+		if req.MilestonePayment != "" {
+			return nil, fiber.NewError(400, "Milestone payment requires milestones")
+		}
+		return nil, nil
+	}
+	if len(req.Milestones) > maxMilestones {
+		return nil, fiber.NewError(400, fmt.Sprintf("An offer can have at most %d milestones", maxMilestones))
+	}
+
+	plan := &milestonePlan{payment: models.MilestonePayment(req.MilestonePayment)}
+	switch plan.payment {
+	case "":
+		plan.payment = models.MilestonePaymentUpfront
+	case models.MilestonePaymentUpfront, models.MilestonePaymentPerMilestone:
+	default:
+		return nil, fiber.NewError(400, "Milestone payment must be 'upfront' or 'per_milestone'")
+	}
+
+	var total int64
+	for i, m := range req.Milestones {
+		if strings.TrimSpace(m.Title) == "" {
+			return nil, fiber.NewError(400, fmt.Sprintf("Milestone %d needs a title", i+1))
+		}
+		if m.Amount <= 0 {
+			return nil, fiber.NewError(400, fmt.Sprintf("Milestone %d amount must be positive", i+1))
+		}
+		dueDate, err := utils.ParseDate(m.DueDate)
+		if err != nil {
+			return nil, fiber.NewError(400, fmt.Sprintf("Milestone %d needs a due date (YYYY-MM-DD)", i+1))
+		}
+		if i > 0 && dueDate.Before(plan.milestones[i-1].DueDate) {
+			return nil, fiber.NewError(400, fmt.Sprintf("Milestone %d is due before the previous one", i+1))
+		}
+		revisions := req.RevisionCount
+		if m.RevisionCount != nil {
+			revisions = *m.RevisionCount
+		}
+		if revisions < 0 {
+			return nil, fiber.NewError(400, fmt.Sprintf("Milestone %d revision count cannot be negative", i+1))
+		}
+
+		plan.milestones = append(plan.milestones, models.Milestone{
+			Sequence:      i + 1,
+			Title:         m.Title,
+			Description:   m.Description,
+			Deliverables:  m.Deliverables,
+			Amount:        m.Amount,
+			DueDate:       dueDate,
+			Status:        models.MilestoneStatusPending,
+			RevisionCount: revisions,
+		})
+		total += m.Amount
+		// Per-milestone payment charges only the first milestone at checkout
+		if i > 0 && plan.payment == models.MilestonePaymentPerMilestone {
+			plan.deferred += m.Amount
+		}
+	}
+	if total != req.Price {
+		return nil, fiber.NewError(400, fmt.Sprintf("Milestone amounts add up to %d, expected the offer price %d", total, req.Price))
+	}
+	return plan, nil
+}
+
+func (p *milestonePlan) lastDueDate() time.Time {
+	return p.milestones[len(p.milestones)-1].DueDate
+}
+
+// apply sets the plan on an offer; a nil plan makes it a single delivery
+func (p *milestonePlan) apply(offer *models.JobOffer) {
+	if p == nil {
+		offer.MilestonePayment = ""
+		offer.DeferredAmount = 0
+		offer.Milestones = nil
+		return
+	}
+	offer.MilestonePayment = p.payment
+	offer.DeferredAmount = p.deferred
+	offer.Milestones = p.milestones
+}
+
+func orderedMilestones(db *gorm.DB) *gorm.DB {
+	return db.Order("sequence ASC")
+}
+
+// fundCheckoutMilestones marks the milestones paid at checkout as funded: all of them with
+// upfront payment, the first one with per-milestone payment
+func fundCheckoutMilestones(tx *gorm.DB, offer *models.JobOffer) error {
+	if !offer.HasMilestones() {
+		return nil
+	}
+	q := tx.Model(&models.Milestone{}).
+		Where("job_offer_id = ? AND status = ?", offer.ID, models.MilestoneStatusPending)
+	if offer.MilestonePayment == models.MilestonePaymentPerMilestone {
+		q = q.Where("sequence = 1")
+	}
+	return q.Updates(map[string]interface{}{
+		"status":    models.MilestoneStatusFunded,
+		"funded_at": time.Now(),
+	}).Error
+}
+
+// cancelOpenMilestones closes the milestones of a cancelled order that were not completed
+func cancelOpenMilestones(tx *gorm.DB, offerID uuid.UUID) error {
+	return tx.Model(&models.Milestone{}).
+		Where("job_offer_id = ? AND status IN ?", offerID, []models.MilestoneStatus{
+			models.MilestoneStatusPending,
+			models.MilestoneStatusFunded,
+			models.MilestoneStatusDelivered,
+		}).
+		Update("status", models.MilestoneStatusCancelled).Error
+}
+
+// dropUnfundedMilestones cancels the milestones the client never funded and takes them out of
+// the price (and the deferred amount), so that the order can be settled on what is in escrow
+func dropUnfundedMilestones(tx *gorm.DB, offer *models.JobOffer) error {
+	unfunded := offer.DeferredAmount - offer.DeferredFunded
+	if unfunded <= 0 {
+		return nil
+	}
+	if err := tx.Model(&models.Milestone{}).
+		Where("job_offer_id = ? AND status = ?", offer.ID, models.MilestoneStatusPending).
+		Update("status", models.MilestoneStatusCancelled).Error; err != nil {
+		return err
+	}
+
+	offer.Price -= unfunded
+	offer.DeferredAmount -= unfunded
+	offer.PlatformFee = commission.Fee(offer.Price, offer.CommissionRateBps, offer.CommissionFlatFee)
+	offer.NetAmount = offer.Price - offer.PlatformFee
+	return tx.Save(offer).Error
+}
+
+// milestonesActive tells whether milestones of an order in this status can be funded, delivered or approved
+func milestonesActive(status models.JobOfferStatus) bool {
+	return status == models.OfferStatusPaid || status == models.OfferStatusWorking
+}
+
+// ListMilestones returns the milestones of an order with their revision history
+func (h *JobOfferHandler) ListMilestones(c *fiber.Ctx) error {
+	userID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var offer models.JobOffer
+	if err := h.DB.Preload("Milestones", orderedMilestones).First(&offer, "id = ?", c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "message": "Offer not found"})
+	}
+	if offer.ClientID != userID && offer.FreelancerID != userID && c.Locals("role") != string(models.RoleAdmin) {
+		return c.Status(403).JSON(fiber.Map{"success": false, "message": "Access denied"})
+	}
+
+	var revisions []models.Revision
+	h.DB.Where("job_offer_id = ? AND milestone_id IS NOT NULL", offer.ID).Order("requested_at ASC").Find(&revisions)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"milestone_payment": offer.MilestonePayment,
+			"deferred_amount":   offer.DeferredAmount,
+			"deferred_funded":   offer.DeferredFunded,
+			"released_amount":   offer.ReleasedAmount,
+			"milestones":        offer.Milestones,
+			"revisions":         revisions,
+		},
+	})
+}
+
+// FundMilestone lets the client pay a milestone of a per-milestone order from their wallet
+// balance. The money is held in escrow until the milestone is approved.
+func (h *JobOfferHandler) FundMilestone(c *fiber.Ctx) error {
+	userID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var offer models.JobOffer
+	var milestone models.Milestone
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := h.lockMilestone(tx, c, &offer, &milestone); err != nil {
+			return err
+		}
+		if offer.ClientID != userID {
+			return fiber.NewError(403, "Only the client can fund milestones")
+		}
+		if !milestonesActive(offer.Status) {
+			return fiber.NewError(400, "Milestones can only be funded on an active order")
+		}
+		if milestone.Status != models.MilestoneStatusPending {
+			return fiber.NewError(400, "Milestone is already funded")
+		}
+
+		desc := fmt.Sprintf("Pendanaan milestone %d pesanan #%s", milestone.Sequence, offer.OrderCode)
+		if err := h.WalletService.DebitClient(tx, offer.ClientID, milestone.Amount, offer.ID, desc); err != nil {
+			return err
+		}
+		if err := h.Ledger.RecordEscrowDeposit(tx, &offer, milestone.Amount, desc); err != nil {
+			return err
+		}
+
+		offer.DeferredFunded += milestone.Amount
+		if err := tx.Save(&offer).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		milestone.Status = models.MilestoneStatusFunded
+		milestone.FundedAt = &now
+		return tx.Save(&milestone).Error
+	})
+	if err != nil {
+		if errors.Is(err, wallet.ErrInsufficientBalance) {
+			return c.Status(400).JSON(fiber.Map{"success": false, "message": "Saldo tidak mencukupi, silakan isi saldo terlebih dahulu"})
+		}
+		if e, ok := err.(*fiber.Error); ok {
+			return c.Status(e.Code).JSON(fiber.Map{"success": false, "message": e.Message})
+		}
+		log.Printf("Failed to fund milestone: %v", err)
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to fund milestone"})
+	}
+
+	h.DB.Preload("Milestones", orderedMilestones).First(&offer, "id = ?", offer.ID)
+	text := fmt.Sprintf("Pembeli mendanai milestone %d (%s). Dana Rp %d ditahan di Escrow.", milestone.Sequence, milestone.Title, milestone.Amount)
+	h.postOrderMessage(&offer, userID, "system", text)
+
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"offer": toJobOfferResponse(&offer), "milestone": milestone}})
+}
+
+// DeliverMilestone handles the delivery of one milestone by the freelancer (multipart:
+// work_url and "files"). A delivered milestone can be delivered again as an update.
+func (h *JobOfferHandler) DeliverMilestone(c *fiber.Ctx) error {
+	userID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	workURL := c.FormValue("work_url")
+	filePaths, err := saveFormFiles(c, "files", "deliveries")
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": err.Error()})
+	}
+
+	var offer models.JobOffer
+	var milestone models.Milestone
+	var delivery *models.Delivery
+	isUpdate := false
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := h.lockMilestone(tx, c, &offer, &milestone); err != nil {
+			return err
+		}
+		if offer.FreelancerID != userID {
+			return fiber.NewError(403, "Only the assigned freelancer can deliver work")
+		}
+		if !milestonesActive(offer.Status) {
+			return fiber.NewError(400, "Milestones can only be delivered on an active order")
+		}
+		isUpdate = milestone.Status == models.MilestoneStatusDelivered
+		if !isUpdate && milestone.Status != models.MilestoneStatusFunded {
+			return fiber.NewError(400, "Only funded milestones can be delivered")
+		}
+
+		// The first delivery starts the work on the order
+		if offer.Status == models.OfferStatusPaid {
+			if err := offer.Transition(models.OfferStatusWorking, models.OfferActorFreelancer); err != nil {
+				return fiber.NewError(400, err.Error())
+			}
+			if err := tx.Save(&offer).Error; err != nil {
+				return err
+			}
+		}
+
+		var err error
+		delivery, err = recordDelivery(tx, &offer, &milestone, userID, c.FormValue("note"), workURL, filePaths)
+		if err != nil {
+			return err
+		}
+		milestone.Status = models.MilestoneStatusDelivered
+		milestone.DeliveredAt = &delivery.CreatedAt
+		return tx.Save(&milestone).Error
+	})
+	if err != nil {
+		if e, ok := err.(*fiber.Error); ok {
+			return c.Status(e.Code).JSON(fiber.Map{"success": false, "message": e.Message})
+		}
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to deliver milestone"})
+	}
+
+	h.DB.Preload("Milestones", orderedMilestones).First(&offer, "id = ?", offer.ID)
+	text := fmt.Sprintf("Freelancer mengirimkan milestone %d (%s), versi %d, untuk ditinjau dan disetujui.\n\nPembeli dapat meminta revisi. Jika tidak ada respon dalam 3 hari, sistem akan secara otomatis menyetujui milestone ini.", milestone.Sequence, milestone.Title, delivery.Version)
+	if isUpdate {
+		text = fmt.Sprintf("Freelancer telah memperbarui hasil milestone %d (%s), versi %d.\n\nSilakan tinjau kembali hasil pekerjaan terbaru yang telah dikirimkan.", milestone.Sequence, milestone.Title, delivery.Version)
+	}
+	h.postDeliveryMessage(&offer, userID, "delivery", text, &delivery.ID)
+
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"offer": toJobOfferResponse(&offer), "milestone": milestone, "delivery": delivery}})
+}
+
+// RequestMilestoneRevision sends a delivered milestone back to the freelancer, within the
+// milestone's revision limit. The reason can be sent as JSON or as a multipart form with
+// "attachments" files.
+func (h *JobOfferHandler) RequestMilestoneRevision(c *fiber.Ctx) error {
+	userID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var req struct {
+		Reason string `json:"reason" form:"reason"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.Reason == "" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Revision reason is required"})
+	}
+
+	attachments, err := saveFormFiles(c, "attachments", "revisions")
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": err.Error()})
+	}
+	attachmentsJSON, _ := json.Marshal(attachments)
+
+	var offer models.JobOffer
+	var milestone models.Milestone
+	var revision models.Revision
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := h.lockMilestone(tx, c, &offer, &milestone); err != nil {
+			return err
+		}
+		if offer.ClientID != userID {
+			return fiber.NewError(403, "Only the client can request revisions")
+		}
+		if !milestonesActive(offer.Status) || milestone.Status != models.MilestoneStatusDelivered {
+			return fiber.NewError(400, "Revision can only be requested for a delivered milestone")
+		}
+		if milestone.UsedRevisionCount >= milestone.RevisionCount {
+			return fiber.NewError(400, "Revision limit reached. No more revisions available for this milestone.")
+		}
+
+		now := time.Now()
+		milestone.Status = models.MilestoneStatusFunded
+		milestone.UsedRevisionCount++
+		if err := tx.Save(&milestone).Error; err != nil {
+			return err
+		}
+
+		revision = models.Revision{
+			JobOfferID:  offer.ID,
+			MilestoneID: &milestone.ID,
+			Number:      milestone.UsedRevisionCount,
+			RequestedBy: userID,
+			Reason:      req.Reason,
+			Attachments: string(attachmentsJSON),
+			Status:      models.RevisionStatusOpen,
+			RequestedAt: now,
+		}
+		return tx.Create(&revision).Error
+	})
+	if err != nil {
+		if e, ok := err.(*fiber.Error); ok {
+			return c.Status(e.Code).JSON(fiber.Map{"success": false, "message": e.Message})
+		}
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to request revision"})
+	}
+
+	h.DB.Preload("Milestones", orderedMilestones).First(&offer, "id = ?", offer.ID)
+	text := fmt.Sprintf("Revisi milestone %d (%s): %s", milestone.Sequence, milestone.Title, req.Reason)
+	h.postOrderMessage(&offer, userID, "revision", text)
+
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"offer": toJobOfferResponse(&offer), "milestone": milestone, "revision": revision}})
+}
+
+// CompleteMilestone lets the client approve a delivered milestone, releasing its share of the
+// escrow. Approving the last milestone completes the order.
+func (h *JobOfferHandler) CompleteMilestone(c *fiber.Ctx) error {
+	userID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var offer models.JobOffer
+	var milestone models.Milestone
+	orderCompleted := false
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := h.lockMilestone(tx, c, &offer, &milestone); err != nil {
+			return err
+		}
+		if offer.ClientID != userID {
+			return fiber.NewError(403, "Only the client can approve milestones")
+		}
+		if !milestonesActive(offer.Status) || milestone.Status != models.MilestoneStatusDelivered {
+			return fiber.NewError(400, "Only delivered milestones can be approved")
+		}
+
+		desc := fmt.Sprintf("Pembayaran milestone %d pesanan #%s", milestone.Sequence, offer.OrderCode)
+		var err error
+		orderCompleted, err = h.completeMilestone(tx, &offer, &milestone, desc)
+		return err
+	})
+	if err != nil {
+		if e, ok := err.(*fiber.Error); ok {
+			return c.Status(e.Code).JSON(fiber.Map{"success": false, "message": e.Message})
+		}
+		log.Printf("Failed to complete milestone: %v", err)
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to complete milestone: " + err.Error()})
+	}
+
+	h.DB.Preload("Milestones", orderedMilestones).First(&offer, "id = ?", offer.ID)
+	h.postOrderMessage(&offer, userID, "system", milestoneCompletedText(&milestone, orderCompleted, false))
+
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"offer": toJobOfferResponse(&offer), "milestone": milestone}})
+}
+
+// completeMilestone approves a delivered milestone inside the caller's DB transaction: the
+// share of the escrow covered so far goes to the freelancer's pending balance, and the order
+// completes with its last milestone. It reports whether the order completed.
+func (h *JobOfferHandler) completeMilestone(tx *gorm.DB, offer *models.JobOffer, m *models.Milestone, description string) (bool, error) {
+	now := time.Now()
+	m.Status = models.MilestoneStatusCompleted
+	m.CompletedAt = &now
+	if err := tx.Save(m).Error; err != nil {
+		return false, err
+	}
+
+	var completed, open int64
+	if err := tx.Model(&models.Milestone{}).
+		Where("job_offer_id = ? AND status = ?", offer.ID, models.MilestoneStatusCompleted).
+		Select("COALESCE(SUM(amount), 0)").Scan(&completed).Error; err != nil {
+		return false, err
+	}
+	if err := tx.Model(&models.Milestone{}).
+		Where("job_offer_id = ? AND status <> ?", offer.ID, models.MilestoneStatusCompleted).
+		Count(&open).Error; err != nil {
+		return false, err
+	}
+
+	if err := h.releaseEscrow(tx, offer, completed, description); err != nil {
+		return false, err
+	}
+	if open > 0 {
+		return false, nil
+	}
+
+	if err := offer.Transition(models.OfferStatusCompleted, models.OfferActorSystem); err != nil {
+		return false, err
+	}
+	return true, tx.Save(offer).Error
+}
+
+// scanAndCompleteMilestones approves milestones left without a response 3 days after delivery
+func (h *JobOfferHandler) scanAndCompleteMilestones() {
+	var due []models.Milestone
+	threeDaysAgo := time.Now().Add(-72 * time.Hour)
+	if err := h.DB.Where("status = ? AND delivered_at <= ?", models.MilestoneStatusDelivered, threeDaysAgo).
+		Find(&due).Error; err != nil {
+		log.Printf("[AutoCompletionWorker] Error fetching delivered milestones: %v", err)
+		return
+	}
+
+	for _, m := range due {
+		var offer models.JobOffer
+		var milestone models.Milestone
+		completed := false
+		orderCompleted := false
+		err := h.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&offer, "id = ?", m.JobOfferID).Error; err != nil {
+				return err
+			}
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&milestone, "id = ?", m.ID).Error; err != nil {
+				return err
+			}
+			// Idempotency: approved, revised or cancelled in the meantime
+			if !milestonesActive(offer.Status) || milestone.Status != models.MilestoneStatusDelivered {
+				return nil
+			}
+
+			desc := fmt.Sprintf("Penyelesaian otomatis milestone %d pesanan #%s (tanpa respon dari pembeli)", milestone.Sequence, offer.OrderCode)
+			var err error
+			orderCompleted, err = h.completeMilestone(tx, &offer, &milestone, desc)
+			completed = err == nil
+			return err
+		})
+		if err != nil {
+			log.Printf("[AutoCompletionWorker] Failed to auto-complete milestone %s of order %s: %v", m.ID, offer.OrderCode, err)
+			continue
+		}
+		if completed {
+			log.Printf("[AutoCompletionWorker] Auto-completed milestone %d of order %s", milestone.Sequence, offer.OrderCode)
+			h.DB.Preload("Milestones", orderedMilestones).First(&offer, "id = ?", offer.ID)
+			h.postOrderMessage(&offer, offer.FreelancerID, "system", milestoneCompletedText(&milestone, orderCompleted, true))
+		}
+	}
+}
+
+func milestoneCompletedText(m *models.Milestone, orderCompleted, auto bool) string {
+	text := fmt.Sprintf("Milestone %d (%s) telah disetujui oleh pembeli.", m.Sequence, m.Title)
+	if auto {
+		text = fmt.Sprintf("Milestone %d (%s) disetujui secara otomatis oleh sistem (3 hari setelah pengiriman tanpa respon).", m.Sequence, m.Title)
+	}
+	text += " Dana milestone diteruskan ke saldo tertunda Freelancer."
+	if orderCompleted {
+		text += " Semua milestone telah selesai, pesanan dinyatakan selesai. Terima kasih!"
+	}
+	return text
+}
+
+// lockMilestone loads and locks an order and one of its milestones from the route params
+func (h *JobOfferHandler) lockMilestone(tx *gorm.DB, c *fiber.Ctx, offer *models.JobOffer, milestone *models.Milestone) error {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(offer, "id = ?", c.Params("id")).Error; err != nil {
+		return fiber.NewError(404, "Offer not found")
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(milestone, "id = ? AND job_offer_id = ?", c.Params("milestoneId"), offer.ID).Error; err != nil {
+		return fiber.NewError(404, "Milestone not found")
+	}
+	return nil
+}
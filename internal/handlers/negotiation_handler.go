@@ -0,0 +1,368 @@
+package handlers
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/models"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Special markers of offer messages, followed by the ID of the offer or counter-offer.
+// The client renders them as cards instead of plain text.
+const (
+	offerMarker           = "[OFFER]"            // + offer ID
+	offerDeclinedMarker   = "[OFFER_DECLINED]"   // + offer ID
+	counterOfferMarker    = "[COUNTER_OFFER]"    // + counter-offer ID
+	counterAcceptedMarker = "[COUNTER_ACCEPTED]" // + counter-offer ID
+	counterRejectedMarker = "[COUNTER_REJECTED]" // + counter-offer ID
+)
+
+// postOfferMarker stores a marker message in the offer's conversation and broadcasts it with the offer
+func (h *JobOfferHandler) postOfferMarker(offer *models.JobOffer, senderID uuid.UUID, marker string, refID uuid.UUID) {
+	msg := models.Message{
+		ConversationID: offer.ConversationID,
+		SenderID:       senderID,
+		Type:           "offer",
+		Text:           marker + refID.String(),
+		IsRead:         false,
+	}
+	if err := h.DB.Create(&msg).Error; err != nil {
+		log.Printf("Failed to create %s message for offer %s: %v", marker, offer.ID, err)
+		return
+	}
+
+	h.DB.Model(&models.Conversation{}).
+		Where("id = ?", offer.ConversationID).
+		Update("last_message_at", msg.CreatedAt)
+
+	h.Hub.SendToConversation(offer.ClientID, offer.FreelancerID, fiber.Map{
+		"type": "new_message",
+		"message": MessageResponse{
+			ID:             msg.ID.String(),
+			ConversationID: msg.ConversationID.String(),
+			SenderID:       msg.SenderID.String(),
+			Type:           msg.Type,
+			Text:           msg.Text,
+			IsRead:         msg.IsRead,
+			CreatedAt:      msg.CreatedAt,
+		},
+		"offer": toJobOfferResponse(offer),
+	})
+
+	h.Hub.SendToConversation(offer.ClientID, offer.FreelancerID, fiber.Map{
+		"type":  "offer_status_update",
+		"offer": toJobOfferResponse(offer),
+	})
+}
+
+type DeclineOfferRequest struct {
+	Reason string `json:"reason"`
+}
+
+// DeclineOffer lets the client turn down a pending offer, with a reason for the freelancer
+func (h *JobOfferHandler) DeclineOffer(c *fiber.Ctx) error {
+	userID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var req DeclineOfferRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid request body"})
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Reason is required"})
+	}
+
+	var offer models.JobOffer
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&offer, "id = ?", c.Params("id")).Error; err != nil {
+			return fiber.NewError(404, "Offer not found")
+		}
+		if offer.ClientID != userID {
+			return fiber.NewError(403, "Only the client can decline this offer")
+		}
+		if err := offer.Transition(models.OfferStatusDeclined, models.OfferActorClient); err != nil {
+			return fiber.NewError(400, "Only pending offers can be declined")
+		}
+		offer.DeclineReason = req.Reason
+		if err := h.closeOfferPayments(tx, &offer, "Penawaran ditolak oleh pembeli"); err != nil {
+			return err
+		}
+		if err := cancelOpenMilestones(tx, offer.ID); err != nil {
+			return err
+		}
+		if err := cancelPendingCounters(tx, offer.ID); err != nil {
+			return err
+		}
+		return tx.Save(&offer).Error
+	})
+	if err != nil {
+		if e, ok := err.(*fiber.Error); ok {
+			return c.Status(e.Code).JSON(fiber.Map{"success": false, "message": e.Message})
+		}
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to decline offer"})
+	}
+
+	h.postOfferMarker(&offer, userID, offerDeclinedMarker, offer.ID)
+	h.notify(offer.FreelancerID, map[string]interface{}{
+		"type":       "offer_declined",
+		"offer_id":   offer.ID.String(),
+		"order_code": offer.OrderCode,
+	})
+
+	return c.JSON(fiber.Map{"success": true, "data": toJobOfferResponse(&offer)})
+}
+
+type CounterOfferRequest struct {
+	Price         int64  `json:"price"`
+	DeliveryDate  string `json:"delivery_date"`  // ISO format: 2026-01-05 (Asia/Jakarta); empty keeps the offer's date
+	RevisionCount *int   `json:"revision_count"` // Omitted keeps the offer's revision count
+	Note          string `json:"note"`
+}
+
+// CreateCounterOffer lets the client propose a different price, delivery date or revision count
+// for a pending offer. The freelancer answers it in chat.
+func (h *JobOfferHandler) CreateCounterOffer(c *fiber.Ctx) error {
+	userID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var req CounterOfferRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid request body"})
+	}
+	if req.Price <= 0 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Price is required and must be positive"})
+	}
+	if req.RevisionCount != nil && *req.RevisionCount < 0 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Revision count cannot be negative"})
+	}
+
+	var offer models.JobOffer
+	var counter models.CounterOffer
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&offer, "id = ?", c.Params("id")).Error; err != nil {
+			return fiber.NewError(404, "Offer not found")
+		}
+		if offer.ClientID != userID {
+			return fiber.NewError(403, "Only the client can send a counter-offer")
+		}
+		if offer.Status != models.OfferStatusPending || offer.IsExpired(time.Now()) {
+			return fiber.NewError(400, "Counter-offers can only be sent on pending offers")
+		}
+		if offer.HasMilestones() {
+			return fiber.NewError(400, "Counter-offers are not available on milestone offers")
+		}
+
+		counter = models.CounterOffer{
+			JobOfferID:    offer.ID,
+			ProposedBy:    userID,
+			Price:         req.Price,
+			DeliveryDate:  offer.DeliveryDate,
+			RevisionCount: offer.RevisionCount,
+			Note:          req.Note,
+			Status:        models.CounterOfferPending,
+		}
+		if req.DeliveryDate != "" {
+			date, err := utils.ParseDate(req.DeliveryDate)
+			if err != nil {
+				return fiber.NewError(400, "Invalid delivery date (YYYY-MM-DD)")
+			}
+			if date.Before(utils.StartOfDay(time.Now())) || date.Before(offer.StartDate) {
+				return fiber.NewError(400, "Delivery date cannot be before the start date or in the past")
+			}
+			counter.DeliveryDate = date
+		}
+		if req.RevisionCount != nil {
+			counter.RevisionCount = *req.RevisionCount
+		}
+
+		var pending int64
+		tx.Model(&models.CounterOffer{}).
+			Where("job_offer_id = ? AND status = ?", offer.ID, models.CounterOfferPending).Count(&pending)
+		if pending > 0 {
+			return fiber.NewError(400, "A counter-offer is already waiting for the freelancer")
+		}
+		return tx.Create(&counter).Error
+	})
+	if err != nil {
+		if e, ok := err.(*fiber.Error); ok {
+			return c.Status(e.Code).JSON(fiber.Map{"success": false, "message": e.Message})
+		}
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to send counter-offer"})
+	}
+
+	h.postOfferMarker(&offer, userID, counterOfferMarker, counter.ID)
+	h.notify(offer.FreelancerID, map[string]interface{}{
+		"type":       "counter_offer",
+		"offer_id":   offer.ID.String(),
+		"counter_id": counter.ID.String(),
+		"price":      counter.Price,
+	})
+
+	return c.Status(201).JSON(fiber.Map{"success": true, "data": counter})
+}
+
+// ListCounterOffers returns the counter-offers sent on an offer, newest first
+func (h *JobOfferHandler) ListCounterOffers(c *fiber.Ctx) error {
+	offer, err := h.loadOfferForParty(c)
+	if err != nil {
+		if e, ok := err.(*fiber.Error); ok {
+			return c.Status(e.Code).JSON(fiber.Map{"success": false, "message": e.Message})
+		}
+		return err
+	}
+
+	var counters []models.CounterOffer
+	if err := h.DB.Where("job_offer_id = ?", offer.ID).Order("created_at DESC").Find(&counters).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to fetch counter-offers"})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": counters})
+}
+
+// AcceptCounterOffer creates a new version of the offer with the counter-offer's terms; it
+// supersedes the original, which can no longer be paid
+func (h *JobOfferHandler) AcceptCounterOffer(c *fiber.Ctx) error {
+	return h.answerCounterOffer(c, true)
+}
+
+// RejectCounterOffer keeps the offer as it is. Optional body: {"reason": "..."}
+func (h *JobOfferHandler) RejectCounterOffer(c *fiber.Ctx) error {
+	return h.answerCounterOffer(c, false)
+}
+
+func (h *JobOfferHandler) answerCounterOffer(c *fiber.Ctx, accept bool) error {
+	userID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var req DeclineOfferRequest
+	_ = c.BodyParser(&req)
+
+	var offer, newOffer models.JobOffer
+	var counter models.CounterOffer
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&offer, "id = ?", c.Params("id")).Error; err != nil {
+			return fiber.NewError(404, "Offer not found")
+		}
+		if offer.FreelancerID != userID {
+			return fiber.NewError(403, "Only the freelancer can answer counter-offers")
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&counter, "id = ? AND job_offer_id = ?", c.Params("counterId"), offer.ID).Error; err != nil {
+			return fiber.NewError(404, "Counter-offer not found")
+		}
+		if counter.Status != models.CounterOfferPending {
+			return fiber.NewError(400, "Counter-offer was already answered")
+		}
+
+		now := time.Now()
+		counter.Status = models.CounterOfferRejected
+		counter.ResponseNote = strings.TrimSpace(req.Reason)
+		counter.RespondedAt = &now
+		if accept {
+			if offer.Status != models.OfferStatusPending || offer.IsExpired(now) {
+				return fiber.NewError(400, "The offer can no longer be changed")
+			}
+			if err := h.createOfferVersion(tx, &offer, &counter, &newOffer); err != nil {
+				return err
+			}
+			counter.Status = models.CounterOfferAccepted
+			counter.ResponseNote = ""
+			counter.NewOfferID = &newOffer.ID
+		}
+		return tx.Save(&counter).Error
+	})
+	if err != nil {
+		if e, ok := err.(*fiber.Error); ok {
+			return c.Status(e.Code).JSON(fiber.Map{"success": false, "message": e.Message})
+		}
+		log.Printf("Failed to answer counter-offer: %v", err)
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to answer counter-offer"})
+	}
+
+	h.notify(offer.ClientID, map[string]interface{}{
+		"type":       "counter_offer_" + string(counter.Status),
+		"offer_id":   offer.ID.String(),
+		"counter_id": counter.ID.String(),
+	})
+	if !accept {
+		h.postOfferMarker(&offer, userID, counterRejectedMarker, counter.ID)
+		return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"offer": toJobOfferResponse(&offer), "counter_offer": counter}})
+	}
+
+	h.postOfferMarker(&offer, userID, counterAcceptedMarker, counter.ID)
+	h.DB.Preload("Freelancer").Preload("Freelancer.FreelancerProfile").
+		Preload("Client").Preload("Product").
+		First(&newOffer, "id = ?", newOffer.ID)
+	h.postOfferMarker(&newOffer, userID, offerMarker, newOffer.ID)
+
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{
+		"offer":         toJobOfferResponse(&newOffer),
+		"previous":      toJobOfferResponse(&offer),
+		"counter_offer": counter,
+	}})
+}
+
+// createOfferVersion copies a pending offer with the terms of an accepted counter-offer and
+// marks the original as superseded
+func (h *JobOfferHandler) createOfferVersion(tx *gorm.DB, offer *models.JobOffer, counter *models.CounterOffer, newOffer *models.JobOffer) error {
+	expiresAt, err := h.offerExpiry(0)
+	if err != nil {
+		return err
+	}
+
+	*newOffer = models.JobOffer{
+		OrderCode:       uniqueOrderCode(tx),
+		ConversationID:  offer.ConversationID,
+		FreelancerID:    offer.FreelancerID,
+		ClientID:        offer.ClientID,
+		ProductID:       offer.ProductID,
+		Price:           counter.Price,
+		Title:           offer.Title,
+		Description:     offer.Description,
+		RevisionCount:   counter.RevisionCount,
+		StartDate:       offer.StartDate,
+		DeliveryDate:    counter.DeliveryDate,
+		DeliveryFormat:  offer.DeliveryFormat,
+		Notes:           offer.Notes,
+		Status:          models.OfferStatusPending,
+		ExpiresAt:       expiresAt,
+		Version:         offer.Version + 1,
+		PreviousOfferID: &offer.ID,
+	}
+	quote, err := h.Commission.QuoteOffer(newOffer)
+	if err != nil {
+		return err
+	}
+	quote.Apply(newOffer)
+	if err := tx.Create(newOffer).Error; err != nil {
+		return err
+	}
+
+	if err := offer.Transition(models.OfferStatusSuperseded, models.OfferActorFreelancer); err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+	offer.SupersededByID = &newOffer.ID
+	if err := h.closeOfferPayments(tx, offer, "Penawaran digantikan versi baru #"+newOffer.OrderCode); err != nil {
+		return err
+	}
+	return tx.Save(offer).Error
+}
+
+// cancelPendingCounters closes the unanswered counter-offers of an offer that changed or closed
+func cancelPendingCounters(tx *gorm.DB, offerID uuid.UUID) error {
+	return tx.Model(&models.CounterOffer{}).
+		Where("job_offer_id = ? AND status = ?", offerID, models.CounterOfferPending).
+		Update("status", models.CounterOfferCancelled).Error
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const maxOfferValidDays = 30
+
+// offerExpiry is the end of the validity period of an offer created (or edited) now
+func (h *JobOfferHandler) offerExpiry(validDays int) (*time.Time, error) {
+	if validDays < 0 || validDays > maxOfferValidDays {
+		return nil, fmt.Errorf("valid_days must be between 1 and %d", maxOfferValidDays)
+	}
+	validity := h.OfferValidity
+	if validDays > 0 {
+		validity = time.Duration(validDays) * 24 * time.Hour
+	}
+	if validity <= 0 {
+		return nil, nil // No platform default: offers stay open until withdrawn
+	}
+	expiresAt := time.Now().Add(validity)
+	return &expiresAt, nil
+}
+
+// StartOfferExpiryWorker periodically expires pending offers the client did not pay within their
+// validity period
+func (h *JobOfferHandler) StartOfferExpiryWorker(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			h.expireStaleOffers()
+		}
+	}()
+}
+
+func (h *JobOfferHandler) expireStaleOffers() {
+	now := time.Now()
+	var offers []models.JobOffer
+	if err := h.DB.Where("status = ? AND expires_at <= ?", models.OfferStatusPending, now).Find(&offers).Error; err != nil {
+		log.Printf("[OfferExpiryWorker] Error fetching stale offers: %v", err)
+		return
+	}
+
+	for _, o := range offers {
+		var offer models.JobOffer
+		expired := false
+		err := h.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&offer, "id = ?", o.ID).Error; err != nil {
+				return err
+			}
+			// Idempotency: paid, withdrawn or edited (new validity) in the meantime
+			if !offer.IsExpired(now) {
+				return nil
+			}
+			if err := offer.Transition(models.OfferStatusExpired, models.OfferActorSystem); err != nil {
+				return err
+			}
+			if err := h.closeOfferPayments(tx, &offer, "Penawaran kedaluwarsa"); err != nil {
+				return err
+			}
+			if err := cancelOpenMilestones(tx, offer.ID); err != nil {
+				return err
+			}
+			if err := cancelPendingCounters(tx, offer.ID); err != nil {
+				return err
+			}
+			expired = true
+			return tx.Save(&offer).Error
+		})
+		if err != nil {
+			log.Printf("[OfferExpiryWorker] Failed to expire offer %s: %v", o.OrderCode, err)
+			continue
+		}
+		if !expired {
+			continue
+		}
+
+		log.Printf("[OfferExpiryWorker] Offer %s expired unpaid", offer.OrderCode)
+		text := "Penawaran #" + offer.OrderCode + " telah kedaluwarsa karena belum dibayar dalam masa berlaku penawaran.\n\nFreelancer dapat mengirimkan penawaran baru jika pekerjaan masih ingin dilanjutkan."
+		h.postOrderMessage(&offer, offer.FreelancerID, "system", text)
+		for _, userID := range []uuid.UUID{offer.ClientID, offer.FreelancerID} {
+			h.notify(userID, map[string]interface{}{
+				"type":       "offer_expired",
+				"offer_id":   offer.ID.String(),
+				"order_code": offer.OrderCode,
+			})
+		}
+	}
+}
+
+// closeOfferPayments closes the open payment attempts of an offer that can no longer be paid
+func (h *JobOfferHandler) closeOfferPayments(tx *gorm.DB, offer *models.JobOffer, note string) error {
+	return closeOpenAttempts(tx, h.WalletService, h.Ledger, h.Vouchers, offer, models.TransactionStatusExpired,
+		note, "Pengembalian saldo, penawaran #"+offer.OrderCode+" ditutup")
+}
+
+// WithdrawOffer lets the freelancer take back a pending offer before the client pays it
+func (h *JobOfferHandler) WithdrawOffer(c *fiber.Ctx) error {
+	userID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var offer models.JobOffer
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&offer, "id = ?", c.Params("id")).Error; err != nil {
+			return fiber.NewError(404, "Offer not found")
+		}
+		if offer.FreelancerID != userID {
+			return fiber.NewError(403, "Only the freelancer can withdraw this offer")
+		}
+		if offer.Status != models.OfferStatusPending {
+			return fiber.NewError(400, "Only pending offers can be withdrawn")
+		}
+		if err := offer.Transition(models.OfferStatusCancelled, models.OfferActorFreelancer); err != nil {
+			return fiber.NewError(400, err.Error())
+		}
+		if err := h.closeOfferPayments(tx, &offer, "Penawaran ditarik oleh freelancer"); err != nil {
+			return err
+		}
+		if err := cancelOpenMilestones(tx, offer.ID); err != nil {
+			return err
+		}
+		if err := cancelPendingCounters(tx, offer.ID); err != nil {
+			return err
+		}
+		return tx.Save(&offer).Error
+	})
+	if err != nil {
+		if e, ok := err.(*fiber.Error); ok {
+			return c.Status(e.Code).JSON(fiber.Map{"success": false, "message": e.Message})
+		}
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to withdraw offer"})
+	}
+
+	h.postOrderMessage(&offer, userID, "system", "Penawaran #"+offer.OrderCode+" telah ditarik oleh freelancer dan tidak dapat dibayar lagi.")
+
+	return c.JSON(fiber.Map{"success": true, "data": toJobOfferResponse(&offer)})
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"time"
+
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/models"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/gateway"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrCallbackNotReplayable = errors.New("callback with an invalid signature cannot be replayed")
+
+// processCallback parses a journaled callback body and applies it, recording the outcome
+// on the journal row. Used for live callbacks and for replays.
+func (h *PaymentHandler) processCallback(record *models.PaymentCallback) (paymentOutcome, error) {
+	var payload gateway.CallbackPayload
+	if err := json.Unmarshal([]byte(record.RawBody), &payload); err != nil {
+		record.Outcome = models.CallbackOutcomeInvalidPayload
+		record.Error = err.Error()
+		h.saveCallbackRecord(record)
+		return paymentOutcome{}, err
+	}
+	record.Reference = payload.Reference
+	record.MerchantRef = payload.MerchantRef
+	record.Status = payload.Status
+
+	outcome, err := h.applyPaymentStatus(&payload)
+	switch {
+	case err != nil:
+		record.Outcome = models.CallbackOutcomeError
+		record.Error = err.Error()
+	case outcome.Applied:
+		record.Outcome = models.CallbackOutcomeApplied
+		record.Event = string(outcome.Event)
+	default:
+		record.Outcome = models.CallbackOutcomeIgnored
+	}
+	h.saveCallbackRecord(record)
+	return outcome, err
+}
+
+// saveCallbackRecord stores the journal row; a journal failure must never fail the callback itself
+func (h *PaymentHandler) saveCallbackRecord(record *models.PaymentCallback) {
+	now := time.Now()
+	record.ProcessedAt = &now
+	if err := h.DB.Save(record).Error; err != nil {
+		log.Printf("Failed to journal payment callback %s: %v", record.Reference, err)
+	}
+}
+
+// ReplayStoredCallback runs a journaled callback through the processing code again (e.g. after
+// a bug fix). The replay is journaled as a new row; idempotency still applies, so a callback
+// that was already applied is ignored.
+func (h *PaymentHandler) ReplayStoredCallback(id uuid.UUID) (*models.PaymentCallback, error) {
+	var original models.PaymentCallback
+	if err := h.DB.First(&original, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	if !original.SignatureValid {
+		return nil, ErrCallbackNotReplayable
+	}
+
+	replay := models.PaymentCallback{
+		ID:             uuid.New(),
+		Gateway:        original.Gateway,
+		Headers:        original.Headers,
+		RawBody:        original.RawBody,
+		Signature:      original.Signature,
+		SignatureValid: original.SignatureValid,
+		ReplayOf:       &original.ID,
+	}
+
+	outcome, err := h.processCallback(&replay)
+	if err == nil {
+		h.notifyPaymentEvent(outcome)
+	}
+	return &replay, err
+}
+
+// ListCallbacks returns journaled callbacks, newest first
+func (h *PaymentHandler) ListCallbacks(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 20)
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 20
+	}
+	offset := (page - 1) * limit
+
+	q := h.DB.Model(&models.PaymentCallback{})
+	if reference := c.Query("reference"); reference != "" {
+		q = q.Where("reference = ?", reference)
+	}
+	if merchantRef := c.Query("merchant_ref"); merchantRef != "" {
+		q = q.Where("merchant_ref = ?", merchantRef)
+	}
+	if outcome := c.Query("outcome"); outcome != "" {
+		q = q.Where("outcome = ?", outcome)
+	}
+
+	var total int64
+	q.Count(&total)
+
+	var callbacks []models.PaymentCallback
+	if err := q.Order("created_at DESC").Limit(limit).Offset(offset).Find(&callbacks).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to fetch payment callbacks"})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    callbacks,
+		"meta": fiber.Map{
+			"page":        page,
+			"limit":       limit,
+			"total_items": total,
+			"total_pages": int(math.Ceil(float64(total) / float64(limit))),
+		},
+	})
+}
+
+// GetCallback returns one journaled callback with its replays
+func (h *PaymentHandler) GetCallback(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid callback ID"})
+	}
+
+	var callback models.PaymentCallback
+	if err := h.DB.First(&callback, "id = ?", id).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "message": "Payment callback not found"})
+	}
+
+	var replays []models.PaymentCallback
+	h.DB.Where("replay_of = ?", id).Order("created_at ASC").Find(&replays)
+
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"callback": callback, "replays": replays}})
+}
+
+// ReplayCallback lets an admin replay a journaled callback
+func (h *PaymentHandler) ReplayCallback(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid callback ID"})
+	}
+
+	replay, err := h.ReplayStoredCallback(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return c.Status(404).JSON(fiber.Map{"success": false, "message": "Payment callback not found"})
+		case errors.Is(err, ErrCallbackNotReplayable):
+			return c.Status(400).JSON(fiber.Map{"success": false, "message": err.Error()})
+		}
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Replay failed: " + err.Error(), "data": replay})
+	}
+
+	return c.JSON(fiber.Map{"success": true, "data": replay})
+}
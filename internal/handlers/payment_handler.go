@@ -1,41 +1,55 @@
 package handlers
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
-	"math"
 	"os"
-	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/models"
 	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/realtime"
-	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/tripay"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/clearance"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/gateway"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/invoice"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/ledger"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/voucher"
 	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/wallet"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type PaymentHandler struct {
 	DB            *gorm.DB
-	TripayService *tripay.TripayService
+	Gateway       gateway.PaymentGateway
 	Hub           *realtime.Hub
 	WalletService *wallet.WalletService
+	Ledger        *ledger.LedgerService
+	Invoices      *invoice.InvoiceService
+	Vouchers      *voucher.VoucherService
+	Clearance     *clearance.ClearanceService
 }
 
-func NewPaymentHandler(db *gorm.DB, tripayService *tripay.TripayService, hub *realtime.Hub, walletService *wallet.WalletService) *PaymentHandler {
-	return &PaymentHandler{DB: db, TripayService: tripayService, Hub: hub, WalletService: walletService}
+func NewPaymentHandler(db *gorm.DB, paymentGateway gateway.PaymentGateway, hub *realtime.Hub, walletService *wallet.WalletService, ledgerService *ledger.LedgerService, invoiceService *invoice.InvoiceService, voucherService *voucher.VoucherService, clearanceService *clearance.ClearanceService) *PaymentHandler {
+	return &PaymentHandler{DB: db, Gateway: paymentGateway, Hub: hub, WalletService: walletService, Ledger: ledgerService, Invoices: invoiceService, Vouchers: voucherService, Clearance: clearanceService}
 }
 
+// paymentExpiry is how long a gateway checkout stays payable
+const paymentExpiry = 24 * time.Hour
+
 type CreatePaymentRequest struct {
 	OfferID       string `json:"offer_id"`
-	PaymentMethod string `json:"payment_method"`
+	PaymentMethod string `json:"payment_method"` // Gateway channel code or "BALANCE"
+	UseBalance    bool   `json:"use_balance"`    // Split payment: wallet balance first, gateway for the remainder
+	VoucherCode   string `json:"voucher_code"`
 }
 
 func (h *PaymentHandler) GetChannels(c *fiber.Ctx) error {
-	channels, err := h.TripayService.GetPaymentChannels()
+	channels, err := h.Gateway.GetPaymentChannels()
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to fetch channels: " + err.Error()})
 	}
@@ -68,194 +82,518 @@ func (h *PaymentHandler) CreatePayment(c *fiber.Ctx) error {
 		return c.Status(403).JSON(fiber.Map{"success": false, "message": "Only client can pay for this offer"})
 	}
 
+	session, err := h.checkout(&offer, req)
+	if err != nil {
+		return paymentErrorResponse(c, err)
+	}
+	return c.JSON(fiber.Map{"success": true, "data": session})
+}
+
+// checkout opens a payment session for a pending offer (Client preloaded): a gateway checkout,
+// or a direct settlement when the wallet balance or the voucher covers the whole amount
+func (h *PaymentHandler) checkout(offer *models.JobOffer, req CreatePaymentRequest) (fiber.Map, error) {
 	// Validate Offer Status
 	if offer.Status != models.OfferStatusPending {
-		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Offer is not in pending status"})
+		return nil, fiber.NewError(400, "Offer is not in pending status")
+	}
+	if offer.IsExpired(time.Now()) {
+		return nil, fiber.NewError(400, "Offer has expired")
 	}
 
-	// Init Tripay Transaction
-	// Using "OFFER-{OrderCode}" ensures uniqueness and reference
-	merchantRef := "INV-" + offer.OrderCode
-
-	// Ensure client data exists
-	clientName := offer.Client.Name
-	clientEmail := offer.Client.Email
-	clientPhone := "08123456789" // Placeholder if phone not in User model, ideally should be fetched
-
-	// Fetch Channels to calculate correct fee
-	channels, err := h.TripayService.GetPaymentChannels()
+	// Every attempt gets its own merchant ref "INV-{OrderCode}-{n}", so an expired or
+	// failed payment can be retried.
+	attempts, err := h.loadAttempts(offer)
 	if err != nil {
-		log.Printf("Failed to fetch channels for fee calculation: %v", err)
-		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to calculate fees"})
+		return nil, fiber.NewError(500, "Failed to load payment attempts")
 	}
+	if attempts.Paid {
+		return nil, fiber.NewError(400, "Offer is already paid")
+	}
+	merchantRef := fmt.Sprintf("INV-%s-%d", offer.OrderCode, attempts.Count+1)
 
-	var selectedChannel tripay.PaymentChannel
-	var channelFound bool
-	for _, ch := range channels {
-		if ch.Code == req.PaymentMethod {
-			selectedChannel = ch
-			channelFound = true
-			break
+	var discount *voucher.Application
+	if req.VoucherCode != "" {
+		discount, err = h.Vouchers.Validate(req.VoucherCode, offer, offer.ClientID)
+		if err != nil {
+			return nil, err
 		}
 	}
+	payable := offer.CheckoutAmount() - discountAmount(discount)
 
-	if !channelFound {
-		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid payment method"})
+	if req.PaymentMethod == models.PaymentMethodBalance || payable == 0 {
+		return h.payWithBalance(offer, merchantRef, discount)
 	}
 
-	// Calculate Fee
-	var flatFee float64
-	var percentFee float64
-
-	// Helper to safely parse interface{} to float64
-	toFloat := func(v interface{}) float64 {
-		switch val := v.(type) {
-		case float64:
-			return val
-		case int:
-			return float64(val)
-		case string:
-			if f, err := strconv.ParseFloat(val, 64); err == nil {
-				return f
-			}
+	// Split payment: the wallet covers what it can, the gateway charges the remainder
+	var balanceUsed int64
+	if req.UseBalance {
+		balanceUsed = attempts.usableBalance(offer, payable)
+		if balanceUsed == payable {
+			return h.payWithBalance(offer, merchantRef, discount)
 		}
-		return 0
 	}
+	chargeAmount := payable - balanceUsed
 
-	flatFee = toFloat(selectedChannel.Fee.Flat)
-	percentFee = toFloat(selectedChannel.Fee.Percent)
+	// Ensure client data exists
+	clientName := offer.Client.Name
+	clientEmail := offer.Client.Email
+	clientPhone := "08123456789" // Placeholder if phone not in User model, ideally should be fetched
+
+	// Fetch Channels to calculate correct fee
+	selectedChannel, err := h.activeChannel(req.PaymentMethod)
+	if err != nil {
+		return nil, err
+	}
 
-	totalFee := flatFee + (float64(offer.Price) * percentFee / 100)
-	totalAmount := offer.Price + int64(math.Ceil(totalFee))
+	// Calculate Fee
+	fee := selectedChannel.CustomerFee(chargeAmount)
+	totalAmount := chargeAmount + fee
 
 	frontendURL := os.Getenv("FRONTEND_URL")
 	if frontendURL == "" {
 		frontendURL = "http://127.0.0.1:3000"
 	}
 	returnUrl := fmt.Sprintf("%s/chat?cid=%s", frontendURL, offer.ConversationID.String())
-
-	resp, err := h.TripayService.CreateTransaction(
-		merchantRef,
-		totalAmount,
-		clientName,
-		clientEmail,
-		clientPhone,
-		offer.Title,
-		req.PaymentMethod,
-		returnUrl,
-	)
+	expiredAt := time.Now().Add(paymentExpiry)
+
+	resp, err := h.Gateway.CreateTransaction(gateway.CreateTransactionRequest{
+		MerchantRef:   merchantRef,
+		Method:        req.PaymentMethod,
+		Amount:        totalAmount,
+		CustomerFee:   fee,
+		CustomerName:  clientName,
+		CustomerEmail: clientEmail,
+		CustomerPhone: clientPhone,
+		ItemName:      offer.Title,
+		ReturnURL:     returnUrl,
+		ExpiredAt:     expiredAt,
+	})
 
 	if err != nil {
-		log.Printf("Tripay error: %v", err)
-		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Payment gateway error: " + err.Error()})
+		log.Printf("%s error: %v", h.Gateway.Name(), err)
+		return nil, fiber.NewError(500, "Payment gateway error: "+err.Error())
 	}
 
-	// Create or Update Transaction Record
-	var trx models.Transaction
-	err = h.DB.Where("merchant_ref = ?", merchantRef).First(&trx).Error
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			// New Transaction
-			trx = models.Transaction{
-				JobOfferID:        offer.ID,
-				Reference:         resp.Data.Reference,
-				MerchantRef:       resp.Data.MerchantRef,
-				TotalAmount:       resp.Data.Amount,
-				PaymentMethodCode: req.PaymentMethod,
-				PaymentMethod:     req.PaymentMethod,
-				CheckoutURL:       resp.Data.CheckoutURL,
-				Status:            models.TransactionStatusUnpaid,
-				FeeCustomer:       int64(math.Ceil(totalFee)),
-				TotalFee:          int64(math.Ceil(totalFee)),
+	// Create the Transaction Record (and move the balance hold) atomically
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := h.supersedeOpenAttempts(tx, offer); err != nil {
+			return err
+		}
+
+		if balanceUsed > 0 {
+			desc := "Pembayaran sebagian pesanan #" + offer.OrderCode + " menggunakan saldo"
+			if err := h.WalletService.DebitClient(tx, offer.ClientID, balanceUsed, offer.ID, desc); err != nil {
+				return err
 			}
-			if err := h.DB.Create(&trx).Error; err != nil {
-				log.Printf("Failed to create transaction: %v", err)
+			if err := h.Ledger.RecordPaymentHold(tx, offer, balanceUsed); err != nil {
+				return err
 			}
-		} else {
-			log.Printf("DB error checking existing transaction: %v", err)
 		}
-	} else {
-		// Update existing UNPAID/FAILED transaction with new Tripay reference
-		if trx.Status != models.TransactionStatusPaid {
-			trx.Reference = resp.Data.Reference
-			trx.CheckoutURL = resp.Data.CheckoutURL
-			trx.Status = models.TransactionStatusUnpaid
-			trx.TotalAmount = resp.Data.Amount
-			trx.PaymentMethodCode = req.PaymentMethod
-			trx.PaymentMethod = req.PaymentMethod
-			trx.FeeCustomer = int64(math.Ceil(totalFee))
-			trx.TotalFee = int64(math.Ceil(totalFee))
-			if err := h.DB.Save(&trx).Error; err != nil {
-				log.Printf("Failed to update transaction: %v", err)
+
+		trx := models.Transaction{
+			Purpose:           models.TransactionPurposeOrder,
+			JobOfferID:        &offer.ID,
+			Reference:         resp.Reference,
+			MerchantRef:       resp.MerchantRef,
+			CheckoutURL:       resp.CheckoutURL,
+			Status:            models.TransactionStatusUnpaid,
+			TotalAmount:       resp.Amount,
+			PaymentMethodCode: req.PaymentMethod,
+			PaymentMethod:     req.PaymentMethod,
+			FeeCustomer:       fee,
+			TotalFee:          fee,
+			BalanceAmount:     balanceUsed,
+			ExpiredAt:         &expiredAt,
+		}
+		if discount != nil {
+			if err := h.Vouchers.Redeem(tx, discount, offer, &trx); err != nil {
+				return err
 			}
 		}
+		return tx.Create(&trx).Error
+	})
+	if err != nil {
+		return nil, checkoutError(err, "Failed to save transaction")
 	}
 
-	return c.JSON(fiber.Map{
-		"success": true,
-		"data": fiber.Map{
-			"checkout_url": resp.Data.CheckoutURL,
-			"reference":    resp.Data.Reference,
-		},
+	return fiber.Map{
+		"checkout_url": resp.CheckoutURL,
+		"reference":    resp.Reference,
+		"discount":     discountAmount(discount),
+		"balance_used": balanceUsed,
+		"paid":         false,
+	}, nil
+}
+
+// payWithBalance settles the checkout amount (minus the voucher discount) from the client's
+// wallet balance. An order fully covered by a voucher is settled the same way, for free.
+func (h *PaymentHandler) payWithBalance(offer *models.JobOffer, merchantRef string, discount *voucher.Application) (fiber.Map, error) {
+	payable := offer.CheckoutAmount() - discountAmount(discount)
+
+	var trx models.Transaction
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := h.supersedeOpenAttempts(tx, offer); err != nil {
+			return err
+		}
+
+		if payable > 0 {
+			desc := "Pembayaran pesanan #" + offer.OrderCode + " menggunakan saldo"
+			if err := h.WalletService.DebitClient(tx, offer.ClientID, payable, offer.ID, desc); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		trx = models.Transaction{
+			Purpose:           models.TransactionPurposeOrder,
+			JobOfferID:        &offer.ID,
+			Reference:         "BAL-" + strings.TrimPrefix(merchantRef, "INV-"),
+			MerchantRef:       merchantRef,
+			Status:            models.TransactionStatusPaid,
+			PaymentMethod:     models.PaymentMethodBalance,
+			PaymentMethodCode: models.PaymentMethodBalance,
+			TotalAmount:       payable,
+			AmountReceived:    payable,
+			BalanceAmount:     payable,
+			PaidAt:            &now,
+		}
+		if discount != nil {
+			if err := h.Vouchers.Redeem(tx, discount, offer, &trx); err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(&trx).Error; err != nil {
+			return err
+		}
+
+		paid, err := h.markOfferPaid(tx, &trx)
+		if err != nil {
+			return err
+		}
+		if !paid {
+			return fiber.NewError(400, "Offer is not in pending status")
+		}
+		if err := h.Vouchers.Confirm(tx, &trx); err != nil {
+			return err
+		}
+		if err := h.Ledger.RecordOrderPayment(tx, &trx, offer, false); err != nil {
+			return err
+		}
+		_, err = h.Invoices.Issue(tx, &trx, offer)
+		return err
 	})
+	if err != nil {
+		return nil, checkoutError(err, "Failed to process balance payment")
+	}
+
+	h.notifyPaymentEvent(paymentOutcome{Event: paymentEventPaid, OfferID: offer.ID})
+
+	return fiber.Map{
+		"checkout_url": "",
+		"reference":    trx.Reference,
+		"discount":     trx.DiscountAmount,
+		"balance_used": payable,
+		"paid":         true,
+	}, nil
+}
+
+// activeChannel returns the gateway channel of a payment method, if it can take payments now
+func (h *PaymentHandler) activeChannel(code string) (gateway.PaymentChannel, error) {
+	channels, err := h.Gateway.GetPaymentChannels()
+	if err != nil {
+		log.Printf("Failed to fetch channels for fee calculation: %v", err)
+		return gateway.PaymentChannel{}, fiber.NewError(500, "Failed to calculate fees")
+	}
+	for _, ch := range channels {
+		if ch.Code == code && ch.Active {
+			return ch, nil
+		}
+	}
+	return gateway.PaymentChannel{}, fiber.NewError(400, "Invalid payment method")
+}
+
+// checkoutError keeps the errors a client can act on (insufficient balance, invalid voucher,
+// *fiber.Error) and turns anything else into a 500 with the given message
+func checkoutError(err error, message string) error {
+	var fe *fiber.Error
+	if errors.Is(err, wallet.ErrInsufficientBalance) || errors.Is(err, voucher.ErrInvalidVoucher) || errors.As(err, &fe) {
+		return err
+	}
+	log.Printf("%s: %v", message, err)
+	return fiber.NewError(500, message)
+}
+
+// paymentErrorResponse answers a failed checkout
+func paymentErrorResponse(c *fiber.Ctx, err error) error {
+	if errors.Is(err, wallet.ErrInsufficientBalance) {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Saldo tidak mencukupi"})
+	}
+	if e, ok := err.(*fiber.Error); ok {
+		return c.Status(e.Code).JSON(fiber.Map{"success": false, "message": e.Message})
+	}
+	return voucherErrorResponse(c, err)
+}
+
+// releaseBalanceHold returns the wallet portion held by an unpaid/expired attempt back to the client
+func (h *PaymentHandler) releaseBalanceHold(tx *gorm.DB, trx *models.Transaction, offer *models.JobOffer, description string) error {
+	return releaseHeldBalance(tx, h.WalletService, h.Ledger, trx, offer, description)
 }
 
-// Callback payload from Tripay (re-type definition if needed or keep existing)
-type TripayCallbackPayload struct {
-	Reference         string `json:"reference"`
-	MerchantRef       string `json:"merchant_ref"`
-	PaymentMethod     string `json:"payment_method"`
-	PaymentMethodCode string `json:"payment_method_code"`
-	TotalAmount       int64  `json:"total_amount"`
-	FeeMerchant       int64  `json:"fee_merchant"`
-	FeeCustomer       int64  `json:"fee_customer"`
-	TotalFee          int64  `json:"total_fee"`
-	AmountReceived    int64  `json:"amount_received"`
-	IsClosedPayment   int    `json:"is_closed_payment"`
-	Status            string `json:"status"` // PAID, EXPIRED, FAILED, REFUND
-	PaidAt            int64  `json:"paid_at"`
-	Note              string `json:"note"`
+func releaseHeldBalance(tx *gorm.DB, wallets *wallet.WalletService, ledgerService *ledger.LedgerService, trx *models.Transaction, offer *models.JobOffer, description string) error {
+	if trx.BalanceAmount <= 0 {
+		return nil
+	}
+	if err := wallets.CreditClient(tx, offer.ClientID, trx.BalanceAmount, offer.ID, description); err != nil {
+		return err
+	}
+	if err := ledgerService.RecordPaymentHoldRelease(tx, offer, trx.BalanceAmount); err != nil {
+		return err
+	}
+	trx.BalanceAmount = 0
+	return nil
+}
+
+// supersedeOpenAttempts prepares a new payment attempt: it locks the offer, makes sure it is
+// still payable and closes older UNPAID attempts, giving back the balance they hold.
+func (h *PaymentHandler) supersedeOpenAttempts(tx *gorm.DB, offer *models.JobOffer) error {
+	var current models.JobOffer
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, "id = ?", offer.ID).Error; err != nil {
+		return err
+	}
+	if current.Status != models.OfferStatusPending {
+		return fiber.NewError(400, "Offer is not in pending status")
+	}
+	if current.IsExpired(time.Now()) {
+		return fiber.NewError(400, "Offer has expired")
+	}
+
+	return closeOpenAttempts(tx, h.WalletService, h.Ledger, h.Vouchers, offer, models.TransactionStatusSuperseded,
+		"Digantikan oleh percobaan pembayaran baru", "Pengembalian saldo dari pembayaran sebelumnya #"+offer.OrderCode)
+}
+
+// closeOpenAttempts closes the UNPAID payment attempts of an offer with the given status, giving
+// back the balance and voucher they hold. The gateway checkout itself cannot be cancelled: a late
+// payment is still accepted and credited to the client's balance (see applyPaymentStatus).
+func closeOpenAttempts(tx *gorm.DB, wallets *wallet.WalletService, ledgerService *ledger.LedgerService, vouchers *voucher.VoucherService,
+	offer *models.JobOffer, status models.TransactionStatus, note, balanceDescription string) error {
+	var open []models.Transaction
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("job_offer_id = ? AND status = ?", offer.ID, models.TransactionStatusUnpaid).
+		Find(&open).Error; err != nil {
+		return err
+	}
+
+	for i := range open {
+		if err := releaseHeldBalance(tx, wallets, ledgerService, &open[i], offer, balanceDescription); err != nil {
+			return err
+		}
+		if err := vouchers.Release(tx, &open[i]); err != nil {
+			return err
+		}
+		open[i].Status = status
+		open[i].Note = note
+		if err := tx.Save(&open[i]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markOfferPaid moves a pending offer to PAID (Escrow - Funds are held by platform).
+// It returns false when the offer was already paid or further (Idempotency at Offer level).
+func (h *PaymentHandler) markOfferPaid(tx *gorm.DB, trx *models.Transaction) (bool, error) {
+	var offer models.JobOffer
+	if trx.JobOfferID == nil {
+		return false, errors.New("transaction is not an order payment")
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&offer, "id = ?", *trx.JobOfferID).Error; err != nil {
+		log.Printf("Offer not found for payment: %s", *trx.JobOfferID)
+		return false, err
+	}
+
+	if err := offer.Transition(models.OfferStatusPaid, models.OfferActorSystem); err != nil {
+		log.Printf("Offer %s already in status %s, skipping", offer.OrderCode, offer.Status)
+		return false, nil
+	}
+	applyDiscount(&offer, trx)
+	if err := tx.Save(&offer).Error; err != nil {
+		return false, err
+	}
+	if err := fundCheckoutMilestones(tx, &offer); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// applyDiscount copies the voucher discount of the paying attempt onto the offer
+func applyDiscount(offer *models.JobOffer, trx *models.Transaction) {
+	offer.VoucherID = trx.VoucherID
+	offer.DiscountAmount = trx.DiscountAmount
+	offer.DiscountFundedBy = trx.DiscountFundedBy
+}
+
+func discountAmount(a *voucher.Application) int64 {
+	if a == nil {
+		return 0
+	}
+	return a.Discount
+}
+
+func voucherErrorResponse(c *fiber.Ctx, err error) error {
+	if errors.Is(err, voucher.ErrInvalidVoucher) {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": err.Error()})
+	}
+	log.Printf("Failed to validate voucher: %v", err)
+	return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to validate voucher"})
+}
+
+// paymentEvent is what a payment status change means for the offer's conversation
+type paymentEvent string
+
+const (
+	paymentEventNone     paymentEvent = ""
+	paymentEventPaid     paymentEvent = "paid"
+	paymentEventExpired  paymentEvent = "expired"
+	paymentEventFailed   paymentEvent = "failed"
+	paymentEventRefunded paymentEvent = "refunded"
+	paymentEventReturned paymentEvent = "returned_to_balance" // paid, but credited to the client's balance
+
+	paymentEventTopUpPaid     paymentEvent = "topup_paid"
+	paymentEventTopUpRefunded paymentEvent = "topup_refunded"
+)
+
+type paymentOutcome struct {
+	Applied bool // false when the status was a duplicate or out of order
+	Event   paymentEvent
+	OfferID uuid.UUID
+	UserID  uuid.UUID // Top-ups only
+	Amount  int64
+}
+
+// notifyPaymentEvent broadcasts the offer and posts a system message explaining the payment event (after commit)
+func (h *PaymentHandler) notifyPaymentEvent(o paymentOutcome) {
+	if o.Event == paymentEventNone {
+		return
+	}
+	if o.Event == paymentEventTopUpPaid || o.Event == paymentEventTopUpRefunded {
+		h.notifyTopUp(o)
+		return
+	}
+
+	var offer models.JobOffer
+	if err := h.DB.Preload("Freelancer").Preload("Freelancer.FreelancerProfile").
+		Preload("Client").Preload("Product").
+		First(&offer, "id = ?", o.OfferID).Error; err != nil {
+		return
+	}
+
+	h.Hub.SendToConversation(offer.ClientID, offer.FreelancerID, fiber.Map{
+		"type":  "offer_status_update",
+		"offer": toJobOfferResponse(&offer),
+	})
+
+	var text string
+	switch o.Event {
+	case paymentEventPaid:
+		text = "Pembayaran terverifikasi. Pembeli telah mengirimkan dana ke Escrow Platform. Freelancer dapat mulai bekerja."
+	case paymentEventExpired:
+		text = "Batas waktu pembayaran pesanan #" + offer.OrderCode + " telah habis. Pembeli dapat melakukan pembayaran ulang."
+	case paymentEventFailed:
+		text = "Pembayaran pesanan #" + offer.OrderCode + " gagal. Pembeli dapat mencoba lagi dengan metode pembayaran lain."
+	case paymentEventRefunded:
+		text = "Pembayaran pesanan #" + offer.OrderCode + " telah di-refund oleh payment gateway. Pesanan dibatalkan."
+	case paymentEventReturned:
+		text = fmt.Sprintf("Pembayaran tambahan untuk pesanan #%s diterima, namun tidak dapat diproses. Dana Rp %d telah dikembalikan ke saldo Jokiin pembeli.", offer.OrderCode, o.Amount)
+	}
+
+	// Create System Message
+	sysMsg := models.Message{
+		ID:             uuid.New(),
+		ConversationID: offer.ConversationID,
+		SenderID:       offer.ClientID,
+		Type:           "system",
+		Text:           text,
+		CreatedAt:      time.Now(),
+	}
+
+	if err := h.DB.Create(&sysMsg).Error; err == nil {
+		h.Hub.SendToConversation(offer.ClientID, offer.FreelancerID, fiber.Map{
+			"type":    "new_message",
+			"message": sysMsg,
+		})
+	}
 }
 
 func (h *PaymentHandler) HandleCallback(c *fiber.Ctx) error {
 	// 1. Get Signature from Header
 	signature := c.Get("X-Callback-Signature")
-	if signature == "" {
-		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Missing signature"})
+	body := c.Body()
+
+	// Every callback is journaled, including rejected ones
+	headers, _ := json.Marshal(c.GetReqHeaders())
+	record := models.PaymentCallback{
+		ID:        uuid.New(),
+		Gateway:   h.Gateway.Name(),
+		Headers:   headers,
+		RawBody:   string(body),
+		Signature: signature,
 	}
 
 	// 2. Validate Signature
-	body := c.Body()
-	if !h.TripayService.ValidateSignature(signature, string(body)) {
+	record.SignatureValid = signature != "" && h.Gateway.ValidateCallback(signature, body)
+	if !record.SignatureValid {
+		record.Outcome = models.CallbackOutcomeInvalidSignature
+		h.saveCallbackRecord(&record)
+		if signature == "" {
+			return c.Status(400).JSON(fiber.Map{"success": false, "message": "Missing signature"})
+		}
 		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid signature"})
 	}
 
-	// 3. Parse Payload
-	var payload TripayCallbackPayload
-	if err := c.BodyParser(&payload); err != nil {
+	// 3. + 4. Parse Payload, Update Transaction & Offer
+	outcome, err := h.processCallback(&record)
+	if record.Outcome == models.CallbackOutcomeInvalidPayload {
 		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid payload"})
 	}
+	if err != nil {
+		log.Printf("Error processing callback: %v", err)
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Processing error"})
+	}
+
+	// Post-transaction actions (Broadcasting)
+	h.notifyPaymentEvent(outcome)
+
+	return c.JSON(fiber.Map{"success": true})
+}
 
-	// 4. Update Transaction & Offer in a DB TRANSACTION
+// applyPaymentStatus applies a gateway payment status (from a callback or a status poll)
+// to the transaction and its offer in one DB transaction. The returned outcome tells the
+// caller what to announce in the conversation after commit.
+func (h *PaymentHandler) applyPaymentStatus(payload *gateway.CallbackPayload) (paymentOutcome, error) {
+	var outcome paymentOutcome
 	err := h.DB.Transaction(func(tx *gorm.DB) error {
 		var trx models.Transaction
-		// Lock the row for update to prevent race conditions (Double Callback)
-		if err := tx.Set("gorm:query_option", "FOR UPDATE").Where("reference = ?", payload.Reference).First(&trx).Error; err != nil {
+		// Lock the row for update to prevent race conditions (double callback, status poll or replay):
+		// a concurrent delivery of the same status waits here and then sees it applied
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("reference = ?", payload.Reference).First(&trx).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				log.Printf("Transaction not found for ref: %s. Ignoring callback.", payload.Reference)
-				return nil // Return nil specifically to stop transaction and return success to Tripay
+				return nil // Return nil specifically to stop transaction and return success to the gateway
 			}
 			return err
 		}
 
-		// IDEMPOTENCY CHECK: If already PAID, just return success
-		if trx.Status == models.TransactionStatusPaid {
-			log.Printf("Transaction %s already processed (Idempotency)", payload.Reference)
+		// IDEMPOTENCY CHECK: repeated or out-of-order statuses are acknowledged and ignored
+		newStatus := models.TransactionStatus(payload.Status)
+		if !canApplyPaymentStatus(trx.Status, newStatus) {
+			log.Printf("Transaction %s: ignoring status %s (current %s)", payload.Reference, newStatus, trx.Status)
 			return nil
 		}
+		prevStatus := trx.Status
+		outcome.Applied = true
 
 		// Update fields
-		trx.Status = models.TransactionStatus(payload.Status)
+		trx.Status = newStatus
 		trx.PaymentMethod = payload.PaymentMethod
 		trx.PaymentMethodCode = payload.PaymentMethodCode
 		trx.TotalAmount = payload.TotalAmount
@@ -270,79 +608,184 @@ func (h *PaymentHandler) HandleCallback(c *fiber.Ctx) error {
 			trx.PaidAt = &t
 		}
 
-		if err := tx.Save(&trx).Error; err != nil {
+		// Wallet top-ups (TOP-...) credit the client's balance, there is no offer behind them
+		if trx.Purpose == models.TransactionPurposeTopUp {
+			if err := h.applyTopUpStatus(tx, &trx, &outcome); err != nil {
+				return err
+			}
+			return tx.Save(&trx).Error
+		}
+		if trx.JobOfferID == nil {
+			return fmt.Errorf("order transaction %s has no job offer", trx.Reference)
+		}
+
+		var offer models.JobOffer
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&offer, "id = ?", *trx.JobOfferID).Error; err != nil {
 			return err
 		}
+		outcome.OfferID = offer.ID
 
 		// 5. Update Offer Status
-		if payload.Status == "PAID" {
-			// Extract Order Code from MerchantRef "INV-{OrderCode}"
-			if len(payload.MerchantRef) < 5 {
-				return fmt.Errorf("invalid merchant ref: %s", payload.MerchantRef)
+		switch newStatus {
+		case models.TransactionStatusPaid:
+			gatewayPortion := trx.TotalAmount - trx.FeeCustomer
+			if trx.BalanceAmount+gatewayPortion >= offer.CheckoutAmount()-trx.DiscountAmount &&
+				offer.Transition(models.OfferStatusPaid, models.OfferActorSystem) == nil {
+				// Escrow - Funds are held by platform
+				applyDiscount(&offer, &trx)
+				if err := tx.Save(&offer).Error; err != nil {
+					return err
+				}
+				if err := fundCheckoutMilestones(tx, &offer); err != nil {
+					return err
+				}
+				if err := cancelPendingCounters(tx, offer.ID); err != nil {
+					return err
+				}
+				if err := h.Vouchers.Confirm(tx, &trx); err != nil {
+					return err
+				}
+				if err := h.Ledger.RecordOrderPayment(tx, &trx, &offer, true); err != nil {
+					return err
+				}
+				if _, err := h.Invoices.Issue(tx, &trx, &offer); err != nil {
+					return err
+				}
+				outcome.Event = paymentEventPaid
+				break
 			}
-			orderCode := payload.MerchantRef[4:]
 
-			var offer models.JobOffer
-			if err := tx.Set("gorm:query_option", "FOR UPDATE").Where("order_code = ?", orderCode).First(&offer).Error; err != nil {
-				log.Printf("Offer not found for callback ref: %s", payload.MerchantRef)
+			// Paid through another attempt already (or a superseded attempt that no longer
+			// covers the price): the money goes to the client's balance instead of escrow
+			paidRef, err := offerPaymentReference(tx, offer.ID)
+			if err != nil {
 				return err
 			}
-
-			// If offer already paid or further, skip (Idempotency at Offer level)
-			if offer.Status != models.OfferStatusPending {
-				log.Printf("Offer %s already in status %s, skipping", offer.OrderCode, offer.Status)
+			if paidRef == trx.Reference {
+				// This very payment funded the offer: a repeated delivery, not a second payment
+				log.Printf("Transaction %s already paid offer %s, ignoring repeated status", trx.Reference, offer.OrderCode)
+				outcome.Applied = false
 				return nil
 			}
+			outcome.Amount = trx.BalanceAmount + gatewayPortion
+			if err := h.releaseBalanceHold(tx, &trx, &offer, "Pengembalian saldo, pembayaran ganda pesanan #"+offer.OrderCode); err != nil {
+				return err
+			}
+			if err := h.Vouchers.Release(tx, &trx); err != nil {
+				return err
+			}
+			desc := "Pengembalian pembayaran ganda pesanan #" + offer.OrderCode + " (" + trx.Reference + ")"
+			if err := h.WalletService.CreditClient(tx, offer.ClientID, gatewayPortion, offer.ID, desc); err != nil {
+				return err
+			}
+			if err := h.Ledger.RecordUnmatchedPayment(tx, &trx, &offer); err != nil {
+				return err
+			}
+			trx.ReturnedToBalance = true
+			outcome.Event = paymentEventReturned
 
-			// Update Status to PAID (Escrow - Funds are held by platform)
-			offer.Status = models.OfferStatusPaid
-			if err := tx.Save(&offer).Error; err != nil {
+		case models.TransactionStatusExpired, models.TransactionStatusFailed:
+			// Give back the wallet portion of a split payment that never completed
+			if err := h.releaseBalanceHold(tx, &trx, &offer, "Pengembalian saldo, pembayaran pesanan #"+offer.OrderCode+" tidak selesai"); err != nil {
+				return err
+			}
+			if err := h.Vouchers.Release(tx, &trx); err != nil {
 				return err
 			}
+			// Only the live attempt of an unpaid offer is worth telling the conversation about
+			if prevStatus == models.TransactionStatusUnpaid && offer.Status == models.OfferStatusPending {
+				outcome.Event = paymentEventExpired
+				if newStatus == models.TransactionStatusFailed {
+					outcome.Event = paymentEventFailed
+				}
+			}
 
-			// Broadcast update (deferred after transaction commit would be better, but hub is fine for now)
-			// Actually let's just do it here, or after the transaction block
+		case models.TransactionStatusRefund:
+			if trx.ReturnedToBalance {
+				// The client got this money as balance already; take it back from there
+				log.Printf("Transaction %s refunded by gateway after being credited to balance, needs manual review", trx.Reference)
+				break
+			}
+			if offer.Status == models.OfferStatusPending || offer.CanTransition(models.OfferStatusCancelled, models.OfferActorSystem) != nil {
+				// Escrow already refunded, nothing left to reverse automatically
+				log.Printf("Transaction %s refunded by gateway but offer %s is %s, needs manual review", trx.Reference, offer.OrderCode, offer.Status)
+				break
+			}
+			// Earnings released on completion (or on completed milestones) come back to escrow
+			// as long as they have not cleared
+			err := h.Clearance.Reverse(tx, &offer, "Pembatalan pelepasan dana, pembayaran pesanan #"+offer.OrderCode+" di-refund")
+			if errors.Is(err, clearance.ErrAlreadyCleared) {
+				log.Printf("Transaction %s refunded by gateway but the earnings of offer %s already cleared, needs manual review", trx.Reference, offer.OrderCode)
+				break
+			}
+			if err != nil {
+				return err
+			}
+
+			if trx.BalanceAmount > 0 {
+				desc := "Pengembalian saldo, pembayaran pesanan #" + offer.OrderCode + " di-refund"
+				if err := h.WalletService.CreditClient(tx, offer.ClientID, trx.BalanceAmount, offer.ID, desc); err != nil {
+					return err
+				}
+				if err := h.Ledger.RecordEscrowRefund(tx, &offer, trx.BalanceAmount); err != nil {
+					return err
+				}
+			}
+			if err := h.Ledger.RecordGatewayRefund(tx, &trx, &offer); err != nil {
+				return err
+			}
+
+			if err := offer.Transition(models.OfferStatusCancelled, models.OfferActorSystem); err != nil {
+				return err
+			}
+			if err := tx.Save(&offer).Error; err != nil {
+				return err
+			}
+			if err := cancelOpenMilestones(tx, offer.ID); err != nil {
+				return err
+			}
+			if err := closeOpenDisputes(tx, offer.ID, "Dana dikembalikan oleh payment gateway"); err != nil {
+				return err
+			}
+			outcome.Event = paymentEventRefunded
 		}
 
-		return nil
+		return tx.Save(&trx).Error
 	})
-
 	if err != nil {
-		log.Printf("Error processing callback: %v", err)
-		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Processing error"})
+		return paymentOutcome{}, err
 	}
+	return outcome, nil
+}
 
-	// Post-transaction actions (Broadcasting)
-	if payload.Status == "PAID" {
-		orderCode := payload.MerchantRef[4:]
-		var offer models.JobOffer
-		if err := h.DB.Preload("Freelancer").Preload("Freelancer.FreelancerProfile").
-			Preload("Client").Preload("Product").
-			Where("order_code = ?", orderCode).First(&offer).Error; err == nil {
-
-			h.Hub.SendToConversation(offer.ClientID, offer.FreelancerID, fiber.Map{
-				"type":  "offer_status_update",
-				"offer": toJobOfferResponse(&offer),
-			})
-
-			// Create System Message
-			sysMsg := models.Message{
-				ID:             uuid.New(),
-				ConversationID: offer.ConversationID,
-				SenderID:       offer.ClientID,
-				Type:           "system",
-				Text:           "Pembayaran terverifikasi. Pembeli telah mengirimkan dana ke Escrow Platform. Freelancer dapat mulai bekerja.",
-				CreatedAt:      time.Now(),
-			}
-
-			if err := h.DB.Create(&sysMsg).Error; err == nil {
-				h.Hub.SendToConversation(offer.ClientID, offer.FreelancerID, fiber.Map{
-					"type":    "new_message",
-					"message": sysMsg,
-				})
-			}
-		}
+// offerPaymentReference returns the reference of the attempt that funded an offer's escrow, or ""
+// when no attempt has paid it
+func offerPaymentReference(tx *gorm.DB, offerID uuid.UUID) (string, error) {
+	var paid models.Transaction
+	err := tx.Select("reference").
+		Where("job_offer_id = ? AND purpose = ? AND status IN ? AND NOT returned_to_balance", offerID, models.TransactionPurposeOrder,
+			[]models.TransactionStatus{models.TransactionStatusPaid, models.TransactionStatusRefund}).
+		Order("paid_at").
+		First(&paid).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
 	}
+	return paid.Reference, err
+}
 
-	return c.JSON(fiber.Map{"success": true})
+// canApplyPaymentStatus tells whether a gateway status may move a transaction forward.
+// Late payments of closed attempts are accepted; a refund only follows a payment.
+func canApplyPaymentStatus(from, to models.TransactionStatus) bool {
+	if from == to {
+		return false
+	}
+	switch from {
+	case models.TransactionStatusUnpaid:
+		return to == models.TransactionStatusPaid || to == models.TransactionStatusExpired || to == models.TransactionStatusFailed
+	case models.TransactionStatusExpired, models.TransactionStatusFailed, models.TransactionStatusSuperseded:
+		return to == models.TransactionStatusPaid
+	case models.TransactionStatusPaid:
+		return to == models.TransactionStatusRefund
+	}
+	return false
 }
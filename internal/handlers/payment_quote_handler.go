@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"log"
+	"time"
+
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/models"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/voucher"
+	"github.com/gofiber/fiber/v2"
+)
+
+// paymentAttempts summarizes the earlier payment attempts of an offer
+type paymentAttempts struct {
+	Count       int   // Used to number the next merchant ref
+	Paid        bool  // An attempt already paid the offer
+	HeldBalance int64 // Wallet balance still held by open (UNPAID) attempts
+}
+
+func (h *PaymentHandler) loadAttempts(offer *models.JobOffer) (paymentAttempts, error) {
+	var rows []models.Transaction
+	if err := h.DB.Where("job_offer_id = ?", offer.ID).Find(&rows).Error; err != nil {
+		return paymentAttempts{}, err
+	}
+
+	a := paymentAttempts{Count: len(rows)}
+	for _, trx := range rows {
+		if trx.Status == models.TransactionStatusPaid && !trx.ReturnedToBalance {
+			a.Paid = true
+		}
+		if trx.Status == models.TransactionStatusUnpaid {
+			a.HeldBalance += trx.BalanceAmount
+		}
+	}
+	return a, nil
+}
+
+// usableBalance is how much of payable the client's wallet covers in a split payment.
+// Balance held by open attempts counts, because a new attempt supersedes them.
+func (a paymentAttempts) usableBalance(offer *models.JobOffer, payable int64) int64 {
+	available := offer.Client.Balance + a.HeldBalance
+	if available > payable {
+		return payable
+	}
+	return available
+}
+
+type channelQuote struct {
+	Code        string `json:"code"`
+	Name        string `json:"name"`
+	Group       string `json:"group"`
+	Type        string `json:"type"`
+	IconURL     string `json:"icon_url"`
+	Amount      int64  `json:"amount"`       // Charged through the gateway before fees
+	CustomerFee int64  `json:"customer_fee"` // Same flat + percent rule as checkout
+	GrandTotal  int64  `json:"grand_total"`
+}
+
+// GetQuote returns, for every active channel, the fee and grand total the client would pay
+// for an offer. With use_balance=true the wallet covers part of the price first; voucher_code
+// applies a discount.
+func (h *PaymentHandler) GetQuote(c *fiber.Ctx) error {
+	userID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var offer models.JobOffer
+	if err := h.DB.Preload("Client").First(&offer, "id = ?", c.Query("offer_id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "message": "Offer not found"})
+	}
+	if offer.ClientID != userID {
+		return c.Status(403).JSON(fiber.Map{"success": false, "message": "Only client can pay for this offer"})
+	}
+	if offer.Status != models.OfferStatusPending {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Offer is not in pending status"})
+	}
+	if offer.IsExpired(time.Now()) {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Offer has expired"})
+	}
+
+	attempts, err := h.loadAttempts(&offer)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to load payment attempts"})
+	}
+	if attempts.Paid {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Offer is already paid"})
+	}
+
+	var discount *voucher.Application
+	if code := c.Query("voucher_code"); code != "" {
+		discount, err = h.Vouchers.Validate(code, &offer, offer.ClientID)
+		if err != nil {
+			return voucherErrorResponse(c, err)
+		}
+	}
+	payable := offer.CheckoutAmount() - discountAmount(discount)
+
+	var balanceUsed int64
+	if c.QueryBool("use_balance") {
+		balanceUsed = attempts.usableBalance(&offer, payable)
+	}
+	chargeAmount := payable - balanceUsed
+
+	quotes := []channelQuote{}
+	if chargeAmount > 0 {
+		channels, err := h.Gateway.GetPaymentChannels()
+		if err != nil {
+			log.Printf("Failed to fetch channels for quote: %v", err)
+			return c.Status(502).JSON(fiber.Map{"success": false, "message": "Failed to fetch payment channels"})
+		}
+		for _, ch := range channels {
+			if !ch.Active {
+				continue
+			}
+			fee := ch.CustomerFee(chargeAmount)
+			quotes = append(quotes, channelQuote{
+				Code:        ch.Code,
+				Name:        ch.Name,
+				Group:       ch.Group,
+				Type:        ch.Type,
+				IconURL:     ch.IconURL,
+				Amount:      chargeAmount,
+				CustomerFee: fee,
+				GrandTotal:  chargeAmount + fee,
+			})
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"offer_id":          offer.ID,
+			"price":             offer.Price,
+			"checkout_amount":   offer.CheckoutAmount(),
+			"discount":          discountAmount(discount),
+			"available_balance": offer.Client.Balance + attempts.HeldBalance,
+			"balance_used":      balanceUsed,
+			"charge_amount":     chargeAmount,
+			"channels":          quotes,
+		},
+	})
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"bytes"
+	"html/template"
+	"log"
+
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/gateway"
+	"github.com/gofiber/fiber/v2"
+)
+
+// PaymentSimulatorHandler serves the fake checkout page of the simulator gateway.
+// Only registered when PAYMENT_GATEWAY=simulator.
+type PaymentSimulatorHandler struct {
+	Simulator *gateway.Simulator
+}
+
+func NewPaymentSimulatorHandler(simulator *gateway.Simulator) *PaymentSimulatorHandler {
+	return &PaymentSimulatorHandler{Simulator: simulator}
+}
+
+var simulatorCheckoutTmpl = template.Must(template.New("checkout").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Payment Simulator - {{.Trx.Reference}}</title>
+<style>
+body { font-family: sans-serif; max-width: 480px; margin: 40px auto; color: #222; }
+table { width: 100%; border-collapse: collapse; margin: 16px 0; }
+td { padding: 6px 0; border-bottom: 1px solid #eee; }
+td:last-child { text-align: right; }
+button { padding: 10px 16px; margin: 4px 4px 4px 0; cursor: pointer; }
+.paid { background: #16a34a; color: #fff; border: 0; }
+.notice { padding: 10px; background: #fef3c7; }
+.error { padding: 10px; background: #fee2e2; }
+</style>
+</head>
+<body>
+<h2>Payment Simulator</h2>
+<p class="notice">Offline gateway for development. No real money is moved.</p>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<table>
+<tr><td>Reference</td><td>{{.Trx.Reference}}</td></tr>
+<tr><td>Merchant Ref</td><td>{{.Trx.MerchantRef}}</td></tr>
+<tr><td>Item</td><td>{{.Trx.ItemName}}</td></tr>
+<tr><td>Method</td><td>{{.Trx.ChannelName}}</td></tr>
+<tr><td>Fee</td><td>Rp {{.Trx.CustomerFee}}</td></tr>
+<tr><td><b>Total</b></td><td><b>Rp {{.Trx.Amount}}</b></td></tr>
+<tr><td>Status</td><td>{{.Trx.Status}}</td></tr>
+</table>
+<form method="post">
+<button class="paid" name="status" value="PAID">Pay</button>
+<button name="status" value="EXPIRED">Expire</button>
+<button name="status" value="FAILED">Fail</button>
+<button name="status" value="REFUND">Refund</button>
+</form>
+{{if .Trx.ReturnURL}}<p><a href="{{.Trx.ReturnURL}}">Back to merchant</a></p>{{end}}
+</body>
+</html>`))
+
+// Checkout renders the fake payment page
+func (h *PaymentSimulatorHandler) Checkout(c *fiber.Ctx) error {
+	trx, ok := h.Simulator.Get(c.Params("reference"))
+	if !ok {
+		return c.Status(404).SendString("Transaction not found")
+	}
+	return h.render(c, 200, trx, "")
+}
+
+// Complete sets the chosen status, fires the signed callback and sends the customer back
+func (h *PaymentSimulatorHandler) Complete(c *fiber.Ctx) error {
+	reference := c.Params("reference")
+	if _, ok := h.Simulator.Get(reference); !ok {
+		return c.Status(404).SendString("Transaction not found")
+	}
+
+	if err := h.Simulator.Complete(reference, c.FormValue("status")); err != nil {
+		log.Printf("[Simulator] Callback for %s failed: %v", reference, err)
+		trx, _ := h.Simulator.Get(reference)
+		return h.render(c, 502, trx, err.Error())
+	}
+
+	trx, _ := h.Simulator.Get(reference)
+	if trx.ReturnURL != "" {
+		return c.Redirect(trx.ReturnURL)
+	}
+	return h.render(c, 200, trx, "")
+}
+
+func (h *PaymentSimulatorHandler) render(c *fiber.Ctx, status int, trx gateway.SimulatedTransaction, errMsg string) error {
+	var buf bytes.Buffer
+	if err := simulatorCheckoutTmpl.Execute(&buf, fiber.Map{"Trx": trx, "Error": errMsg}); err != nil {
+		return c.Status(500).SendString("Failed to render checkout page")
+	}
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	return c.Status(status).Send(buf.Bytes())
+}
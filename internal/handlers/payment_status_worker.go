@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"log"
+	"time"
+
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/models"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/gateway"
+	"github.com/gofiber/fiber/v2"
+)
+
+// statusPollGrace keeps polling a transaction for a while after its deadline,
+// so the final EXPIRED status is picked up even when the callback is lost.
+const statusPollGrace = 1 * time.Hour
+
+// StartStatusPollingWorker periodically asks the gateway for the status of open transactions,
+// in case the callback never reached us (server down, tunnel expired, ...).
+func (h *PaymentHandler) StartStatusPollingWorker(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			h.pollUnpaidTransactions()
+		}
+	}()
+}
+
+func (h *PaymentHandler) pollUnpaidTransactions() {
+	// Older rows have no expired_at; their deadline was created_at + paymentExpiry
+	cutoff := time.Now().Add(-statusPollGrace)
+
+	var transactions []models.Transaction
+	err := h.DB.
+		Where("status IN ? AND payment_method <> ?", []models.TransactionStatus{
+			models.TransactionStatusUnpaid,
+			models.TransactionStatusSuperseded, // can still be paid at the gateway
+			models.TransactionStatusExpired,    // closed with its offer before the gateway deadline
+		}, models.PaymentMethodBalance).
+		Where("(expired_at > ?) OR (expired_at IS NULL AND created_at > ?)", cutoff, cutoff.Add(-paymentExpiry)).
+		Find(&transactions).Error
+	if err != nil {
+		log.Printf("[PaymentStatusWorker] Error fetching unpaid transactions: %v", err)
+		return
+	}
+
+	for _, trx := range transactions {
+		if _, _, err := h.recheckTransaction(trx.Reference); err != nil {
+			log.Printf("[PaymentStatusWorker] Failed to re-check %s: %v", trx.Reference, err)
+		}
+	}
+}
+
+// recheckTransaction fetches the gateway status of a reference and applies it like a callback would
+func (h *PaymentHandler) recheckTransaction(reference string) (*gateway.CallbackPayload, bool, error) {
+	status, err := h.Gateway.GetTransactionStatus(reference)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Nothing happened at the gateway yet
+	if status.Status == string(models.TransactionStatusUnpaid) {
+		return status, false, nil
+	}
+
+	outcome, err := h.applyPaymentStatus(status)
+	if err != nil {
+		return status, false, err
+	}
+
+	if outcome.Event != paymentEventNone {
+		log.Printf("[PaymentStatusWorker] Recovered missed %s status for %s", status.Status, reference)
+	}
+	h.notifyPaymentEvent(outcome)
+	return status, true, nil
+}
+
+// RecheckTransaction lets an admin force a gateway status re-check for one reference
+func (h *PaymentHandler) RecheckTransaction(c *fiber.Ctx) error {
+	reference := c.Params("reference")
+
+	var trx models.Transaction
+	if err := h.DB.Where("reference = ?", reference).First(&trx).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "message": "Transaction not found"})
+	}
+	if trx.PaymentMethod == models.PaymentMethodBalance {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Balance payments have no gateway status"})
+	}
+
+	status, applied, err := h.recheckTransaction(reference)
+	if err != nil {
+		return c.Status(502).JSON(fiber.Map{"success": false, "message": "Failed to re-check transaction: " + err.Error()})
+	}
+
+	h.DB.Where("id = ?", trx.ID).First(&trx)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"gateway_status": status.Status,
+			"applied":        applied,
+			"transaction":    trx,
+		},
+	})
+}
@@ -0,0 +1,350 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/models"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type PayoutAccountHandler struct {
+	DB         *gorm.DB
+	EncryptKey string
+}
+
+func NewPayoutAccountHandler(db *gorm.DB, encryptKey string) *PayoutAccountHandler {
+	return &PayoutAccountHandler{DB: db, EncryptKey: encryptKey}
+}
+
+type PayoutAccountRequest struct {
+	Type          string `json:"type"`          // bank | ewallet
+	ProviderCode  string `json:"provider_code"` // BCA, BNI, DANA, OVO, GOPAY, ...
+	AccountNumber string `json:"account_number"`
+	HolderName    string `json:"holder_name"`
+	IsPrimary     bool   `json:"is_primary"`
+}
+
+var (
+	bankAccountRe    = regexp.MustCompile(`^[0-9]{6,20}$`)
+	ewalletAccountRe = regexp.MustCompile(`^(08|628)[0-9]{8,12}$`)
+	nonLetterRe      = regexp.MustCompile(`[^A-Z ]+`)
+)
+
+// normalizeHolderName uppercases a name and strips punctuation/extra spaces so that
+// "Budi  Santoso, S.Kom" style differences don't break the KTP name comparison.
+func normalizeHolderName(name string) string {
+	name = nonLetterRe.ReplaceAllString(strings.ToUpper(name), " ")
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// ktpFullName builds the name exactly as captured in onboarding step 4 (KTP)
+func ktpFullName(p *models.FreelancerProfile) string {
+	parts := []string{}
+	for _, n := range []string{p.FirstName, p.MiddleName, p.LastName} {
+		if strings.TrimSpace(n) != "" {
+			parts = append(parts, strings.TrimSpace(n))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// validate checks the request and returns the cleaned account number
+func (h *PayoutAccountHandler) validate(tx *gorm.DB, userID uuid.UUID, req *PayoutAccountRequest) (string, error) {
+	req.Type = strings.ToLower(strings.TrimSpace(req.Type))
+	req.ProviderCode = strings.ToUpper(strings.TrimSpace(req.ProviderCode))
+	req.HolderName = strings.TrimSpace(req.HolderName)
+
+	accountType := models.PayoutAccountType(req.Type)
+	providers, ok := models.PayoutProviders[accountType]
+	if !ok {
+		return "", fiber.NewError(400, "type must be bank or ewallet")
+	}
+	if _, ok := providers[req.ProviderCode]; !ok {
+		return "", fiber.NewError(400, "Unsupported provider_code for "+req.Type)
+	}
+
+	number := normalizePhone(req.AccountNumber)
+	switch accountType {
+	case models.PayoutAccountBank:
+		if !bankAccountRe.MatchString(number) {
+			return "", fiber.NewError(400, "account_number must be 6-20 digits")
+		}
+	case models.PayoutAccountEWallet:
+		if !ewalletAccountRe.MatchString(number) {
+			return "", fiber.NewError(400, "account_number must be a valid phone number registered to the e-wallet")
+		}
+	}
+
+	if req.HolderName == "" {
+		return "", fiber.NewError(400, "holder_name is required")
+	}
+
+	// Holder name must match the KTP name captured during onboarding
+	var profile models.FreelancerProfile
+	if err := tx.Where("user_id = ?", userID).First(&profile).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", fiber.NewError(404, "Profile not found")
+		}
+		return "", err
+	}
+
+	ktpName := ktpFullName(&profile)
+	if ktpName == "" {
+		return "", fiber.NewError(400, "Lengkapi data identitas (KTP) terlebih dahulu")
+	}
+	if normalizeHolderName(req.HolderName) != normalizeHolderName(ktpName) {
+		return "", fiber.NewError(400, "Nama pemilik rekening harus sama dengan nama di KTP")
+	}
+
+	return number, nil
+}
+
+// ListPayoutAccounts returns the freelancer's payout destinations
+func (h *PayoutAccountHandler) ListPayoutAccounts(c *fiber.Ctx) error {
+	userID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var accounts []models.PayoutAccount
+	if err := h.DB.Where("user_id = ?", userID).Order("is_primary DESC, created_at ASC").Find(&accounts).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to fetch payout accounts"})
+	}
+
+	return c.JSON(fiber.Map{"success": true, "data": accounts})
+}
+
+// CreatePayoutAccount adds a new bank account or e-wallet
+func (h *PayoutAccountHandler) CreatePayoutAccount(c *fiber.Ctx) error {
+	userID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var req PayoutAccountRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid request body"})
+	}
+
+	var account models.PayoutAccount
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		number, err := h.validate(tx, userID, &req)
+		if err != nil {
+			return err
+		}
+
+		enc, err := utils.EncryptString(number, h.EncryptKey)
+		if err != nil {
+			return err
+		}
+
+		// The first account always becomes the primary one
+		var count int64
+		if err := tx.Model(&models.PayoutAccount{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+			return err
+		}
+
+		account = models.PayoutAccount{
+			ID:                  uuid.New(),
+			UserID:              userID,
+			Type:                models.PayoutAccountType(req.Type),
+			ProviderCode:        req.ProviderCode,
+			HolderName:          req.HolderName,
+			AccountNumberEnc:    enc,
+			AccountNumberMasked: utils.MaskAccountNumber(number),
+			IsPrimary:           req.IsPrimary || count == 0,
+		}
+
+		if account.IsPrimary {
+			if err := h.clearPrimary(tx, userID); err != nil {
+				return err
+			}
+		}
+
+		return tx.Create(&account).Error
+	})
+
+	if err != nil {
+		return h.fail(c, err)
+	}
+
+	return c.Status(201).JSON(fiber.Map{"success": true, "data": account})
+}
+
+// UpdatePayoutAccount replaces the details of an existing payout account
+func (h *PayoutAccountHandler) UpdatePayoutAccount(c *fiber.Ctx) error {
+	userID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var req PayoutAccountRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid request body"})
+	}
+
+	var account models.PayoutAccount
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := h.findOwned(tx, c.Params("id"), userID, &account); err != nil {
+			return err
+		}
+
+		number, err := h.validate(tx, userID, &req)
+		if err != nil {
+			return err
+		}
+
+		enc, err := utils.EncryptString(number, h.EncryptKey)
+		if err != nil {
+			return err
+		}
+
+		account.Type = models.PayoutAccountType(req.Type)
+		account.ProviderCode = req.ProviderCode
+		account.HolderName = req.HolderName
+		account.AccountNumberEnc = enc
+		account.AccountNumberMasked = utils.MaskAccountNumber(number)
+
+		return tx.Save(&account).Error
+	})
+
+	if err != nil {
+		return h.fail(c, err)
+	}
+
+	return c.JSON(fiber.Map{"success": true, "data": account})
+}
+
+// SetPrimaryPayoutAccount marks an account as the default withdrawal destination
+func (h *PayoutAccountHandler) SetPrimaryPayoutAccount(c *fiber.Ctx) error {
+	userID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var account models.PayoutAccount
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := h.findOwned(tx, c.Params("id"), userID, &account); err != nil {
+			return err
+		}
+		if err := h.clearPrimary(tx, userID); err != nil {
+			return err
+		}
+
+		account.IsPrimary = true
+		return tx.Save(&account).Error
+	})
+
+	if err != nil {
+		return h.fail(c, err)
+	}
+
+	return c.JSON(fiber.Map{"success": true, "data": account})
+}
+
+// DeletePayoutAccount removes a payout account; if it was primary, the oldest remaining one takes over
+func (h *PayoutAccountHandler) DeletePayoutAccount(c *fiber.Ctx) error {
+	userID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		var account models.PayoutAccount
+		if err := h.findOwned(tx, c.Params("id"), userID, &account); err != nil {
+			return err
+		}
+
+		if err := tx.Delete(&account).Error; err != nil {
+			return err
+		}
+
+		if !account.IsPrimary {
+			return nil
+		}
+
+		var next models.PayoutAccount
+		if err := tx.Where("user_id = ?", userID).Order("created_at ASC").First(&next).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		return tx.Model(&next).Update("is_primary", true).Error
+	})
+
+	if err != nil {
+		return h.fail(c, err)
+	}
+
+	return c.JSON(fiber.Map{"success": true, "message": "Payout account deleted"})
+}
+
+// ===== Helpers =====
+
+func (h *PayoutAccountHandler) findOwned(tx *gorm.DB, rawID string, userID uuid.UUID, account *models.PayoutAccount) error {
+	accountID, err := uuid.Parse(rawID)
+	if err != nil {
+		return fiber.NewError(400, "Invalid payout account ID")
+	}
+
+	if err := tx.First(account, "id = ? AND user_id = ?", accountID, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fiber.NewError(404, "Payout account not found")
+		}
+		return err
+	}
+	return nil
+}
+
+func (h *PayoutAccountHandler) clearPrimary(tx *gorm.DB, userID uuid.UUID) error {
+	return tx.Model(&models.PayoutAccount{}).
+		Where("user_id = ? AND is_primary = ?", userID, true).
+		Update("is_primary", false).Error
+}
+
+func (h *PayoutAccountHandler) fail(c *fiber.Ctx, err error) error {
+	if e, ok := err.(*fiber.Error); ok {
+		return c.Status(e.Code).JSON(fiber.Map{"success": false, "message": e.Message})
+	}
+	log.Printf("[PayoutAccount] %v", err)
+	return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to process payout account"})
+}
+
+// revealAccountNumber decrypts the account number of a withdrawal or refund destination for an
+// admin and records the view. The number is only returned once the audit record is stored.
+func revealAccountNumber(db *gorm.DB, c *fiber.Ctx, encryptKey, subjectType string, subjectID uuid.UUID, enc string) (string, error) {
+	viewerID, err := getAuth(c)
+	if err != nil {
+		return "", err
+	}
+	if enc == "" {
+		return "", fiber.NewError(404, "No account number stored for this "+subjectType)
+	}
+
+	number, err := utils.DecryptString(enc, encryptKey)
+	if err != nil {
+		log.Printf("[PayoutAccount] Failed to decrypt account number of %s %s: %v", subjectType, subjectID, err)
+		return "", fiber.NewError(500, "Failed to decrypt account number")
+	}
+
+	view := models.PayoutAccountView{
+		ViewerID:    viewerID,
+		SubjectType: subjectType,
+		SubjectID:   subjectID,
+		IPAddress:   c.IP(),
+	}
+	if err := db.Create(&view).Error; err != nil {
+		log.Printf("[PayoutAccount] Failed to record account view of %s %s: %v", subjectType, subjectID, err)
+		return "", fiber.NewError(500, "Failed to record account view")
+	}
+
+	log.Printf("[PayoutAccount] Admin %s viewed the account number of %s %s", viewerID, subjectType, subjectID)
+	return number, nil
+}
@@ -39,6 +39,30 @@ type PackageReq struct {
 	Benefits     []string `json:"benefits"`
 }
 
+// AddonReq is an optional extra a client can add to any package when ordering
+type AddonReq struct {
+	ID           string `json:"id"` // Diisi otomatis (addon-1, addon-2, ...) kalau kosong
+	Title        string `json:"title"`
+	Description  string `json:"description"`
+	Price        int64  `json:"price"`
+	DeliveryDays int    `json:"delivery_days"` // Tambahan hari pengerjaan
+	Revisions    int    `json:"revisions"`     // Tambahan jumlah revisi
+}
+
+// addonsJSON gives every add-on an ID orders can refer to
+func addonsJSON(addons []AddonReq) datatypes.JSON {
+	for i := range addons {
+		if addons[i].ID == "" {
+			addons[i].ID = fmt.Sprintf("addon-%d", i+1)
+		}
+	}
+	if addons == nil {
+		addons = []AddonReq{}
+	}
+	b, _ := json.Marshal(addons)
+	return datatypes.JSON(b)
+}
+
 type PortfolioImageReq struct {
 	FileName    string `json:"file_name"`
 	Description string `json:"description"`
@@ -57,6 +81,8 @@ type ProductBasicReq struct {
 	Standard PackageReq `json:"standard"`
 	Premium  PackageReq `json:"premium"`
 
+	Addons []AddonReq `json:"addons"`
+
 	PortfolioVideoURL string              `json:"portfolio_video_url"`
 	PortfolioImages   []PortfolioImageReq `json:"portfolio_images"`
 
@@ -146,6 +172,7 @@ func (h *ProductHandler) CreateBasic(c *fiber.Ctx) error {
 		CoverURL:              req.CoverURL,
 		CoverTransform:        datatypes.JSON(coverTransformJSON),
 		Packages:              datatypes.JSON(packagesJSON),
+		Addons:                addonsJSON(req.Addons),
 		Portfolio:             datatypes.JSON(portfolioJSON),
 		Status:                status,
 	}
@@ -403,6 +430,7 @@ func (h *ProductHandler) UpdateProduct(c *fiber.Ctx) error {
 	product.CoverURL = req.CoverURL
 	product.CoverTransform = datatypes.JSON(coverTransformJSON)
 	product.Packages = datatypes.JSON(packagesJSON)
+	product.Addons = addonsJSON(req.Addons)
 	product.Portfolio = datatypes.JSON(portfolioJSON)
 
 	if req.Status != "" {
@@ -690,6 +718,14 @@ func (h *ProductHandler) GetDetail(c *fiber.Ctx) error {
 		}
 	}
 
+	// Parse add-ons JSON
+	addons := []AddonReq{}
+	if len(product.Addons) > 0 {
+		if err := json.Unmarshal(product.Addons, &addons); err != nil {
+
+		}
+	}
+
 	// Parse portfolio JSON
 	var portfolio map[string]interface{}
 	if len(product.Portfolio) > 0 {
@@ -748,6 +784,7 @@ func (h *ProductHandler) GetDetail(c *fiber.Ctx) error {
 			"cover_url":              product.CoverURL,
 			"cover_transform":        coverTransform,
 			"packages":               packages,
+			"addons":                 addons,
 			"portfolio":              portfolio,
 			"status":                 product.Status,
 			"rating":                 ratingStats.AvgRating,
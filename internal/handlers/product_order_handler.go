@@ -0,0 +1,314 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/models"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/wallet"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ProductOrderHandler checks out product packages directly, without a custom offer in chat
+type ProductOrderHandler struct {
+	DB       *gorm.DB
+	Offers   *JobOfferHandler
+	Payments *PaymentHandler
+}
+
+func NewProductOrderHandler(db *gorm.DB, offers *JobOfferHandler, payments *PaymentHandler) *ProductOrderHandler {
+	return &ProductOrderHandler{DB: db, Offers: offers, Payments: payments}
+}
+
+type ProductOrderRequest struct {
+	Package string   `json:"package"` // basic, standard or premium
+	Addons  []string `json:"addons"`  // IDs of the product add-ons
+	Notes   string   `json:"notes"`
+
+	// Payment session, as in CreatePaymentRequest
+	PaymentMethod string `json:"payment_method"`
+	UseBalance    bool   `json:"use_balance"`
+	VoucherCode   string `json:"voucher_code"`
+}
+
+// orderTerms are the offer terms of a package with its add-ons
+type orderTerms struct {
+	Package   PackageReq
+	Addons    []AddonReq
+	Price     int64
+	Days      int
+	Revisions int
+}
+
+// packageTerms resolves the package and add-ons a client picked on a product
+func packageTerms(product *models.Product, name string, addonIDs []string) (*orderTerms, error) {
+	var packages map[string]PackageReq
+	if len(product.Packages) > 0 {
+		if err := json.Unmarshal(product.Packages, &packages); err != nil {
+			return nil, fmt.Errorf("product packages are invalid")
+		}
+	}
+	pkg, ok := packages[name]
+	if !ok || pkg.Price <= 0 || pkg.DeliveryDays <= 0 {
+		return nil, fmt.Errorf("package %q is not available on this product", name)
+	}
+	terms := &orderTerms{Package: pkg, Price: pkg.Price, Days: pkg.DeliveryDays, Revisions: pkg.Revisions}
+
+	var addons []AddonReq
+	if len(product.Addons) > 0 {
+		if err := json.Unmarshal(product.Addons, &addons); err != nil {
+			return nil, fmt.Errorf("product add-ons are invalid")
+		}
+	}
+	picked := map[string]bool{}
+	for _, id := range addonIDs {
+		if picked[id] {
+			continue
+		}
+		found := false
+		for _, a := range addons {
+			if a.ID == id {
+				terms.Addons = append(terms.Addons, a)
+				terms.Price += a.Price
+				terms.Days += a.DeliveryDays
+				terms.Revisions += a.Revisions
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("add-on %q is not available on this product", id)
+		}
+		picked[id] = true
+	}
+	return terms, nil
+}
+
+// description lists the package and add-ons ordered
+func (t *orderTerms) description() string {
+	lines := []string{t.Package.Description}
+	if len(t.Package.Benefits) > 0 {
+		lines = append(lines, "", "Termasuk:")
+		for _, b := range t.Package.Benefits {
+			lines = append(lines, "- "+b)
+		}
+	}
+	if len(t.Addons) > 0 {
+		lines = append(lines, "", "Tambahan:")
+		for _, a := range t.Addons {
+			lines = append(lines, fmt.Sprintf("- %s (Rp %d)", a.Title, a.Price))
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// OrderPackage lets a client order a product package (and optional add-ons) directly. It checks
+// the voucher and payment method, opens (or reuses) the conversation with the freelancer,
+// creates the offer from the package terms and returns a payment session for it.
+func (h *ProductOrderHandler) OrderPackage(c *fiber.Ctx) error {
+	clientID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	rawID, err := utils.DecryptID(c.Params("id"), os.Getenv("ID_ENCRYPT_KEY"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid product ID"})
+	}
+
+	var req ProductOrderRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid request body"})
+	}
+	if req.PaymentMethod == "" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Payment method is required"})
+	}
+
+	var product models.Product
+	if err := h.DB.First(&product, "id = ? AND status = ?", rawID, "published").Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "message": "Product not found"})
+	}
+	if product.UserID == clientID {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "You cannot order your own product"})
+	}
+
+	terms, err := packageTerms(&product, req.Package, req.Addons)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": err.Error()})
+	}
+
+	criteria, err := h.Offers.Commission.CriteriaFor(product.UserID, &product.ID, terms.Price)
+	if err != nil {
+		log.Println("Error loading commission criteria:", err)
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to calculate platform fee"})
+	}
+	quote, err := h.Offers.Commission.Resolve(criteria)
+	if err != nil {
+		log.Println("Error resolving commission rule:", err)
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to calculate platform fee"})
+	}
+	expiresAt, err := h.Offers.offerExpiry(0)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": err.Error()})
+	}
+
+	addonsData, _ := json.Marshal(terms.Addons)
+	today := utils.StartOfDay(time.Now())
+	title := product.Title
+	if terms.Package.Title != "" {
+		title += " - " + terms.Package.Title
+	}
+
+	offer := models.JobOffer{
+		FreelancerID:  product.UserID,
+		ClientID:      clientID,
+		ProductID:     &product.ID,
+		Package:       req.Package,
+		Addons:        string(addonsData),
+		Price:         terms.Price,
+		Title:         title,
+		Description:   terms.description(),
+		RevisionCount: terms.Revisions,
+		StartDate:     today,
+		DeliveryDate:  today.AddDate(0, 0, terms.Days),
+		Notes:         req.Notes,
+		Status:        models.OfferStatusPending,
+		ExpiresAt:     expiresAt,
+	}
+	quote.Apply(&offer)
+
+	// Nothing is created unless the payment can start
+	if err := h.precheck(&offer, req); err != nil {
+		return paymentErrorResponse(c, err)
+	}
+
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		conv, err := conversationFor(tx, clientID, product.UserID, &product.ID)
+		if err != nil {
+			return err
+		}
+		offer.OrderCode = uniqueOrderCode(tx)
+		offer.ConversationID = conv.ID
+		return tx.Create(&offer).Error
+	})
+	if err != nil {
+		log.Println("Error creating product order:", err)
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to create order"})
+	}
+
+	h.DB.Preload("Freelancer").Preload("Freelancer.FreelancerProfile").
+		Preload("Client").Preload("Product").
+		First(&offer, "id = ?", offer.ID)
+
+	session, err := h.Payments.checkout(&offer, CreatePaymentRequest{
+		OfferID:       offer.ID.String(),
+		PaymentMethod: req.PaymentMethod,
+		UseBalance:    req.UseBalance,
+		VoucherCode:   req.VoucherCode,
+	})
+	if err != nil {
+		// e.g. the gateway is down: drop the order instead of leaving an unpaid offer in the chat
+		h.discardOrder(&offer)
+		return paymentErrorResponse(c, err)
+	}
+
+	h.DB.First(&offer, "id = ?", offer.ID)
+	h.Offers.postOfferMarker(&offer, clientID, offerMarker, offer.ID)
+
+	return c.Status(201).JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"offer":           toJobOfferResponse(&offer),
+			"conversation_id": offer.ConversationID,
+			"payment":         session,
+		},
+	})
+}
+
+// precheck validates the voucher and payment method (or balance) of an order before its offer
+// is created, so a checkout that cannot start does not leave a pending offer behind
+func (h *ProductOrderHandler) precheck(offer *models.JobOffer, req ProductOrderRequest) error {
+	var discount int64
+	if req.VoucherCode != "" {
+		app, err := h.Payments.Vouchers.Validate(req.VoucherCode, offer, offer.ClientID)
+		if err != nil {
+			return err
+		}
+		discount = app.Discount
+	}
+	payable := offer.CheckoutAmount() - discount
+
+	if req.PaymentMethod != models.PaymentMethodBalance {
+		if payable == 0 {
+			return nil
+		}
+		_, err := h.Payments.activeChannel(req.PaymentMethod)
+		return err
+	}
+
+	var client models.User
+	if err := h.DB.Select("id", "balance").First(&client, "id = ?", offer.ClientID).Error; err != nil {
+		return checkoutError(err, "Failed to check balance")
+	}
+	if client.Balance < payable {
+		return wallet.ErrInsufficientBalance
+	}
+	return nil
+}
+
+// discardOrder cancels the offer of an order whose payment could not be started after all
+func (h *ProductOrderHandler) discardOrder(offer *models.JobOffer) {
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		var current models.JobOffer
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, "id = ?", offer.ID).Error; err != nil {
+			return err
+		}
+		if current.Status != models.OfferStatusPending {
+			return nil
+		}
+		if err := current.Transition(models.OfferStatusCancelled, models.OfferActorSystem); err != nil {
+			return err
+		}
+		if err := h.Offers.closeOfferPayments(tx, &current, "Pembayaran pesanan tidak dapat dibuat"); err != nil {
+			return err
+		}
+		return tx.Save(&current).Error
+	})
+	if err != nil {
+		log.Printf("Failed to discard product order %s: %v", offer.OrderCode, err)
+	}
+}
+
+// conversationFor returns the latest conversation between a client and a freelancer, creating
+// one when they have never talked (see ChatHandler.CreateOrGetConversation)
+func conversationFor(tx *gorm.DB, clientID, freelancerID uuid.UUID, productID *uint) (*models.Conversation, error) {
+	var conv models.Conversation
+	err := tx.Where("client_id = ? AND freelancer_id = ?", clientID, freelancerID).
+		Order("updated_at DESC").
+		First(&conv).Error
+	if err == nil {
+		return &conv, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	conv = models.Conversation{
+		ClientID:      clientID,
+		FreelancerID:  freelancerID,
+		ProductID:     productID,
+		LastMessageAt: time.Now(),
+	}
+	if err := tx.Create(&conv).Error; err != nil {
+		return nil, err
+	}
+	return &conv, nil
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"math"
+
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/models"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/reconciliation"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ReconciliationHandler lets admins run and inspect wallet/escrow reconciliation reports
+type ReconciliationHandler struct {
+	DB      *gorm.DB
+	Service *reconciliation.ReconciliationService
+}
+
+func NewReconciliationHandler(db *gorm.DB, service *reconciliation.ReconciliationService) *ReconciliationHandler {
+	return &ReconciliationHandler{DB: db, Service: service}
+}
+
+// ListReports returns reconciliation reports (without issue details), newest first
+func (h *ReconciliationHandler) ListReports(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 20)
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 20
+	}
+	offset := (page - 1) * limit
+
+	q := h.DB.Model(&models.ReconciliationReport{})
+	if status := c.Query("status"); status != "" {
+		q = q.Where("status = ?", status)
+	}
+
+	var total int64
+	q.Count(&total)
+
+	var reports []models.ReconciliationReport
+	if err := q.Omit("issues").Order("started_at DESC").Limit(limit).Offset(offset).Find(&reports).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to fetch reconciliation reports"})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    reports,
+		"meta": fiber.Map{
+			"page":        page,
+			"limit":       limit,
+			"total_items": total,
+			"total_pages": int(math.Ceil(float64(total) / float64(limit))),
+		},
+	})
+}
+
+// GetReport returns one report including every issue found.
+// Use "latest" as the id to fetch the most recent run.
+func (h *ReconciliationHandler) GetReport(c *fiber.Ctx) error {
+	q := h.DB.Model(&models.ReconciliationReport{})
+
+	if id := c.Params("id"); id == "latest" {
+		q = q.Order("started_at DESC")
+	} else {
+		reportID, err := uuid.Parse(id)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid report ID"})
+		}
+		q = q.Where("id = ?", reportID)
+	}
+
+	var report models.ReconciliationReport
+	if err := q.First(&report).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "message": "Reconciliation report not found"})
+	}
+
+	return c.JSON(fiber.Map{"success": true, "data": report})
+}
+
+// RunNow triggers a reconciliation run on demand and returns its report
+func (h *ReconciliationHandler) RunNow(c *fiber.Ctx) error {
+	report, err := h.Service.Run("manual")
+	if report == nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to start reconciliation"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Reconciliation failed", "data": report})
+	}
+
+	return c.JSON(fiber.Map{"success": true, "message": "Reconciliation finished", "data": report})
+}
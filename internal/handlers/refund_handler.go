@@ -0,0 +1,517 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/models"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/gateway"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/refund"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RefundHandler struct {
+	DB          *gorm.DB
+	Gateway     gateway.PaymentGateway
+	Refunds     *refund.RefundService
+	EncryptKey  string
+	TransferFee int64 // Deducted from refunds sent to a bank account / e-wallet
+}
+
+func NewRefundHandler(db *gorm.DB, paymentGateway gateway.PaymentGateway, refundService *refund.RefundService, encryptKey string, transferFee int64) *RefundHandler {
+	return &RefundHandler{DB: db, Gateway: paymentGateway, Refunds: refundService, EncryptKey: encryptKey, TransferFee: transferFee}
+}
+
+type SubmitRefundRequest struct {
+	Destination   string `json:"destination"`    // balance | original | bank
+	Type          string `json:"type"`           // bank | ewallet (destination bank only)
+	ProviderCode  string `json:"provider_code"`  // destination bank only
+	AccountNumber string `json:"account_number"` // destination bank only
+	HolderName    string `json:"holder_name"`    // destination bank only
+}
+
+type processRefundRequest struct {
+	Note      string `json:"note"`
+	Reference string `json:"reference"`
+	Reason    string `json:"reason"`
+}
+
+type CreatePartialRefundRequest struct {
+	OfferID string `json:"offer_id"`
+	Amount  int64  `json:"amount"`
+	Reason  string `json:"reason"`
+}
+
+// ListMyRefunds returns the client's refund requests
+func (h *RefundHandler) ListMyRefunds(c *fiber.Ctx) error {
+	userID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+	return h.list(c, h.DB.Model(&models.RefundRequest{}).Where("client_id = ?", userID))
+}
+
+// SubmitRefund lets the client choose where a refund goes. Refunds to the platform
+// balance are paid out immediately; the other destinations need finance approval.
+func (h *RefundHandler) SubmitRefund(c *fiber.Ctx) error {
+	userID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var req SubmitRefundRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid request body"})
+	}
+
+	return h.transition(c, func(tx *gorm.DB, r *models.RefundRequest) error {
+		if r.ClientID != userID {
+			return fiber.NewError(404, "Refund not found")
+		}
+		if r.Status != models.RefundStatusAwaitingClient {
+			return fiber.NewError(400, "Refund destination has already been chosen")
+		}
+
+		r.Destination = models.RefundDestination(strings.ToLower(strings.TrimSpace(req.Destination)))
+		r.TransferFee = 0
+		r.DestinationType, r.ProviderCode, r.AccountHolder = "", "", ""
+		r.AccountNumberEnc, r.AccountNumberMasked = "", ""
+
+		switch r.Destination {
+		case models.RefundToBalance:
+			return h.Refunds.Complete(tx, r, "")
+
+		case models.RefundToOriginal:
+			if err := h.checkOriginalRefundable(tx, r); err != nil {
+				return err
+			}
+
+		case models.RefundToBank:
+			if err := h.setBankDestination(r, &req); err != nil {
+				return err
+			}
+
+		default:
+			return fiber.NewError(400, "destination must be balance, original or bank")
+		}
+
+		r.Status = models.RefundStatusPending
+		return nil
+	})
+}
+
+// AdminListRefunds returns all refund requests, optionally filtered by status
+func (h *RefundHandler) AdminListRefunds(c *fiber.Ctx) error {
+	return h.list(c, h.DB.Model(&models.RefundRequest{}).Preload("JobOffer").Preload("Client"))
+}
+
+// CreatePartialRefund refunds part of an order that is still in progress (e.g. as
+// compensation). The rest of the escrow is released to the freelancer as usual.
+func (h *RefundHandler) CreatePartialRefund(c *fiber.Ctx) error {
+	adminID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var req CreatePartialRefundRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid request body"})
+	}
+	offerID, err := uuid.Parse(req.OfferID)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid offer ID"})
+	}
+	if req.Amount <= 0 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Amount is required and must be positive"})
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Reason is required"})
+	}
+
+	var r *models.RefundRequest
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		var offer models.JobOffer
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&offer, "id = ?", offerID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fiber.NewError(404, "Offer not found")
+			}
+			return err
+		}
+
+		switch offer.Status {
+		case models.OfferStatusPaid, models.OfferStatusWorking, models.OfferStatusDelivered:
+		default:
+			return fiber.NewError(400, "Only orders with funds in escrow can be partially refunded")
+		}
+		if req.Amount >= offer.EscrowRemaining() {
+			return fiber.NewError(400, "Partial refund must be less than the escrow left; cancel the order for a full refund")
+		}
+
+		var err error
+		r, err = h.Refunds.Open(tx, &offer, req.Amount, false, req.Reason, &adminID)
+		return err
+	})
+
+	if err != nil {
+		if e, ok := err.(*fiber.Error); ok {
+			return c.Status(e.Code).JSON(fiber.Map{"success": false, "message": e.Message})
+		}
+		log.Printf("[Refund] Failed to create partial refund for offer %s: %v", offerID, err)
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to create refund"})
+	}
+
+	return c.Status(201).JSON(fiber.Map{"success": true, "message": "Refund created", "data": r})
+}
+
+// ApproveRefund approves a pending refund. Refunds to the original payment method go
+// through the gateway's refund API when it has one; otherwise finance pays them manually.
+//
+// The gateway is never called inside a DB transaction: the refund is first claimed
+// (pending -> processing) and committed, then refunded with its ID as idempotency key and
+// the result recorded in a second transaction. A refund left in processing (e.g. the API
+// stopped before recording the result) can be approved again; the idempotency key makes
+// the gateway return the first refund instead of paying twice.
+func (h *RefundHandler) ApproveRefund(c *fiber.Ctx) error {
+	adminID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	refundID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid refund ID"})
+	}
+
+	var req processRefundRequest
+	_ = c.BodyParser(&req)
+
+	refunder, viaGateway := h.Gateway.(gateway.Refunder)
+	r, err := h.update(refundID, func(tx *gorm.DB, r *models.RefundRequest) error {
+		if r.Status == models.RefundStatusProcessing {
+			return nil // Retry of the gateway call
+		}
+		if r.Status != models.RefundStatusPending {
+			return fiber.NewError(400, "Only pending refunds can be approved")
+		}
+
+		now := time.Now()
+		r.Status = models.RefundStatusApproved
+		r.AdminNote = req.Note
+		r.ProcessedBy = &adminID
+		r.ProcessedAt = &now
+		if r.Destination == models.RefundToOriginal && viaGateway {
+			r.Status = models.RefundStatusProcessing
+		}
+		return nil
+	})
+	if err != nil || r.Status != models.RefundStatusProcessing {
+		return h.respond(c, refundID, r, err)
+	}
+
+	var trx models.Transaction
+	if err := h.DB.First(&trx, "id = ?", r.TransactionID).Error; err != nil {
+		return h.respond(c, refundID, r, err)
+	}
+	refundRef, gatewayErr := refunder.Refund(trx.Reference, r.PayoutAmount(), r.Reason, r.ID.String())
+
+	r, err = h.update(refundID, func(tx *gorm.DB, r *models.RefundRequest) error {
+		if r.Status != models.RefundStatusProcessing {
+			return fiber.NewError(409, "Refund is no longer being processed")
+		}
+		if gatewayErr != nil {
+			// Back to pending so finance can retry or send the client elsewhere
+			r.Status = models.RefundStatusPending
+			r.AdminNote = "Refund gateway gagal: " + gatewayErr.Error()
+			return nil
+		}
+		return h.Refunds.Complete(tx, r, refundRef)
+	})
+	if err == nil && gatewayErr != nil {
+		log.Printf("[Refund] Gateway refund for %s failed: %v", refundID, gatewayErr)
+		return c.Status(502).JSON(fiber.Map{"success": false, "message": "Payment gateway refund failed: " + gatewayErr.Error(), "data": r})
+	}
+	if err != nil && gatewayErr == nil {
+		log.Printf("[Refund] Gateway refund %s for %s succeeded but could not be recorded: %v", refundRef, refundID, err)
+	}
+	return h.respond(c, refundID, r, err)
+}
+
+// AdminGetRefundAccount reveals the full account number of a refund sent to a bank account
+// or e-wallet so finance can make the transfer. Every view is recorded.
+func (h *RefundHandler) AdminGetRefundAccount(c *fiber.Ctx) error {
+	refundID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid refund ID"})
+	}
+
+	var r models.RefundRequest
+	if err := h.DB.First(&r, "id = ?", refundID).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "message": "Refund not found"})
+	}
+	if r.Destination != models.RefundToBank {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Refund is not sent to a bank account or e-wallet"})
+	}
+
+	number, err := revealAccountNumber(h.DB, c, h.EncryptKey, "refund", r.ID, r.AccountNumberEnc)
+	if err != nil {
+		if e, ok := err.(*fiber.Error); ok {
+			return c.Status(e.Code).JSON(fiber.Map{"success": false, "message": e.Message})
+		}
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"destination_type": r.DestinationType,
+			"provider_code":    r.ProviderCode,
+			"account_holder":   r.AccountHolder,
+			"account_number":   number,
+			"payout_amount":    r.PayoutAmount(),
+		},
+	})
+}
+
+// RejectRefund sends a pending refund back to the client to choose another destination
+// (e.g. invalid bank account). The money stays reserved for the client.
+func (h *RefundHandler) RejectRefund(c *fiber.Ctx) error {
+	adminID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var req processRefundRequest
+	_ = c.BodyParser(&req)
+	if strings.TrimSpace(req.Note) == "" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Rejection note is required"})
+	}
+
+	return h.transition(c, func(tx *gorm.DB, r *models.RefundRequest) error {
+		if r.Status != models.RefundStatusPending {
+			return fiber.NewError(400, "Only pending refunds can be rejected")
+		}
+
+		now := time.Now()
+		r.Status = models.RefundStatusAwaitingClient
+		r.AdminNote = req.Note
+		r.ProcessedBy = &adminID
+		r.ProcessedAt = &now
+		return nil
+	})
+}
+
+// MarkRefundCompleted records a manual refund (bank transfer or gateway dashboard)
+func (h *RefundHandler) MarkRefundCompleted(c *fiber.Ctx) error {
+	adminID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var req processRefundRequest
+	_ = c.BodyParser(&req)
+	if strings.TrimSpace(req.Reference) == "" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "reference is required"})
+	}
+
+	return h.transition(c, func(tx *gorm.DB, r *models.RefundRequest) error {
+		if r.Status != models.RefundStatusApproved {
+			return fiber.NewError(400, "Only approved refunds can be marked as completed")
+		}
+
+		r.ProcessedBy = &adminID
+		return h.Refunds.Complete(tx, r, req.Reference)
+	})
+}
+
+// MarkRefundFailed records a failed manual refund; the client chooses a destination again
+func (h *RefundHandler) MarkRefundFailed(c *fiber.Ctx) error {
+	adminID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var req processRefundRequest
+	_ = c.BodyParser(&req)
+	if strings.TrimSpace(req.Reason) == "" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Failure reason is required"})
+	}
+
+	return h.transition(c, func(tx *gorm.DB, r *models.RefundRequest) error {
+		if r.Status != models.RefundStatusApproved {
+			return fiber.NewError(400, "Only approved refunds can be marked as failed")
+		}
+
+		now := time.Now()
+		r.Status = models.RefundStatusAwaitingClient
+		r.AdminNote = "Refund gagal: " + req.Reason
+		r.ProcessedBy = &adminID
+		r.ProcessedAt = &now
+		return nil
+	})
+}
+
+// ===== Helpers =====
+
+// checkOriginalRefundable makes sure the original payment went through the gateway and
+// can still absorb this refund (the wallet part of a split payment can only go to balance).
+func (h *RefundHandler) checkOriginalRefundable(tx *gorm.DB, r *models.RefundRequest) error {
+	if r.TransactionID == nil {
+		return fiber.NewError(400, "Original payment not found, choose balance or bank")
+	}
+
+	var trx models.Transaction
+	if err := tx.First(&trx, "id = ?", r.TransactionID).Error; err != nil {
+		return err
+	}
+	if trx.PaymentMethod == models.PaymentMethodBalance {
+		return fiber.NewError(400, "Order was paid with balance, choose balance as destination")
+	}
+
+	var alreadyRefunded int64
+	if err := tx.Model(&models.RefundRequest{}).
+		Where("transaction_id = ? AND id <> ? AND destination = ? AND status IN ?", trx.ID, r.ID, models.RefundToOriginal,
+			[]models.RefundStatus{models.RefundStatusPending, models.RefundStatusProcessing, models.RefundStatusApproved, models.RefundStatusCompleted}).
+		Select("COALESCE(SUM(amount + fee_refund - transfer_fee), 0)").
+		Scan(&alreadyRefunded).Error; err != nil {
+		return err
+	}
+
+	if alreadyRefunded+r.PayoutAmount() > trx.TotalAmount {
+		return fiber.NewError(400, "Refund exceeds what was paid through the payment method, choose balance or bank")
+	}
+	return nil
+}
+
+// setBankDestination validates and stores (encrypted) the client's bank / e-wallet account
+func (h *RefundHandler) setBankDestination(r *models.RefundRequest, req *SubmitRefundRequest) error {
+	accountType := models.PayoutAccountType(strings.ToLower(strings.TrimSpace(req.Type)))
+	providerCode := strings.ToUpper(strings.TrimSpace(req.ProviderCode))
+	holder := strings.TrimSpace(req.HolderName)
+
+	providers, ok := models.PayoutProviders[accountType]
+	if !ok {
+		return fiber.NewError(400, "type must be bank or ewallet")
+	}
+	if _, ok := providers[providerCode]; !ok {
+		return fiber.NewError(400, "Unsupported provider_code for "+string(accountType))
+	}
+
+	number := normalizePhone(req.AccountNumber)
+	switch accountType {
+	case models.PayoutAccountBank:
+		if !bankAccountRe.MatchString(number) {
+			return fiber.NewError(400, "account_number must be 6-20 digits")
+		}
+	case models.PayoutAccountEWallet:
+		if !ewalletAccountRe.MatchString(number) {
+			return fiber.NewError(400, "account_number must be a valid phone number registered to the e-wallet")
+		}
+	}
+	if holder == "" {
+		return fiber.NewError(400, "holder_name is required")
+	}
+
+	if r.Amount+r.FeeRefund <= h.TransferFee {
+		return fiber.NewError(400, "Refund is too small for a bank transfer, choose balance")
+	}
+
+	enc, err := utils.EncryptString(number, h.EncryptKey)
+	if err != nil {
+		return err
+	}
+
+	r.TransferFee = h.TransferFee
+	r.DestinationType = accountType
+	r.ProviderCode = providerCode
+	r.AccountHolder = holder
+	r.AccountNumberEnc = enc
+	r.AccountNumberMasked = utils.MaskAccountNumber(number)
+	return nil
+}
+
+// transition locks the refund from the :id param, applies fn and saves the result
+// in a single DB transaction.
+func (h *RefundHandler) transition(c *fiber.Ctx, fn func(tx *gorm.DB, r *models.RefundRequest) error) error {
+	refundID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid refund ID"})
+	}
+
+	r, err := h.update(refundID, fn)
+	return h.respond(c, refundID, r, err)
+}
+
+// update locks the refund (SELECT ... FOR UPDATE), applies fn and saves the result in a
+// single DB transaction
+func (h *RefundHandler) update(refundID uuid.UUID, fn func(tx *gorm.DB, r *models.RefundRequest) error) (*models.RefundRequest, error) {
+	var r models.RefundRequest
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&r, "id = ?", refundID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fiber.NewError(404, "Refund not found")
+			}
+			return err
+		}
+
+		if err := fn(tx, &r); err != nil {
+			return err
+		}
+
+		return tx.Save(&r).Error
+	})
+	return &r, err
+}
+
+func (h *RefundHandler) respond(c *fiber.Ctx, refundID uuid.UUID, r *models.RefundRequest, err error) error {
+	if err != nil {
+		if e, ok := err.(*fiber.Error); ok {
+			return c.Status(e.Code).JSON(fiber.Map{"success": false, "message": e.Message})
+		}
+		log.Printf("[Refund] Failed to process refund %s: %v", refundID, err)
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to process refund"})
+	}
+
+	return c.JSON(fiber.Map{"success": true, "data": r})
+}
+
+func (h *RefundHandler) list(c *fiber.Ctx, q *gorm.DB) error {
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 20)
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 20
+	}
+	offset := (page - 1) * limit
+
+	if status := c.Query("status"); status != "" {
+		q = q.Where("status = ?", status)
+	}
+
+	var total int64
+	q.Count(&total)
+
+	var refunds []models.RefundRequest
+	if err := q.Order("created_at DESC").Limit(limit).Offset(offset).Find(&refunds).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to fetch refunds"})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    refunds,
+		"meta": fiber.Map{
+			"page":        page,
+			"limit":       limit,
+			"total_items": total,
+			"total_pages": int(math.Ceil(float64(total) / float64(limit))),
+		},
+	})
+}
@@ -0,0 +1,360 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/models"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/commission"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/wallet"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RequestRevision handles revision request by client. The reason can be sent as JSON or as
+// a multipart form with "attachments" files.
+func (h *JobOfferHandler) RequestRevision(c *fiber.Ctx) error {
+	userUUID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+	offerUUID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid offer ID"})
+	}
+
+	var req struct {
+		Reason string `json:"reason" form:"reason"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.Reason == "" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Revision reason is required"})
+	}
+
+	attachments, err := saveFormFiles(c, "attachments", "revisions")
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": err.Error()})
+	}
+	attachmentsJSON, _ := json.Marshal(attachments)
+
+	var offer models.JobOffer
+	var revision models.Revision
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&offer, "id = ?", offerUUID).Error; err != nil {
+			return fiber.NewError(404, "Offer not found")
+		}
+
+		if offer.ClientID != userUUID {
+			return fiber.NewError(403, "Only the client can request revisions")
+		}
+		if offer.HasMilestones() {
+			return fiber.NewError(400, "Revisions of this order are requested per milestone")
+		}
+		if offer.CanTransition(models.OfferStatusWorking, models.OfferActorClient) != nil {
+			return fiber.NewError(400, "Revision can only be requested for delivered work")
+		}
+		if offer.UsedRevisionCount >= offer.RevisionCount {
+			return fiber.NewError(400, "Revision limit reached. No more revisions available for this offer.")
+		}
+
+		// Update Offer
+		if err := offer.Transition(models.OfferStatusWorking, models.OfferActorClient); err != nil {
+			return fiber.NewError(400, err.Error())
+		}
+		offer.UsedRevisionCount++
+		if err := tx.Save(&offer).Error; err != nil {
+			return err
+		}
+
+		revision = models.Revision{
+			JobOfferID:  offer.ID,
+			Number:      offer.UsedRevisionCount,
+			RequestedBy: userUUID,
+			Reason:      req.Reason,
+			Attachments: string(attachmentsJSON),
+			Status:      models.RevisionStatusOpen,
+			RequestedAt: offer.UpdatedAt,
+		}
+		return tx.Create(&revision).Error
+	})
+	if err != nil {
+		if e, ok := err.(*fiber.Error); ok {
+			return c.Status(e.Code).JSON(fiber.Map{"success": false, "message": e.Message})
+		}
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to update offer status"})
+	}
+
+	// Revision Message
+	h.postOrderMessage(&offer, userUUID, "revision", req.Reason)
+
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"offer": toJobOfferResponse(&offer), "revision": revision}})
+}
+
+// ListRevisions returns the revision history of an order, with the extra revisions offered on it
+func (h *JobOfferHandler) ListRevisions(c *fiber.Ctx) error {
+	userID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var offer models.JobOffer
+	if err := h.DB.First(&offer, "id = ?", c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "message": "Offer not found"})
+	}
+	if offer.ClientID != userID && offer.FreelancerID != userID && c.Locals("role") != string(models.RoleAdmin) {
+		return c.Status(403).JSON(fiber.Map{"success": false, "message": "Access denied"})
+	}
+
+	var revisions []models.Revision
+	if err := h.DB.Where("job_offer_id = ?", offer.ID).Order("number ASC").Find(&revisions).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to fetch revisions"})
+	}
+
+	var purchases []models.RevisionPurchase
+	h.DB.Where("job_offer_id = ?", offer.ID).Order("created_at ASC").Find(&purchases)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"revision_count":      offer.RevisionCount,
+			"used_revision_count": offer.UsedRevisionCount,
+			"revisions":           revisions,
+			"extra_revisions":     purchases,
+		},
+	})
+}
+
+type OfferExtraRevisionsRequest struct {
+	Count int    `json:"count"`
+	Price int64  `json:"price"` // 0 grants the revisions for free
+	Note  string `json:"note"`
+}
+
+// OfferExtraRevisions lets the freelancer grant revisions beyond the package limit. Free ones
+// apply right away; paid ones wait for the client to accept and pay from their balance.
+// Milestone orders are excluded: their revisions are counted per milestone.
+func (h *JobOfferHandler) OfferExtraRevisions(c *fiber.Ctx) error {
+	userID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var req OfferExtraRevisionsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid request body"})
+	}
+	if req.Count < 1 || req.Count > 10 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Count must be between 1 and 10"})
+	}
+	if req.Price < 0 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Price cannot be negative"})
+	}
+
+	var offer models.JobOffer
+	var purchase models.RevisionPurchase
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&offer, "id = ?", c.Params("id")).Error; err != nil {
+			return fiber.NewError(404, "Offer not found")
+		}
+		if offer.FreelancerID != userID {
+			return fiber.NewError(403, "Only the assigned freelancer can offer extra revisions")
+		}
+		if !acceptsExtras(offer.Status) {
+			return fiber.NewError(400, "Extra revisions can only be offered on an active order")
+		}
+		if offer.HasMilestones() {
+			return fiber.NewError(400, "Milestone orders use the revision limit of each milestone, extra revisions are not available")
+		}
+
+		purchase = models.RevisionPurchase{
+			JobOfferID:  offer.ID,
+			Count:       req.Count,
+			Price:       req.Price,
+			PlatformFee: commission.Fee(req.Price, offer.CommissionRateBps, 0),
+			Note:        req.Note,
+			Status:      models.RevisionPurchaseOffered,
+			OfferedBy:   userID,
+		}
+		if req.Price == 0 {
+			now := time.Now()
+			purchase.Status = models.RevisionPurchaseAccepted
+			purchase.RespondedAt = &now
+			offer.RevisionCount += req.Count
+			if err := tx.Save(&offer).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(&purchase).Error
+	})
+	if err != nil {
+		if e, ok := err.(*fiber.Error); ok {
+			return c.Status(e.Code).JSON(fiber.Map{"success": false, "message": e.Message})
+		}
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to offer extra revisions"})
+	}
+
+	text := fmt.Sprintf("Freelancer memberikan %d revisi tambahan secara gratis.", req.Count)
+	if req.Price > 0 {
+		text = fmt.Sprintf("Freelancer menawarkan %d revisi tambahan seharga Rp %d. Pembeli dapat menyetujui dan membayar dari saldo Jokiin.", req.Count, req.Price)
+	}
+	h.postOrderMessage(&offer, userID, "system", text)
+
+	return c.JSON(fiber.Map{"success": true, "data": purchase})
+}
+
+// AcceptExtraRevisions lets the client buy offered extra revisions with their wallet balance.
+// The price is held in escrow with the order and released (minus commission) on completion.
+func (h *JobOfferHandler) AcceptExtraRevisions(c *fiber.Ctx) error {
+	userID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var offer models.JobOffer
+	var purchase models.RevisionPurchase
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := h.lockRevisionPurchase(tx, c, userID, &offer, &purchase); err != nil {
+			return err
+		}
+		if !acceptsExtras(offer.Status) {
+			return fiber.NewError(400, "The order is no longer active")
+		}
+		if offer.HasMilestones() {
+			return fiber.NewError(400, "Milestone orders use the revision limit of each milestone, extra revisions are not available")
+		}
+
+		desc := fmt.Sprintf("Pembelian %d revisi tambahan pesanan #%s", purchase.Count, offer.OrderCode)
+		if err := h.WalletService.DebitClient(tx, offer.ClientID, purchase.Price, offer.ID, desc); err != nil {
+			return err
+		}
+		if err := h.Ledger.RecordEscrowDeposit(tx, &offer, purchase.Price, desc); err != nil {
+			return err
+		}
+
+		offer.RevisionCount += purchase.Count
+		offer.ExtrasAmount += purchase.Price
+		offer.ExtrasFee += purchase.PlatformFee
+		if err := tx.Save(&offer).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		purchase.Status = models.RevisionPurchaseAccepted
+		purchase.RespondedAt = &now
+		return tx.Save(&purchase).Error
+	})
+	if err != nil {
+		if errors.Is(err, wallet.ErrInsufficientBalance) {
+			return c.Status(400).JSON(fiber.Map{"success": false, "message": "Saldo tidak mencukupi, silakan isi saldo terlebih dahulu"})
+		}
+		if e, ok := err.(*fiber.Error); ok {
+			return c.Status(e.Code).JSON(fiber.Map{"success": false, "message": e.Message})
+		}
+		log.Printf("Failed to accept extra revisions: %v", err)
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to accept extra revisions"})
+	}
+
+	text := fmt.Sprintf("Pembeli membeli %d revisi tambahan. Dana Rp %d ditahan di Escrow bersama pesanan.", purchase.Count, purchase.Price)
+	h.postOrderMessage(&offer, userID, "system", text)
+
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"offer": toJobOfferResponse(&offer), "extra_revision": purchase}})
+}
+
+// DeclineExtraRevisions lets the client turn down offered extra revisions
+func (h *JobOfferHandler) DeclineExtraRevisions(c *fiber.Ctx) error {
+	userID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var offer models.JobOffer
+	var purchase models.RevisionPurchase
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := h.lockRevisionPurchase(tx, c, userID, &offer, &purchase); err != nil {
+			return err
+		}
+		now := time.Now()
+		purchase.Status = models.RevisionPurchaseDeclined
+		purchase.RespondedAt = &now
+		return tx.Save(&purchase).Error
+	})
+	if err != nil {
+		if e, ok := err.(*fiber.Error); ok {
+			return c.Status(e.Code).JSON(fiber.Map{"success": false, "message": e.Message})
+		}
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to decline extra revisions"})
+	}
+
+	h.postOrderMessage(&offer, userID, "system", "Pembeli menolak tawaran revisi tambahan.")
+
+	return c.JSON(fiber.Map{"success": true, "data": purchase})
+}
+
+// lockRevisionPurchase loads an offered extra-revision purchase for the client to answer
+func (h *JobOfferHandler) lockRevisionPurchase(tx *gorm.DB, c *fiber.Ctx, clientID uuid.UUID, offer *models.JobOffer, purchase *models.RevisionPurchase) error {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(offer, "id = ?", c.Params("id")).Error; err != nil {
+		return fiber.NewError(404, "Offer not found")
+	}
+	if offer.ClientID != clientID {
+		return fiber.NewError(403, "Only the client can answer extra revision offers")
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(purchase, "id = ? AND job_offer_id = ?", c.Params("purchaseId"), offer.ID).Error; err != nil {
+		return fiber.NewError(404, "Extra revision offer not found")
+	}
+	if purchase.Status != models.RevisionPurchaseOffered {
+		return fiber.NewError(400, "Extra revision offer was already answered")
+	}
+	return nil
+}
+
+// acceptsExtras tells whether extras can still be added to an order in this status
+func acceptsExtras(status models.JobOfferStatus) bool {
+	return status == models.OfferStatusPaid || status == models.OfferStatusWorking || status == models.OfferStatusDelivered
+}
+
+// postOrderMessage stores a message in the order's conversation and broadcasts it with the offer
+func (h *JobOfferHandler) postOrderMessage(offer *models.JobOffer, senderID uuid.UUID, msgType, text string) {
+	h.postDeliveryMessage(offer, senderID, msgType, text, nil)
+}
+
+// postDeliveryMessage is postOrderMessage for a message that announces a delivery version
+func (h *JobOfferHandler) postDeliveryMessage(offer *models.JobOffer, senderID uuid.UUID, msgType, text string, deliveryID *uuid.UUID) {
+	msg := models.Message{
+		ID:             uuid.New(),
+		ConversationID: offer.ConversationID,
+		SenderID:       senderID,
+		Text:           text,
+		Type:           msgType,
+		DeliveryID:     deliveryID,
+		IsRead:         false,
+		CreatedAt:      time.Now(),
+	}
+	if err := h.DB.Create(&msg).Error; err != nil {
+		log.Printf("Failed to create %s message for offer %s: %v", msgType, offer.ID, err)
+		return
+	}
+
+	h.Hub.SendToConversation(offer.ClientID, offer.FreelancerID, fiber.Map{
+		"type": "new_message",
+		"message": fiber.Map{
+			"id":              msg.ID.String(),
+			"conversation_id": msg.ConversationID.String(),
+			"sender_id":       msg.SenderID.String(),
+			"text":            msg.Text,
+			"type":            msg.Type,
+			"delivery_id":     msg.DeliveryID,
+			"created_at":      msg.CreatedAt,
+		},
+		"offer": toJobOfferResponse(offer),
+	})
+
+	h.Hub.SendToConversation(offer.ClientID, offer.FreelancerID, fiber.Map{
+		"type":  "offer_status_update",
+		"offer": toJobOfferResponse(offer),
+	})
+}
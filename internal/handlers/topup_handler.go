@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"time"
+
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/models"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/gateway"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TopUpHandler struct {
+	DB        *gorm.DB
+	Gateway   gateway.PaymentGateway
+	MinAmount int64
+	MaxAmount int64
+}
+
+func NewTopUpHandler(db *gorm.DB, paymentGateway gateway.PaymentGateway, minAmount, maxAmount int64) *TopUpHandler {
+	return &TopUpHandler{DB: db, Gateway: paymentGateway, MinAmount: minAmount, MaxAmount: maxAmount}
+}
+
+type CreateTopUpRequest struct {
+	Amount        int64  `json:"amount"` // Credited to the balance, the channel fee is charged on top
+	PaymentMethod string `json:"payment_method"`
+}
+
+// CreateTopUp opens a gateway checkout that credits the client's wallet balance once paid
+func (h *TopUpHandler) CreateTopUp(c *fiber.Ctx) error {
+	userID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var req CreateTopUpRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid request body"})
+	}
+	if req.PaymentMethod == "" || req.PaymentMethod == models.PaymentMethodBalance {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Payment method is required"})
+	}
+	if req.Amount < h.MinAmount || req.Amount > h.MaxAmount {
+		return c.Status(400).JSON(fiber.Map{
+			"success":    false,
+			"message":    "Amount is outside the allowed top-up range",
+			"min_amount": h.MinAmount,
+			"max_amount": h.MaxAmount,
+		})
+	}
+
+	var user models.User
+	if err := h.DB.First(&user, "id = ?", userID).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "message": "User not found"})
+	}
+
+	channels, err := h.Gateway.GetPaymentChannels()
+	if err != nil {
+		log.Printf("Failed to fetch channels for top-up: %v", err)
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to calculate fees"})
+	}
+	var channel *gateway.PaymentChannel
+	for i := range channels {
+		if channels[i].Code == req.PaymentMethod && channels[i].Active {
+			channel = &channels[i]
+			break
+		}
+	}
+	if channel == nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid payment method"})
+	}
+
+	// Merchant refs are unique across orders and top-ups; the TOP- prefix keeps them apart
+	var merchantRef string
+	for {
+		merchantRef = models.TopUpRefPrefix + models.GenerateOrderCode()
+		var count int64
+		h.DB.Model(&models.Transaction{}).Where("merchant_ref = ?", merchantRef).Count(&count)
+		if count == 0 {
+			break
+		}
+	}
+
+	fee := channel.CustomerFee(req.Amount)
+	frontendURL := os.Getenv("FRONTEND_URL")
+	if frontendURL == "" {
+		frontendURL = "http://127.0.0.1:3000"
+	}
+	expiredAt := time.Now().Add(paymentExpiry)
+
+	phone := user.Phone
+	if phone == "" {
+		phone = "08123456789"
+	}
+
+	resp, err := h.Gateway.CreateTransaction(gateway.CreateTransactionRequest{
+		MerchantRef:   merchantRef,
+		Method:        req.PaymentMethod,
+		Amount:        req.Amount + fee,
+		CustomerFee:   fee,
+		CustomerName:  user.Name,
+		CustomerEmail: user.Email,
+		CustomerPhone: phone,
+		ItemName:      "Isi Saldo Jokiin",
+		ReturnURL:     frontendURL + "/wallet",
+		ExpiredAt:     expiredAt,
+	})
+	if err != nil {
+		log.Printf("%s error: %v", h.Gateway.Name(), err)
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Payment gateway error: " + err.Error()})
+	}
+
+	trx := models.Transaction{
+		Purpose:           models.TransactionPurposeTopUp,
+		UserID:            &userID,
+		Reference:         resp.Reference,
+		MerchantRef:       resp.MerchantRef,
+		CheckoutURL:       resp.CheckoutURL,
+		Status:            models.TransactionStatusUnpaid,
+		TotalAmount:       resp.Amount,
+		PaymentMethodCode: req.PaymentMethod,
+		PaymentMethod:     req.PaymentMethod,
+		FeeCustomer:       fee,
+		TotalFee:          fee,
+		ExpiredAt:         &expiredAt,
+	}
+	if err := h.DB.Create(&trx).Error; err != nil {
+		log.Printf("Failed to save top-up transaction: %v", err)
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to save transaction"})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"checkout_url": resp.CheckoutURL,
+			"reference":    resp.Reference,
+			"merchant_ref": resp.MerchantRef,
+			"amount":       req.Amount,
+			"fee":          fee,
+			"total_amount": resp.Amount,
+			"expired_at":   expiredAt,
+		},
+	})
+}
+
+// ListMyTopUps returns the client's top-ups, newest first
+func (h *TopUpHandler) ListMyTopUps(c *fiber.Ctx) error {
+	userID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 20)
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 20
+	}
+	offset := (page - 1) * limit
+
+	q := h.DB.Model(&models.Transaction{}).
+		Where("purpose = ? AND user_id = ?", models.TransactionPurposeTopUp, userID)
+	if status := c.Query("status"); status != "" {
+		q = q.Where("status = ?", status)
+	}
+
+	var total int64
+	q.Count(&total)
+
+	var topups []models.Transaction
+	if err := q.Order("created_at DESC").Limit(limit).Offset(offset).Find(&topups).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to fetch top-ups"})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    topups,
+		"meta": fiber.Map{
+			"page":        page,
+			"limit":       limit,
+			"total_items": total,
+			"total_pages": int(math.Ceil(float64(total) / float64(limit))),
+		},
+	})
+}
+
+// applyTopUpStatus moves the money of a top-up status change, inside applyPaymentStatus' DB transaction
+func (h *PaymentHandler) applyTopUpStatus(tx *gorm.DB, trx *models.Transaction, outcome *paymentOutcome) error {
+	if trx.UserID == nil {
+		return fmt.Errorf("top-up transaction %s has no user", trx.Reference)
+	}
+	outcome.UserID = *trx.UserID
+
+	switch trx.Status {
+	case models.TransactionStatusPaid:
+		// The customer fee belongs to the gateway; the rest is what the client topped up
+		amount := trx.TotalAmount - trx.FeeCustomer
+		desc := "Isi saldo (" + trx.MerchantRef + ")"
+		if err := h.WalletService.TopUpClient(tx, *trx.UserID, amount, trx.ID, desc); err != nil {
+			return err
+		}
+		if err := h.Ledger.RecordTopUp(tx, trx); err != nil {
+			return err
+		}
+		outcome.Amount = amount
+		outcome.Event = paymentEventTopUpPaid
+
+	case models.TransactionStatusRefund:
+		amount := trx.TotalAmount - trx.FeeCustomer
+
+		// Take back what is left of the top-up. If the client already spent part of it, the
+		// rest is recorded as a shortfall owed to the platform and reported by reconciliation.
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "balance").First(&user, "id = ?", *trx.UserID).Error; err != nil {
+			return err
+		}
+		debit := amount
+		if user.Balance < debit {
+			debit = max(user.Balance, 0)
+		}
+		trx.RefundShortfall = amount - debit
+
+		if debit > 0 {
+			desc := "Refund isi saldo (" + trx.MerchantRef + ") oleh payment gateway"
+			if err := h.WalletService.DebitClient(tx, *trx.UserID, debit, trx.ID, desc); err != nil {
+				return err
+			}
+		}
+		if err := h.Ledger.RecordTopUpRefund(tx, trx); err != nil {
+			return err
+		}
+		if trx.RefundShortfall > 0 {
+			log.Printf("Top-up %s refunded by gateway after the client spent Rp %d of it, recorded as shortfall", trx.Reference, trx.RefundShortfall)
+		}
+		outcome.Amount = debit
+		outcome.Event = paymentEventTopUpRefunded
+	}
+	// EXPIRED / FAILED: nothing was credited, the status update is all there is
+	return nil
+}
+
+// notifyTopUp tells the client's open sessions that their balance changed
+func (h *PaymentHandler) notifyTopUp(o paymentOutcome) {
+	if o.UserID == uuid.Nil {
+		return
+	}
+	var user models.User
+	if err := h.DB.Select("id", "balance").First(&user, "id = ?", o.UserID).Error; err != nil {
+		return
+	}
+	h.Hub.SendToUser(o.UserID, fiber.Map{
+		"type":    "wallet_update",
+		"event":   o.Event,
+		"amount":  o.Amount,
+		"balance": user.Balance,
+	})
+}
@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/models"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/voucher"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// VoucherHandler manages discount codes: platform-funded ones by admins and
+// freelancer-funded ones by admins or by the freelancer who funds them.
+type VoucherHandler struct {
+	DB *gorm.DB
+}
+
+func NewVoucherHandler(db *gorm.DB) *VoucherHandler {
+	return &VoucherHandler{DB: db}
+}
+
+type VoucherRequest struct {
+	Code         string `json:"code"`
+	Description  string `json:"description"`
+	FundedBy     string `json:"funded_by"`     // platform | freelancer (admin only)
+	FreelancerID string `json:"freelancer_id"` // Required for freelancer-funded vouchers (admin only)
+	Type         string `json:"type"`          // percent | fixed
+	Value        int64  `json:"value"`
+	MaxDiscount  int64  `json:"max_discount"`
+	MinSpend     int64  `json:"min_spend"`
+	UsageLimit   int    `json:"usage_limit"`
+	PerUserLimit *int   `json:"per_user_limit"`
+	Category     string `json:"category"`
+	ProductID    *uint  `json:"product_id"`
+	StartsAt     string `json:"starts_at"`  // RFC3339, optional
+	ExpiresAt    string `json:"expires_at"` // RFC3339, optional
+	IsActive     *bool  `json:"is_active"`
+}
+
+// toVoucher validates the request and copies it onto v (funding fields are set by the caller)
+func (req *VoucherRequest) toVoucher(v *models.Voucher) error {
+	code := voucher.NormalizeCode(req.Code)
+	if code == "" || len(code) > 40 {
+		return fiber.NewError(400, "Code is required (max 40 characters)")
+	}
+	switch models.VoucherType(req.Type) {
+	case models.VoucherTypePercent:
+		if req.Value < 1 || req.Value > 100 {
+			return fiber.NewError(400, "Percent value must be between 1 and 100")
+		}
+	case models.VoucherTypeFixed:
+		if req.Value <= 0 {
+			return fiber.NewError(400, "Fixed value must be greater than zero")
+		}
+	default:
+		return fiber.NewError(400, "Type must be percent or fixed")
+	}
+	if req.MaxDiscount < 0 || req.MinSpend < 0 || req.UsageLimit < 0 || (req.PerUserLimit != nil && *req.PerUserLimit < 0) {
+		return fiber.NewError(400, "Limits cannot be negative")
+	}
+
+	var startsAt, expiresAt *time.Time
+	if req.StartsAt != "" {
+		t, err := time.Parse(time.RFC3339, req.StartsAt)
+		if err != nil {
+			return fiber.NewError(400, "starts_at must be RFC3339")
+		}
+		startsAt = &t
+	}
+	if req.ExpiresAt != "" {
+		t, err := time.Parse(time.RFC3339, req.ExpiresAt)
+		if err != nil {
+			return fiber.NewError(400, "expires_at must be RFC3339")
+		}
+		expiresAt = &t
+	}
+	if startsAt != nil && expiresAt != nil && !expiresAt.After(*startsAt) {
+		return fiber.NewError(400, "expires_at must be after starts_at")
+	}
+
+	v.Code = code
+	v.Description = req.Description
+	v.Type = models.VoucherType(req.Type)
+	v.Value = req.Value
+	v.MaxDiscount = req.MaxDiscount
+	v.MinSpend = req.MinSpend
+	v.UsageLimit = req.UsageLimit
+	if req.PerUserLimit != nil {
+		v.PerUserLimit = *req.PerUserLimit
+	}
+	v.Category = strings.TrimSpace(req.Category)
+	v.ProductID = req.ProductID
+	v.StartsAt = startsAt
+	v.ExpiresAt = expiresAt
+	if req.IsActive != nil {
+		v.IsActive = *req.IsActive
+	}
+	return nil
+}
+
+// adminFunding applies the admin-chosen funding source
+func (req *VoucherRequest) adminFunding(v *models.Voucher) error {
+	switch models.VoucherFunding(req.FundedBy) {
+	case models.VoucherFundedByPlatform:
+		v.FundedBy = models.VoucherFundedByPlatform
+		v.FreelancerID = nil
+	case models.VoucherFundedByFreelancer:
+		id, err := uuid.Parse(req.FreelancerID)
+		if err != nil {
+			return fiber.NewError(400, "freelancer_id is required for freelancer-funded vouchers")
+		}
+		v.FundedBy = models.VoucherFundedByFreelancer
+		v.FreelancerID = &id
+	default:
+		return fiber.NewError(400, "funded_by must be platform or freelancer")
+	}
+	return nil
+}
+
+// checkProductOwner makes sure a freelancer only scopes vouchers to their own services
+func (h *VoucherHandler) checkProductOwner(v *models.Voucher, freelancerID uuid.UUID) error {
+	if v.ProductID == nil {
+		return nil
+	}
+	var count int64
+	h.DB.Model(&models.Product{}).Where("id = ? AND user_id = ?", *v.ProductID, freelancerID).Count(&count)
+	if count == 0 {
+		return fiber.NewError(400, "Product not found")
+	}
+	return nil
+}
+
+func (h *VoucherHandler) save(c *fiber.Ctx, v *models.Voucher, created bool) error {
+	var taken int64
+	h.DB.Model(&models.Voucher{}).Where("code = ? AND id <> ?", v.Code, v.ID).Count(&taken)
+	if taken > 0 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Voucher code is already used"})
+	}
+
+	if err := h.DB.Save(v).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to save voucher"})
+	}
+	if created {
+		return c.Status(201).JSON(fiber.Map{"success": true, "message": "Voucher created", "data": v})
+	}
+	return c.JSON(fiber.Map{"success": true, "message": "Voucher updated", "data": v})
+}
+
+// AdminListVouchers returns all vouchers with their usage
+func (h *VoucherHandler) AdminListVouchers(c *fiber.Ctx) error {
+	q := h.DB.Model(&models.Voucher{})
+	if fundedBy := c.Query("funded_by"); fundedBy != "" {
+		q = q.Where("funded_by = ?", fundedBy)
+	}
+	return h.list(c, q)
+}
+
+// AdminCreateVoucher creates a platform- or freelancer-funded voucher
+func (h *VoucherHandler) AdminCreateVoucher(c *fiber.Ctx) error {
+	adminID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var req VoucherRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid request body"})
+	}
+
+	v := models.Voucher{ID: uuid.New(), IsActive: true, PerUserLimit: 1, CreatedBy: &adminID}
+	if err := req.toVoucher(&v); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": err.Error()})
+	}
+	if err := req.adminFunding(&v); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": err.Error()})
+	}
+	return h.save(c, &v, true)
+}
+
+// AdminUpdateVoucher replaces a voucher's settings. Redemptions already made keep their discount.
+func (h *VoucherHandler) AdminUpdateVoucher(c *fiber.Ctx) error {
+	var req VoucherRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid request body"})
+	}
+
+	var v models.Voucher
+	if err := h.DB.First(&v, "id = ?", c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "message": "Voucher not found"})
+	}
+	if err := req.toVoucher(&v); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": err.Error()})
+	}
+	if err := req.adminFunding(&v); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": err.Error()})
+	}
+	return h.save(c, &v, false)
+}
+
+// ListMyVouchers returns the vouchers funded by the freelancer
+func (h *VoucherHandler) ListMyVouchers(c *fiber.Ctx) error {
+	userID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+	return h.list(c, h.DB.Model(&models.Voucher{}).Where("freelancer_id = ?", userID))
+}
+
+// CreateMyVoucher lets a freelancer fund a discount on their own orders
+func (h *VoucherHandler) CreateMyVoucher(c *fiber.Ctx) error {
+	userID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var req VoucherRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid request body"})
+	}
+
+	v := models.Voucher{
+		ID:           uuid.New(),
+		IsActive:     true,
+		PerUserLimit: 1,
+		FundedBy:     models.VoucherFundedByFreelancer,
+		FreelancerID: &userID,
+		CreatedBy:    &userID,
+	}
+	if err := req.toVoucher(&v); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": err.Error()})
+	}
+	if err := h.checkProductOwner(&v, userID); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": err.Error()})
+	}
+	return h.save(c, &v, true)
+}
+
+// UpdateMyVoucher updates one of the freelancer's own vouchers
+func (h *VoucherHandler) UpdateMyVoucher(c *fiber.Ctx) error {
+	userID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var req VoucherRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid request body"})
+	}
+
+	var v models.Voucher
+	if err := h.DB.First(&v, "id = ? AND freelancer_id = ?", c.Params("id"), userID).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "message": "Voucher not found"})
+	}
+	if err := req.toVoucher(&v); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": err.Error()})
+	}
+	if err := h.checkProductOwner(&v, userID); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": err.Error()})
+	}
+	return h.save(c, &v, false)
+}
+
+type voucherWithUsage struct {
+	models.Voucher
+	UsedCount     int64 `json:"used_count"`
+	ReservedCount int64 `json:"reserved_count"`
+}
+
+func (h *VoucherHandler) list(c *fiber.Ctx, q *gorm.DB) error {
+	var vouchers []models.Voucher
+	if err := q.Order("created_at DESC").Find(&vouchers).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to fetch vouchers"})
+	}
+
+	type usageRow struct {
+		VoucherID uuid.UUID
+		Status    models.VoucherRedemptionStatus
+		Count     int64
+	}
+	ids := make([]uuid.UUID, len(vouchers))
+	for i, v := range vouchers {
+		ids[i] = v.ID
+	}
+	var rows []usageRow
+	if len(ids) > 0 {
+		h.DB.Model(&models.VoucherRedemption{}).
+			Select("voucher_id, status, COUNT(*) AS count").
+			Where("voucher_id IN ?", ids).
+			Group("voucher_id, status").
+			Scan(&rows)
+	}
+
+	result := make([]voucherWithUsage, len(vouchers))
+	index := map[uuid.UUID]int{}
+	for i, v := range vouchers {
+		result[i] = voucherWithUsage{Voucher: v}
+		index[v.ID] = i
+	}
+	for _, r := range rows {
+		switch r.Status {
+		case models.VoucherRedemptionUsed:
+			result[index[r.VoucherID]].UsedCount = r.Count
+		case models.VoucherRedemptionReserved:
+			result[index[r.VoucherID]].ReservedCount = r.Count
+		}
+	}
+
+	return c.JSON(fiber.Map{"success": true, "data": result})
+}
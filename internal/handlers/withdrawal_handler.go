@@ -0,0 +1,370 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/models"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/ledger"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/wallet"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WithdrawalHandler struct {
+	DB            *gorm.DB
+	WalletService *wallet.WalletService
+	Ledger        *ledger.LedgerService
+	MinAmount     int64
+	EncryptKey    string // Decrypts the destination account number for finance
+}
+
+func NewWithdrawalHandler(db *gorm.DB, walletService *wallet.WalletService, ledgerService *ledger.LedgerService, minAmount int64, encryptKey string) *WithdrawalHandler {
+	return &WithdrawalHandler{DB: db, WalletService: walletService, Ledger: ledgerService, MinAmount: minAmount, EncryptKey: encryptKey}
+}
+
+type CreateWithdrawalRequest struct {
+	Amount          int64  `json:"amount"`
+	PayoutAccountID string `json:"payout_account_id"` // optional, defaults to the primary account
+}
+
+// CreateWithdrawal lets a freelancer request a payout. The amount is held immediately
+// by debiting the wallet, so concurrent requests can never overdraw the balance.
+func (h *WithdrawalHandler) CreateWithdrawal(c *fiber.Ctx) error {
+	userID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var req CreateWithdrawalRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid request body"})
+	}
+
+	if req.Amount <= 0 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Amount is required and must be positive"})
+	}
+	if req.Amount < h.MinAmount {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Amount is below the minimum withdrawal", "min_amount": h.MinAmount})
+	}
+
+	// Resolve payout destination
+	var account models.PayoutAccount
+	q := h.DB.Where("user_id = ?", userID)
+	if req.PayoutAccountID != "" {
+		accountID, err := uuid.Parse(req.PayoutAccountID)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid payout account ID"})
+		}
+		q = q.Where("id = ?", accountID)
+	} else {
+		q = q.Where("is_primary = ?", true)
+	}
+	if err := q.First(&account).Error; err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Tambahkan rekening atau e-wallet tujuan penarikan terlebih dahulu"})
+	}
+
+	withdrawal := models.Withdrawal{
+		ID:                  uuid.New(),
+		UserID:              userID,
+		Amount:              req.Amount,
+		PayoutAccountID:     &account.ID,
+		DestinationType:     account.Type,
+		ProviderCode:        account.ProviderCode,
+		AccountHolder:       account.HolderName,
+		AccountNumberEnc:    account.AccountNumberEnc,
+		AccountNumberMasked: account.AccountNumberMasked,
+		Status:              models.WithdrawalStatusPending,
+	}
+
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&withdrawal).Error; err != nil {
+			return err
+		}
+
+		desc := "Penarikan saldo ke " + withdrawal.ProviderCode + " " + withdrawal.AccountNumberMasked + " a.n. " + withdrawal.AccountHolder
+		if err := h.WalletService.DebitFreelancer(tx, userID, withdrawal.Amount, withdrawal.ID, desc); err != nil {
+			return err
+		}
+		return h.Ledger.RecordWithdrawalHold(tx, &withdrawal)
+	})
+
+	if err != nil {
+		if errors.Is(err, wallet.ErrInsufficientBalance) {
+			return c.Status(400).JSON(fiber.Map{"success": false, "message": "Saldo tidak mencukupi"})
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(404).JSON(fiber.Map{"success": false, "message": "Profile not found"})
+		}
+		log.Printf("[Withdrawal] Failed to create withdrawal for user %s: %v", userID, err)
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to create withdrawal"})
+	}
+
+	return c.Status(201).JSON(fiber.Map{"success": true, "data": withdrawal})
+}
+
+// ListMyWithdrawals returns the freelancer's withdrawal history
+func (h *WithdrawalHandler) ListMyWithdrawals(c *fiber.Ctx) error {
+	userID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	return h.list(c, h.DB.Model(&models.Withdrawal{}).Where("user_id = ?", userID))
+}
+
+// CancelWithdrawal lets a freelancer cancel a request that has not been processed yet
+func (h *WithdrawalHandler) CancelWithdrawal(c *fiber.Ctx) error {
+	userID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	return h.transition(c, func(tx *gorm.DB, w *models.Withdrawal) error {
+		if w.UserID != userID {
+			return fiber.NewError(403, "Access denied")
+		}
+		if w.Status != models.WithdrawalStatusPending {
+			return fiber.NewError(400, "Only pending withdrawals can be cancelled")
+		}
+
+		w.Status = models.WithdrawalStatusCancelled
+		return h.refund(tx, w, "Pembatalan penarikan saldo")
+	})
+}
+
+// ===== Admin =====
+
+// AdminListWithdrawals returns all withdrawals, optionally filtered by status
+func (h *WithdrawalHandler) AdminListWithdrawals(c *fiber.Ctx) error {
+	return h.list(c, h.DB.Model(&models.Withdrawal{}).Preload("User"))
+}
+
+// AdminGetWithdrawalAccount reveals the full destination account number of a withdrawal so
+// finance can make the transfer. Every view is recorded.
+func (h *WithdrawalHandler) AdminGetWithdrawalAccount(c *fiber.Ctx) error {
+	withdrawalID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid withdrawal ID"})
+	}
+
+	var withdrawal models.Withdrawal
+	if err := h.DB.First(&withdrawal, "id = ?", withdrawalID).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "message": "Withdrawal not found"})
+	}
+
+	number, err := revealAccountNumber(h.DB, c, h.EncryptKey, "withdrawal", withdrawal.ID, withdrawal.AccountNumberEnc)
+	if err != nil {
+		if e, ok := err.(*fiber.Error); ok {
+			return c.Status(e.Code).JSON(fiber.Map{"success": false, "message": e.Message})
+		}
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"destination_type": withdrawal.DestinationType,
+			"provider_code":    withdrawal.ProviderCode,
+			"account_holder":   withdrawal.AccountHolder,
+			"account_number":   number,
+		},
+	})
+}
+
+type processWithdrawalRequest struct {
+	Note              string `json:"note"`
+	Reason            string `json:"reason"`
+	TransferReference string `json:"transfer_reference"`
+}
+
+// ApproveWithdrawal marks a pending withdrawal as approved for transfer
+func (h *WithdrawalHandler) ApproveWithdrawal(c *fiber.Ctx) error {
+	adminID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var req processWithdrawalRequest
+	_ = c.BodyParser(&req)
+
+	return h.transition(c, func(tx *gorm.DB, w *models.Withdrawal) error {
+		if w.Status != models.WithdrawalStatusPending {
+			return fiber.NewError(400, "Only pending withdrawals can be approved")
+		}
+
+		now := time.Now()
+		w.Status = models.WithdrawalStatusApproved
+		w.AdminNote = req.Note
+		w.ProcessedBy = &adminID
+		w.ProcessedAt = &now
+		return nil
+	})
+}
+
+// RejectWithdrawal rejects a pending withdrawal and returns the held funds
+func (h *WithdrawalHandler) RejectWithdrawal(c *fiber.Ctx) error {
+	adminID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var req processWithdrawalRequest
+	_ = c.BodyParser(&req)
+	if strings.TrimSpace(req.Note) == "" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Rejection note is required"})
+	}
+
+	return h.transition(c, func(tx *gorm.DB, w *models.Withdrawal) error {
+		if w.Status != models.WithdrawalStatusPending {
+			return fiber.NewError(400, "Only pending withdrawals can be rejected")
+		}
+
+		now := time.Now()
+		w.Status = models.WithdrawalStatusRejected
+		w.AdminNote = req.Note
+		w.ProcessedBy = &adminID
+		w.ProcessedAt = &now
+		return h.refund(tx, w, "Penarikan saldo ditolak: "+req.Note)
+	})
+}
+
+// MarkWithdrawalTransferred records that the money has been sent to the freelancer
+func (h *WithdrawalHandler) MarkWithdrawalTransferred(c *fiber.Ctx) error {
+	adminID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var req processWithdrawalRequest
+	_ = c.BodyParser(&req)
+	if strings.TrimSpace(req.TransferReference) == "" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "transfer_reference is required"})
+	}
+
+	return h.transition(c, func(tx *gorm.DB, w *models.Withdrawal) error {
+		if w.Status != models.WithdrawalStatusApproved {
+			return fiber.NewError(400, "Only approved withdrawals can be marked as transferred")
+		}
+
+		now := time.Now()
+		w.Status = models.WithdrawalStatusTransferred
+		w.TransferReference = req.TransferReference
+		w.ProcessedBy = &adminID
+		w.TransferredAt = &now
+		return h.Ledger.RecordWithdrawalPayout(tx, w)
+	})
+}
+
+// MarkWithdrawalFailed records a failed transfer and returns the held funds
+func (h *WithdrawalHandler) MarkWithdrawalFailed(c *fiber.Ctx) error {
+	adminID, err := getAuth(c)
+	if err != nil {
+		return err
+	}
+
+	var req processWithdrawalRequest
+	_ = c.BodyParser(&req)
+	if strings.TrimSpace(req.Reason) == "" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Failure reason is required"})
+	}
+
+	return h.transition(c, func(tx *gorm.DB, w *models.Withdrawal) error {
+		if w.Status != models.WithdrawalStatusApproved {
+			return fiber.NewError(400, "Only approved withdrawals can be marked as failed")
+		}
+
+		now := time.Now()
+		w.Status = models.WithdrawalStatusFailed
+		w.FailureReason = req.Reason
+		w.ProcessedBy = &adminID
+		w.ProcessedAt = &now
+		return h.refund(tx, w, "Transfer penarikan saldo gagal: "+req.Reason)
+	})
+}
+
+// ===== Helpers =====
+
+// refund returns the held amount of a closed withdrawal to the freelancer balance
+func (h *WithdrawalHandler) refund(tx *gorm.DB, w *models.Withdrawal, description string) error {
+	if err := h.WalletService.RefundFreelancer(tx, w.UserID, w.Amount, w.ID, description); err != nil {
+		return err
+	}
+	return h.Ledger.RecordWithdrawalReversal(tx, w)
+}
+
+// transition locks the withdrawal from the :id param (SELECT ... FOR UPDATE), applies fn and
+// saves the result in a single DB transaction. Concurrent transitions of the same withdrawal wait
+// for the lock and then see the new status, so the held funds are never returned twice.
+func (h *WithdrawalHandler) transition(c *fiber.Ctx, fn func(tx *gorm.DB, w *models.Withdrawal) error) error {
+	withdrawalID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "message": "Invalid withdrawal ID"})
+	}
+
+	var withdrawal models.Withdrawal
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&withdrawal, "id = ?", withdrawalID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fiber.NewError(404, "Withdrawal not found")
+			}
+			return err
+		}
+
+		if err := fn(tx, &withdrawal); err != nil {
+			return err
+		}
+
+		return tx.Save(&withdrawal).Error
+	})
+
+	if err != nil {
+		if e, ok := err.(*fiber.Error); ok {
+			return c.Status(e.Code).JSON(fiber.Map{"success": false, "message": e.Message})
+		}
+		log.Printf("[Withdrawal] Failed to process withdrawal %s: %v", withdrawalID, err)
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to process withdrawal"})
+	}
+
+	return c.JSON(fiber.Map{"success": true, "data": withdrawal})
+}
+
+func (h *WithdrawalHandler) list(c *fiber.Ctx, q *gorm.DB) error {
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 20)
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 20
+	}
+	offset := (page - 1) * limit
+
+	if status := c.Query("status"); status != "" {
+		q = q.Where("status = ?", status)
+	}
+
+	var total int64
+	q.Count(&total)
+
+	var withdrawals []models.Withdrawal
+	if err := q.Order("created_at DESC").Limit(limit).Offset(offset).Find(&withdrawals).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "message": "Failed to fetch withdrawals"})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    withdrawals,
+		"meta": fiber.Map{
+			"page":        page,
+			"limit":       limit,
+			"total_items": total,
+			"total_pages": int(math.Ceil(float64(total) / float64(limit))),
+		},
+	})
+}
@@ -46,6 +46,7 @@ type Message struct {
 	Text           string     `json:"text"`
 	FileUrl        string     `json:"file_url,omitempty"`
 	FileName       string     `json:"file_name,omitempty"`
+	DeliveryID     *uuid.UUID `gorm:"type:uuid" json:"delivery_id,omitempty"` // Delivery version announced by a "delivery" message
 	IsRead         bool       `gorm:"default:false" json:"is_read"`
 	ReadAt         *time.Time `json:"read_at"`
 	CreatedAt      time.Time  `json:"created_at"`
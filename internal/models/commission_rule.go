@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CommissionRule sets the platform fee for offers matching all of its (optional) criteria.
+// Empty/zero criteria match anything. The matching rule with a freelancer override wins,
+// then the highest Priority, then the most specific one.
+type CommissionRule struct {
+	ID       uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	Name     string    `gorm:"type:varchar(120);not null" json:"name"`
+	Priority int       `gorm:"not null;default:0" json:"priority"`
+	IsActive bool      `gorm:"not null;default:true;index" json:"is_active"`
+
+	// Criteria
+	Category        string          `gorm:"type:varchar(100)" json:"category"`
+	FreelancerLevel FreelancerLevel `gorm:"type:varchar(20)" json:"freelancer_level"`
+	FreelancerID    *uuid.UUID      `gorm:"type:uuid;index" json:"freelancer_id,omitempty"` // Per-freelancer override
+	MinPrice        int64           `gorm:"not null;default:0" json:"min_price"`            // Inclusive, 0 = no minimum
+	MaxPrice        int64           `gorm:"not null;default:0" json:"max_price"`            // Inclusive, 0 = no maximum
+	StartsAt        *time.Time      `json:"starts_at,omitempty"`
+	EndsAt          *time.Time      `json:"ends_at,omitempty"`
+
+	// Fee = Price * RateBps / 10000 + FlatFee (capped at Price)
+	RateBps int   `gorm:"not null" json:"rate_bps"`
+	FlatFee int64 `gorm:"not null;default:0" json:"flat_fee"`
+
+	CreatedBy *uuid.UUID `gorm:"type:uuid" json:"created_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// Specificity counts how many criteria the rule sets
+func (r *CommissionRule) Specificity() int {
+	n := 0
+	if r.Category != "" {
+		n++
+	}
+	if r.FreelancerLevel != "" {
+		n++
+	}
+	if r.MinPrice > 0 || r.MaxPrice > 0 {
+		n++
+	}
+	if r.StartsAt != nil || r.EndsAt != nil {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type CounterOfferStatus string
+
+const (
+	CounterOfferPending   CounterOfferStatus = "pending"   // Menunggu jawaban freelancer
+	CounterOfferAccepted  CounterOfferStatus = "accepted"  // Diterima, penawaran versi baru dibuat
+	CounterOfferRejected  CounterOfferStatus = "rejected"  // Ditolak freelancer
+	CounterOfferCancelled CounterOfferStatus = "cancelled" // Penawaran diubah/ditutup sebelum dijawab
+)
+
+// CounterOffer is the client's proposal of different terms for a pending offer. Accepting it
+// creates a new version of the offer that supersedes the original.
+type CounterOffer struct {
+	ID            uuid.UUID          `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	JobOfferID    uuid.UUID          `gorm:"type:uuid;index;not null" json:"job_offer_id"`
+	ProposedBy    uuid.UUID          `gorm:"type:uuid;not null" json:"proposed_by"`
+	Price         int64              `json:"price"`
+	DeliveryDate  time.Time          `json:"delivery_date"` // Midnight (Asia/Jakarta) of the due day
+	RevisionCount int                `json:"revision_count"`
+	Note          string             `gorm:"type:text" json:"note"`
+	Status        CounterOfferStatus `gorm:"type:varchar(20);not null;default:'pending';index" json:"status"`
+	ResponseNote  string             `gorm:"type:text" json:"response_note,omitempty"` // Freelancer's reason when rejecting
+	NewOfferID    *uuid.UUID         `gorm:"type:uuid" json:"new_offer_id,omitempty"`  // Offer version created on acceptance
+	RespondedAt   *time.Time         `json:"responded_at,omitempty"`
+	CreatedAt     time.Time          `json:"created_at"`
+	UpdatedAt     time.Time          `json:"updated_at"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ExtensionStatus string
+
+const (
+	ExtensionPending   ExtensionStatus = "pending"   // Menunggu jawaban pembeli
+	ExtensionAccepted  ExtensionStatus = "accepted"  // Batas waktu pesanan diperpanjang
+	ExtensionRejected  ExtensionStatus = "rejected"  // Ditolak pembeli
+	ExtensionCancelled ExtensionStatus = "cancelled" // Pesanan selesai/dibatalkan sebelum dijawab
+)
+
+// DeadlineExtension is a freelancer's request to move the delivery date of an order
+type DeadlineExtension struct {
+	ID           uuid.UUID       `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	JobOfferID   uuid.UUID       `gorm:"type:uuid;index;not null" json:"job_offer_id"`
+	RequestedBy  uuid.UUID       `gorm:"type:uuid;not null" json:"requested_by"`
+	CurrentDate  time.Time       `json:"current_date"`  // Delivery date when the request was made
+	ProposedDate time.Time       `json:"proposed_date"` // Midnight (Asia/Jakarta) of the new delivery date
+	Reason       string          `gorm:"type:text" json:"reason"`
+	Status       ExtensionStatus `gorm:"type:varchar(20);not null;default:'pending';index" json:"status"`
+	RespondedAt  *time.Time      `json:"responded_at,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+}
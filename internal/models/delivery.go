@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Delivery is one version of the work handed in on an order (or one of its milestones).
+// Versions are never overwritten, so earlier deliveries stay available after revisions and
+// as evidence in disputes.
+type Delivery struct {
+	ID          uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	JobOfferID  uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_delivery_version" json:"job_offer_id"`
+	Version     int        `gorm:"not null;uniqueIndex:idx_delivery_version" json:"version"` // 1-based, per order
+	MilestoneID *uuid.UUID `gorm:"type:uuid;index" json:"milestone_id,omitempty"`
+	DeliveredBy uuid.UUID  `gorm:"type:uuid;not null" json:"delivered_by"`
+	Note        string     `gorm:"type:text" json:"note"`
+	Link        string     `gorm:"type:text" json:"link"`
+	Files       string     `gorm:"type:text" json:"files"` // JSON array of file URLs
+	CreatedAt   time.Time  `json:"created_at"`
+}
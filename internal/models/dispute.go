@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type DisputeStatus string
+
+const (
+	DisputeStatusOpen      DisputeStatus = "open"      // Menunggu keputusan admin
+	DisputeStatusResolved  DisputeStatus = "resolved"  // Sudah diputuskan admin
+	DisputeStatusWithdrawn DisputeStatus = "withdrawn" // Ditarik kembali oleh pembeli
+)
+
+type DisputeResolution string
+
+const (
+	DisputeResolutionRelease DisputeResolution = "release" // Seluruh dana escrow diteruskan ke freelancer
+	DisputeResolutionRefund  DisputeResolution = "refund"  // Seluruh dana escrow dikembalikan ke pembeli
+	DisputeResolutionSplit   DisputeResolution = "split"   // Sebagian ke pembeli, sisanya ke freelancer
+)
+
+// Dispute is a client's complaint on an order, arbitrated by an admin. While it is open the
+// order is frozen in the disputed status (no auto-completion).
+type Dispute struct {
+	ID             uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	JobOfferID     uuid.UUID      `gorm:"type:uuid;index;not null" json:"job_offer_id"`
+	OrderCode      string         `gorm:"type:varchar(20);index" json:"order_code"`
+	OpenedBy       uuid.UUID      `gorm:"type:uuid;not null" json:"opened_by"`
+	Reason         string         `gorm:"type:text;not null" json:"reason"`
+	Status         DisputeStatus  `gorm:"type:varchar(20);not null;default:'open';index" json:"status"`
+	PreviousStatus JobOfferStatus `gorm:"type:varchar(20);not null" json:"previous_status"` // Restored when the dispute is withdrawn
+
+	// Admin decision
+	Resolution      DisputeResolution `gorm:"type:varchar(20)" json:"resolution,omitempty"`
+	RefundAmount    int64             `gorm:"not null;default:0" json:"refund_amount"`  // Escrow returned to the client
+	ReleaseAmount   int64             `gorm:"not null;default:0" json:"release_amount"` // Escrow released to the freelancer
+	RefundRequestID *uuid.UUID        `gorm:"type:uuid" json:"refund_request_id,omitempty"`
+	AdminNote       string            `gorm:"type:text" json:"admin_note"`
+	ResolvedBy      *uuid.UUID        `gorm:"type:uuid" json:"resolved_by,omitempty"`
+	ResolvedAt      *time.Time        `json:"resolved_at,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Evidence []DisputeEvidence `gorm:"foreignKey:DisputeID" json:"evidence,omitempty"`
+}
+
+// DisputeEvidence is a statement of one party (or the admin) with optional files
+type DisputeEvidence struct {
+	ID          uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	DisputeID   uuid.UUID  `gorm:"type:uuid;index;not null" json:"dispute_id"`
+	SubmittedBy uuid.UUID  `gorm:"type:uuid;not null" json:"submitted_by"`
+	Role        OfferActor `gorm:"type:varchar(20);not null" json:"role"`
+	Text        string     `gorm:"type:text" json:"text"`
+	Files       string     `gorm:"type:text" json:"files"` // JSON array of file URLs
+	CreatedAt   time.Time  `json:"created_at"`
+}
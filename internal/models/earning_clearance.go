@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ClearanceStatus string
+
+const (
+	ClearancePending  ClearanceStatus = "pending"  // Masih di saldo tertunda
+	ClearanceReleased ClearanceStatus = "released" // Sudah pindah ke saldo yang bisa ditarik
+	ClearanceReversed ClearanceStatus = "reversed" // Ditarik kembali sebelum kliring (sengketa/chargeback)
+)
+
+// EarningClearance holds the net earnings of one completed order (or milestone) in the freelancer's
+// pending balance until AvailableAt, when they move to the withdrawable balance.
+type EarningClearance struct {
+	ID          uuid.UUID       `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID      uuid.UUID       `gorm:"type:uuid;index;not null" json:"user_id"`
+	JobOfferID  uuid.UUID       `gorm:"type:uuid;index;not null" json:"job_offer_id"` // One per order, or per milestone
+	OrderCode   string          `gorm:"type:varchar(20)" json:"order_code"`
+	Amount      int64           `gorm:"not null" json:"amount"`
+	Description string          `gorm:"type:text" json:"description"`
+	Status      ClearanceStatus `gorm:"type:varchar(20);not null;default:'pending';index" json:"status"`
+	AvailableAt time.Time       `gorm:"index" json:"available_at"`
+	ReleasedAt  *time.Time      `json:"released_at,omitempty"`
+	ReversedAt  *time.Time      `json:"reversed_at,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
@@ -14,6 +14,24 @@ const (
 	FreelancerPartTime FreelancerType = "part_time"
 )
 
+type FreelancerLevel string
+
+const (
+	FreelancerLevelNew      FreelancerLevel = "new"
+	FreelancerLevelOne      FreelancerLevel = "level_1"
+	FreelancerLevelTwo      FreelancerLevel = "level_2"
+	FreelancerLevelTopRated FreelancerLevel = "top_rated"
+)
+
+// IsValidFreelancerLevel reports whether l is one of the known seller levels
+func IsValidFreelancerLevel(l FreelancerLevel) bool {
+	switch l {
+	case FreelancerLevelNew, FreelancerLevelOne, FreelancerLevelTwo, FreelancerLevelTopRated:
+		return true
+	}
+	return false
+}
+
 type OnboardingStatus string
 
 const (
@@ -58,8 +76,13 @@ type FreelancerProfile struct {
 	ContactPhone   string `gorm:"type:varchar(30)" json:"contact_phone"`
 	CurrentAddress string `gorm:"type:text" json:"current_address"`
 
-	// Wallet
-	Balance int64 `gorm:"not null;default:0" json:"balance"`
+	// Wallet: Balance can be withdrawn, PendingBalance holds completed-order earnings
+	// until their clearing period is over (see EarningClearance)
+	Balance        int64 `gorm:"not null;default:0" json:"balance"`
+	PendingBalance int64 `gorm:"not null;default:0" json:"pending_balance"`
+
+	// Seller level (set by admin), used by commission rules
+	Level FreelancerLevel `gorm:"type:varchar(20);not null;default:'new'" json:"level"`
 
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// Invoice is issued to the client when an order payment is PAID. Numbers are sequential
+// per month (INV/2026/10/0001) and never reused; the buyer, seller and amounts are
+// snapshotted so the invoice does not change when the order or profiles do.
+type Invoice struct {
+	ID            uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	Number        string    `gorm:"type:varchar(30);uniqueIndex;not null" json:"number"`
+	JobOfferID    uuid.UUID `gorm:"type:uuid;index;not null" json:"job_offer_id"`
+	TransactionID uuid.UUID `gorm:"type:uuid;uniqueIndex;not null" json:"transaction_id"`
+	OrderCode     string    `gorm:"type:varchar(20)" json:"order_code"`
+
+	ClientID         uuid.UUID      `gorm:"type:uuid;index;not null" json:"client_id"`
+	ClientName       string         `json:"client_name"`
+	ClientEmail      string         `json:"client_email"`
+	FreelancerID     uuid.UUID      `gorm:"type:uuid;not null" json:"freelancer_id"`
+	FreelancerName   string         `json:"freelancer_name"`
+	Items            datatypes.JSON `json:"items"` // []InvoiceItem
+	Subtotal         int64          `gorm:"not null" json:"subtotal"`
+	Discount         int64          `gorm:"not null;default:0" json:"discount"` // Voucher discount
+	PaymentFee       int64          `gorm:"not null;default:0" json:"payment_fee"`
+	Total            int64          `gorm:"not null" json:"total"`
+	BalanceAmount    int64          `gorm:"not null;default:0" json:"balance_amount"` // Part of Total paid from the wallet
+	PaymentMethod    string         `json:"payment_method"`
+	PaymentReference string         `json:"payment_reference"`
+
+	IssuedAt  time.Time `json:"issued_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type InvoiceItem struct {
+	Description string `json:"description"`
+	Quantity    int    `json:"quantity"`
+	UnitPrice   int64  `json:"unit_price"`
+	Amount      int64  `json:"amount"`
+}
+
+// InvoiceSequence holds the last invoice number used in a month ("2026/10")
+type InvoiceSequence struct {
+	Period     string `gorm:"type:varchar(7);primaryKey" json:"period"`
+	LastNumber int    `gorm:"not null;default:0" json:"last_number"`
+}
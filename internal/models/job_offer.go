@@ -11,12 +11,16 @@ import (
 type JobOfferStatus string
 
 const (
-	OfferStatusPending   JobOfferStatus = "pending"   // Menunggu Pembayaran
-	OfferStatusPaid      JobOfferStatus = "paid"      // Pembayaran Diterima
-	OfferStatusWorking   JobOfferStatus = "working"   // Sedang Bekerja
-	OfferStatusDelivered JobOfferStatus = "delivered" // Terkirim
-	OfferStatusCompleted JobOfferStatus = "completed" // Selesai
-	OfferStatusCancelled JobOfferStatus = "cancelled" // Dibatalkan
+	OfferStatusPending    JobOfferStatus = "pending"    // Menunggu Pembayaran
+	OfferStatusPaid       JobOfferStatus = "paid"       // Pembayaran Diterima
+	OfferStatusWorking    JobOfferStatus = "working"    // Sedang Bekerja
+	OfferStatusDelivered  JobOfferStatus = "delivered"  // Terkirim
+	OfferStatusCompleted  JobOfferStatus = "completed"  // Selesai
+	OfferStatusCancelled  JobOfferStatus = "cancelled"  // Dibatalkan
+	OfferStatusDisputed   JobOfferStatus = "disputed"   // Dalam sengketa, menunggu keputusan admin
+	OfferStatusExpired    JobOfferStatus = "expired"    // Kedaluwarsa, tidak dibayar dalam masa berlaku penawaran
+	OfferStatusDeclined   JobOfferStatus = "declined"   // Ditolak pembeli
+	OfferStatusSuperseded JobOfferStatus = "superseded" // Digantikan versi penawaran baru (tawaran balik diterima)
 )
 
 type JobOffer struct {
@@ -27,11 +31,20 @@ type JobOffer struct {
 	ClientID       uuid.UUID `gorm:"type:uuid;index" json:"client_id"`
 	ProductID      *uint     `gorm:"index" json:"product_id,omitempty"`
 
+	// Set on orders checked out directly from a product package
+	Package string `gorm:"type:varchar(20)" json:"package,omitempty"` // basic, standard or premium
+	Addons  string `gorm:"type:text" json:"addons,omitempty"`         // JSON array of the add-ons ordered
+
 	// Step 1: Harga
 	Price       int64 `json:"price"`        // Harga Pekerjaan
-	PlatformFee int64 `json:"platform_fee"` // Komisi Platform (10%)
+	PlatformFee int64 `json:"platform_fee"` // Komisi Platform (sesuai commission rule)
 	NetAmount   int64 `json:"net_amount"`   // Anda akan menerima
 
+	// Commission snapshot at the time the offer terms were set, so later rule changes don't alter the order
+	CommissionRuleID  *uuid.UUID `gorm:"type:uuid" json:"commission_rule_id,omitempty"`
+	CommissionRateBps int        `gorm:"not null;default:1000" json:"commission_rate_bps"` // 1000 = 10%
+	CommissionFlatFee int64      `gorm:"not null;default:0" json:"commission_flat_fee"`
+
 	// Step 2: Deskripsi
 	Title         string `json:"title"`
 	Description   string `json:"description"`    // Deskripsi Pekerjaan dan Langkah Kerja
@@ -39,17 +52,53 @@ type JobOffer struct {
 
 	// Step 3: Hasil Pekerjaan
 	StartDate      time.Time `json:"start_date"`
-	DeliveryDate   time.Time `json:"delivery_date"`
+	DeliveryDate   time.Time `json:"delivery_date"`   // Midnight (Asia/Jakarta) of the due day
 	DeliveryFormat string    `json:"delivery_format"` // e.g., .pdf, .png
 	Notes          string    `json:"notes"`           // Catatan tambahan
 
+	// Set by the deadline worker once the delivery date has passed without a delivery
+	IsOverdue bool       `gorm:"not null;default:false;index" json:"is_overdue"`
+	OverdueAt *time.Time `json:"overdue_at,omitempty"`
+
 	// Submission Data
 	WorkDeliveryLink  string `json:"work_delivery_link"`
 	WorkDeliveryFiles string `json:"work_delivery_files"` // JSON string or comma-separated URLs
 	UsedRevisionCount int    `gorm:"default:0" json:"used_revision_count"`
 
+	// Voucher applied when the order was paid
+	VoucherID        *uuid.UUID     `gorm:"type:uuid" json:"voucher_id,omitempty"`
+	DiscountAmount   int64          `gorm:"not null;default:0" json:"discount_amount"`
+	DiscountFundedBy VoucherFunding `gorm:"type:varchar(20)" json:"discount_funded_by,omitempty"`
+
+	// Paid extras bought after the order started (extra revisions), held in escrow with the order
+	ExtrasAmount int64 `gorm:"not null;default:0" json:"extras_amount"`
+	ExtrasFee    int64 `gorm:"not null;default:0" json:"extras_fee"` // Platform commission on ExtrasAmount
+
+	RefundedAmount int64 `gorm:"not null;default:0" json:"refunded_amount"` // Part of the amount paid refunded to the client
+
+	// Milestone orders ("" for a single delivery). With per-milestone funding only the first
+	// milestone is charged at checkout; DeferredAmount is the rest of the price, funded later
+	// milestone by milestone (DeferredFunded so far).
+	MilestonePayment MilestonePayment `gorm:"type:varchar(20)" json:"milestone_payment,omitempty"`
+	DeferredAmount   int64            `gorm:"not null;default:0" json:"deferred_amount"`
+	DeferredFunded   int64            `gorm:"not null;default:0" json:"deferred_funded"`
+
+	// Escrow already released on completed milestones
+	ReleasedAmount int64 `gorm:"not null;default:0" json:"released_amount"`
+	ReleasedNet    int64 `gorm:"not null;default:0" json:"released_net"`
+	ReleasedFee    int64 `gorm:"not null;default:0" json:"released_fee"`
+
 	Status JobOfferStatus `gorm:"default:pending" json:"status"`
 
+	// End of the validity period of a pending offer; the expiry worker closes it once passed
+	ExpiresAt *time.Time `gorm:"index" json:"expires_at,omitempty"`
+
+	// Negotiation: an accepted counter-offer creates a new version that supersedes this one
+	Version         int        `gorm:"not null;default:1" json:"version"`
+	PreviousOfferID *uuid.UUID `gorm:"type:uuid" json:"previous_offer_id,omitempty"`
+	SupersededByID  *uuid.UUID `gorm:"type:uuid" json:"superseded_by_id,omitempty"`
+	DeclineReason   string     `gorm:"type:text" json:"decline_reason,omitempty"`
+
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 
@@ -58,6 +107,97 @@ type JobOffer struct {
 	Freelancer   *User         `gorm:"foreignKey:FreelancerID" json:"freelancer,omitempty"`
 	Client       *User         `gorm:"foreignKey:ClientID" json:"client,omitempty"`
 	Product      *Product      `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+	Milestones   []Milestone   `gorm:"foreignKey:JobOfferID" json:"milestones,omitempty"`
+}
+
+// HasMilestones tells whether the order is delivered and released milestone by milestone
+func (o *JobOffer) HasMilestones() bool {
+	return o.MilestonePayment != ""
+}
+
+// IsExpired tells whether a pending offer is past its validity period and can no longer be paid
+func (o *JobOffer) IsExpired(now time.Time) bool {
+	return o.Status == OfferStatusPending && o.ExpiresAt != nil && !now.Before(*o.ExpiresAt)
+}
+
+// CheckoutAmount is the part of the price charged at checkout, before the voucher discount
+func (o *JobOffer) CheckoutAmount() int64 {
+	return o.Price - o.DeferredAmount
+}
+
+// AmountPaid is what the client paid for the order: the price minus the voucher discount,
+// plus paid extras
+func (o *JobOffer) AmountPaid() int64 {
+	return o.Price - o.DiscountAmount + o.ExtrasAmount
+}
+
+// EscrowRemaining is the client money still held in escrow for the order: what was paid so
+// far, minus refunds and milestone releases
+func (o *JobOffer) EscrowRemaining() int64 {
+	unfunded := o.DeferredAmount - o.DeferredFunded
+	return o.AmountPaid() - unfunded - o.RefundedAmount - o.ReleasedAmount
+}
+
+// ReleaseShare is one escrow release: Escrow leaves escrow, Net goes to the freelancer and
+// Fee to the platform. Promotions covers the difference (a platform-funded discount).
+type ReleaseShare struct {
+	Escrow int64
+	Net    int64
+	Fee    int64
+}
+
+func (r ReleaseShare) Promotions() int64 {
+	return r.Net + r.Fee - r.Escrow
+}
+
+// NextRelease returns what to release now so that the total released matches the share of
+// the price covered by completed milestones, completed being the sum of their amounts. The
+// whole order (completed >= Price) releases exactly what EscrowRelease says, minus earlier
+// releases. Escrow never goes below zero: with per-milestone funding a checkout discount only
+// came out of the first milestone, so promotions bridges the gap until the last release.
+func (o *JobOffer) NextRelease(completed int64) ReleaseShare {
+	net, fee := o.EscrowRelease()
+	escrow := o.AmountPaid() - o.RefundedAmount
+	if completed < o.Price && o.Price > 0 {
+		escrow = escrow * completed / o.Price
+		net = net * completed / o.Price
+		fee = fee * completed / o.Price
+	}
+	return ReleaseShare{
+		Escrow: min(max(escrow-o.ReleasedAmount, 0), o.EscrowRemaining()),
+		Net:    max(net-o.ReleasedNet, 0),
+		Fee:    max(fee-o.ReleasedFee, 0),
+	}
+}
+
+// MarkReleased records a release on the offer
+func (o *JobOffer) MarkReleased(r ReleaseShare) {
+	o.ReleasedAmount += r.Escrow
+	o.ReleasedNet += r.Net
+	o.ReleasedFee += r.Fee
+}
+
+// EscrowRelease splits a completed order between the freelancer and the platform.
+// A freelancer-funded discount comes out of the freelancer's net; a platform-funded one is
+// paid by the platform on top of the escrow (net + fee - EscrowRemaining). After partial
+// refunds both shares are scaled to what is left of the amount paid.
+func (o *JobOffer) EscrowRelease() (net, fee int64) {
+	net, fee = o.NetAmount+o.ExtrasAmount-o.ExtrasFee, o.PlatformFee+o.ExtrasFee
+	var subsidy int64
+	switch o.DiscountFundedBy {
+	case VoucherFundedByFreelancer:
+		net -= o.DiscountAmount
+	case VoucherFundedByPlatform:
+		subsidy = o.DiscountAmount
+	}
+
+	paid := o.AmountPaid()
+	if o.RefundedAmount == 0 || paid == 0 {
+		return net, fee
+	}
+	remaining := paid - o.RefundedAmount
+	fee = fee * remaining / paid
+	return remaining + subsidy*remaining/paid - fee, fee
 }
 
 // GenerateOrderCode generates a random alphanumeric code
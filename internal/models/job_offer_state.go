@@ -0,0 +1,104 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OfferActor is who triggers an order status transition
+type OfferActor string
+
+const (
+	OfferActorClient     OfferActor = "client"
+	OfferActorFreelancer OfferActor = "freelancer"
+	OfferActorAdmin      OfferActor = "admin"
+	OfferActorSystem     OfferActor = "system" // Payment callbacks, workers
+)
+
+var (
+	ErrTransitionNotAllowed = errors.New("order status transition not allowed")
+	ErrActorNotAllowed      = errors.New("not allowed to perform this order status transition")
+)
+
+type offerTransition struct {
+	From JobOfferStatus
+	To   JobOfferStatus
+}
+
+type transitionRule struct {
+	Actors []OfferActor
+	// Direct transitions carry no side effects and may be requested through the generic
+	// status endpoint; every other one belongs to its own flow (payment, delivery, escrow release, ...)
+	Direct bool
+}
+
+// offerTransitions is the order state machine: every allowed status change and who may trigger it
+var offerTransitions = map[offerTransition]transitionRule{
+	{OfferStatusPending, OfferStatusPaid}:        {Actors: []OfferActor{OfferActorSystem}},                                                          // Payment confirmed
+	{OfferStatusPending, OfferStatusCancelled}:   {Actors: []OfferActor{OfferActorFreelancer, OfferActorAdmin, OfferActorSystem}},                   // Offer withdrawn
+	{OfferStatusPending, OfferStatusExpired}:     {Actors: []OfferActor{OfferActorSystem}},                                                          // Not paid within the validity period
+	{OfferStatusPending, OfferStatusDeclined}:    {Actors: []OfferActor{OfferActorClient}},                                                          // Declined by the client
+	{OfferStatusPending, OfferStatusSuperseded}:  {Actors: []OfferActor{OfferActorFreelancer}},                                                      // Counter-offer accepted
+	{OfferStatusPaid, OfferStatusWorking}:        {Actors: []OfferActor{OfferActorFreelancer}, Direct: true},                                        // Work started
+	{OfferStatusPaid, OfferStatusDelivered}:      {Actors: []OfferActor{OfferActorFreelancer}},                                                      // Delivery
+	{OfferStatusPaid, OfferStatusCancelled}:      {Actors: []OfferActor{OfferActorFreelancer, OfferActorClient, OfferActorAdmin, OfferActorSystem}}, // Cancelled with refund (client: overdue)
+	{OfferStatusPaid, OfferStatusCompleted}:      {Actors: []OfferActor{OfferActorSystem}},                                                          // Last milestone approved
+	{OfferStatusWorking, OfferStatusDelivered}:   {Actors: []OfferActor{OfferActorFreelancer}},                                                      // Delivery
+	{OfferStatusWorking, OfferStatusCancelled}:   {Actors: []OfferActor{OfferActorClient, OfferActorAdmin, OfferActorSystem}},                       // Overdue / gateway refund
+	{OfferStatusWorking, OfferStatusCompleted}:   {Actors: []OfferActor{OfferActorSystem}},                                                          // Last milestone approved
+	{OfferStatusWorking, OfferStatusDisputed}:    {Actors: []OfferActor{OfferActorClient}},                                                          // Dispute opened
+	{OfferStatusDelivered, OfferStatusWorking}:   {Actors: []OfferActor{OfferActorClient}},                                                          // Revision requested
+	{OfferStatusDelivered, OfferStatusCompleted}: {Actors: []OfferActor{OfferActorClient, OfferActorSystem}},                                        // Accepted / auto-completed
+	{OfferStatusDelivered, OfferStatusCancelled}: {Actors: []OfferActor{OfferActorAdmin, OfferActorSystem}},                                         // Gateway refund
+	{OfferStatusDelivered, OfferStatusDisputed}:  {Actors: []OfferActor{OfferActorClient}},                                                          // Dispute opened
+	{OfferStatusDisputed, OfferStatusWorking}:    {Actors: []OfferActor{OfferActorClient}},                                                          // Dispute withdrawn
+	{OfferStatusDisputed, OfferStatusDelivered}:  {Actors: []OfferActor{OfferActorClient}},                                                          // Dispute withdrawn
+	{OfferStatusDisputed, OfferStatusCompleted}:  {Actors: []OfferActor{OfferActorAdmin}},                                                           // Resolved as release or split
+	{OfferStatusDisputed, OfferStatusCancelled}:  {Actors: []OfferActor{OfferActorAdmin, OfferActorSystem}},                                         // Resolved as refund / gateway refund
+	{OfferStatusCompleted, OfferStatusCancelled}: {Actors: []OfferActor{OfferActorSystem}},                                                          // Gateway refund before the earnings cleared
+}
+
+// CanTransition checks a status change against the state machine
+func (o *JobOffer) CanTransition(to JobOfferStatus, actor OfferActor) error {
+	rule, ok := offerTransitions[offerTransition{o.Status, to}]
+	if !ok {
+		return fmt.Errorf("%w: %s -> %s", ErrTransitionNotAllowed, o.Status, to)
+	}
+	for _, a := range rule.Actors {
+		if a == actor {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s cannot move an order from %s to %s", ErrActorNotAllowed, actor, o.Status, to)
+}
+
+// Transition moves the offer to a new status if the state machine allows it. Callers run the
+// side effects of the transition (escrow, wallets, ledger) only after it succeeded, in the same
+// DB transaction that saves the offer.
+func (o *JobOffer) Transition(to JobOfferStatus, actor OfferActor) error {
+	if err := o.CanTransition(to, actor); err != nil {
+		return err
+	}
+	o.Status = to
+	o.UpdatedAt = time.Now()
+	return nil
+}
+
+// IsDirectTransition tells whether a status change may be requested through the generic status endpoint
+func IsDirectTransition(from, to JobOfferStatus) bool {
+	return offerTransitions[offerTransition{from, to}].Direct
+}
+
+// ActorFor returns the role userID plays on the offer, or "" when they are not part of it
+func (o *JobOffer) ActorFor(userID uuid.UUID) OfferActor {
+	switch userID {
+	case o.ClientID:
+		return OfferActorClient
+	case o.FreelancerID:
+		return OfferActorFreelancer
+	}
+	return ""
+}
@@ -0,0 +1,153 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestCanTransitionRejectsActors(t *testing.T) {
+	tests := []struct {
+		from  JobOfferStatus
+		to    JobOfferStatus
+		actor OfferActor
+	}{
+		// Only payment confirmation marks an order paid
+		{OfferStatusPending, OfferStatusPaid, OfferActorClient},
+		{OfferStatusPending, OfferStatusPaid, OfferActorFreelancer},
+		{OfferStatusPending, OfferStatusPaid, OfferActorAdmin},
+		// Only the freelancer starts and delivers the work
+		{OfferStatusPaid, OfferStatusWorking, OfferActorClient},
+		{OfferStatusPaid, OfferStatusWorking, OfferActorAdmin},
+		{OfferStatusWorking, OfferStatusDelivered, OfferActorClient},
+		{OfferStatusWorking, OfferStatusDelivered, OfferActorSystem},
+		// Only the client accepts a delivery (or the auto-completion worker)
+		{OfferStatusDelivered, OfferStatusCompleted, OfferActorFreelancer},
+		{OfferStatusDelivered, OfferStatusCompleted, OfferActorAdmin},
+		// Only the client asks for a revision or opens a dispute
+		{OfferStatusDelivered, OfferStatusWorking, OfferActorFreelancer},
+		{OfferStatusWorking, OfferStatusDisputed, OfferActorFreelancer},
+		{OfferStatusDelivered, OfferStatusDisputed, OfferActorAdmin},
+		// A freelancer cannot cancel a started order; a client cannot cancel a delivered one
+		{OfferStatusWorking, OfferStatusCancelled, OfferActorFreelancer},
+		{OfferStatusDelivered, OfferStatusCancelled, OfferActorClient},
+		// Disputes are settled by an admin or withdrawn by the client
+		{OfferStatusDisputed, OfferStatusCompleted, OfferActorClient},
+		{OfferStatusDisputed, OfferStatusCompleted, OfferActorSystem},
+		{OfferStatusDisputed, OfferStatusWorking, OfferActorFreelancer},
+		// Offer lifecycle
+		{OfferStatusPending, OfferStatusCancelled, OfferActorClient},
+		{OfferStatusPending, OfferStatusExpired, OfferActorFreelancer},
+		{OfferStatusPending, OfferStatusDeclined, OfferActorFreelancer},
+		{OfferStatusPending, OfferStatusSuperseded, OfferActorClient},
+		{OfferStatusCompleted, OfferStatusCancelled, OfferActorAdmin},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"->"+string(tt.to)+" by "+string(tt.actor), func(t *testing.T) {
+			o := JobOffer{Status: tt.from}
+			err := o.Transition(tt.to, tt.actor)
+			if !errors.Is(err, ErrActorNotAllowed) {
+				t.Fatalf("Transition() error = %v, want ErrActorNotAllowed", err)
+			}
+			if o.Status != tt.from {
+				t.Fatalf("status changed to %s on a rejected transition", o.Status)
+			}
+		})
+	}
+}
+
+func TestCanTransitionRejectsTransitions(t *testing.T) {
+	actors := []OfferActor{OfferActorClient, OfferActorFreelancer, OfferActorAdmin, OfferActorSystem}
+	tests := []struct {
+		from JobOfferStatus
+		to   JobOfferStatus
+	}{
+		{OfferStatusPending, OfferStatusWorking},
+		{OfferStatusPending, OfferStatusDelivered},
+		{OfferStatusPending, OfferStatusCompleted},
+		{OfferStatusPending, OfferStatusDisputed},
+		{OfferStatusPaid, OfferStatusPending},
+		{OfferStatusPaid, OfferStatusDisputed},
+		{OfferStatusWorking, OfferStatusPaid},
+		{OfferStatusDelivered, OfferStatusPaid},
+		{OfferStatusCompleted, OfferStatusWorking},
+		{OfferStatusCompleted, OfferStatusDisputed},
+		{OfferStatusCancelled, OfferStatusPaid},
+		{OfferStatusCancelled, OfferStatusPending},
+		{OfferStatusExpired, OfferStatusPaid},
+		{OfferStatusDeclined, OfferStatusPending},
+		{OfferStatusSuperseded, OfferStatusPaid},
+		{OfferStatusDisputed, OfferStatusPaid},
+	}
+
+	for _, tt := range tests {
+		for _, actor := range actors {
+			t.Run(string(tt.from)+"->"+string(tt.to)+" by "+string(actor), func(t *testing.T) {
+				o := JobOffer{Status: tt.from}
+				if err := o.CanTransition(tt.to, actor); !errors.Is(err, ErrTransitionNotAllowed) {
+					t.Fatalf("CanTransition() error = %v, want ErrTransitionNotAllowed", err)
+				}
+			})
+		}
+	}
+}
+
+func TestTransitionAllowed(t *testing.T) {
+	tests := []struct {
+		from  JobOfferStatus
+		to    JobOfferStatus
+		actor OfferActor
+	}{
+		{OfferStatusPending, OfferStatusPaid, OfferActorSystem},
+		{OfferStatusPaid, OfferStatusWorking, OfferActorFreelancer},
+		{OfferStatusWorking, OfferStatusDelivered, OfferActorFreelancer},
+		{OfferStatusDelivered, OfferStatusWorking, OfferActorClient},
+		{OfferStatusDelivered, OfferStatusCompleted, OfferActorClient},
+		{OfferStatusDelivered, OfferStatusCompleted, OfferActorSystem},
+		{OfferStatusDisputed, OfferStatusCancelled, OfferActorAdmin},
+		{OfferStatusCompleted, OfferStatusCancelled, OfferActorSystem},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"->"+string(tt.to)+" by "+string(tt.actor), func(t *testing.T) {
+			o := JobOffer{Status: tt.from}
+			if err := o.Transition(tt.to, tt.actor); err != nil {
+				t.Fatalf("Transition() error = %v", err)
+			}
+			if o.Status != tt.to {
+				t.Fatalf("status = %s, want %s", o.Status, tt.to)
+			}
+		})
+	}
+}
+
+func TestIsDirectTransition(t *testing.T) {
+	if !IsDirectTransition(OfferStatusPaid, OfferStatusWorking) {
+		t.Fatal("paid -> working should be requestable through the status endpoint")
+	}
+	// Transitions that move money must go through their own flow
+	for _, to := range []JobOfferStatus{OfferStatusCompleted, OfferStatusCancelled, OfferStatusDelivered} {
+		if IsDirectTransition(OfferStatusWorking, to) {
+			t.Fatalf("working -> %s must not be a direct transition", to)
+		}
+	}
+}
+
+func TestActorFor(t *testing.T) {
+	o := JobOffer{ClientID: uuid.New(), FreelancerID: uuid.New()}
+	tests := []struct {
+		userID uuid.UUID
+		want   OfferActor
+	}{
+		{o.ClientID, OfferActorClient},
+		{o.FreelancerID, OfferActorFreelancer},
+		{uuid.New(), ""},
+	}
+	for _, tt := range tests {
+		if got := o.ActorFor(tt.userID); got != tt.want {
+			t.Fatalf("ActorFor(%s) = %q, want %q", tt.userID, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package models
+
+import "testing"
+
+// offer100k is a 100.000 order at a 10% platform fee
+func offer100k() JobOffer {
+	return JobOffer{Price: 100000, PlatformFee: 10000, NetAmount: 90000}
+}
+
+func TestEscrowRelease(t *testing.T) {
+	tests := []struct {
+		name     string
+		edit     func(o *JobOffer)
+		wantNet  int64
+		wantFee  int64
+		wantPaid int64 // Escrow released: AmountPaid - RefundedAmount
+	}{
+		{
+			name:     "no voucher",
+			edit:     func(o *JobOffer) {},
+			wantNet:  90000,
+			wantFee:  10000,
+			wantPaid: 100000,
+		},
+		{
+			name: "freelancer-funded voucher comes out of the net",
+			edit: func(o *JobOffer) {
+				o.DiscountAmount, o.DiscountFundedBy = 20000, VoucherFundedByFreelancer
+			},
+			wantNet:  70000,
+			wantFee:  10000,
+			wantPaid: 80000,
+		},
+		{
+			name: "platform-funded voucher is paid on top of the escrow",
+			edit: func(o *JobOffer) {
+				o.DiscountAmount, o.DiscountFundedBy = 20000, VoucherFundedByPlatform
+			},
+			wantNet:  90000,
+			wantFee:  10000,
+			wantPaid: 80000,
+		},
+		{
+			name: "partial refund scales both shares",
+			edit: func(o *JobOffer) {
+				o.RefundedAmount = 30000
+			},
+			wantNet:  63000,
+			wantFee:  7000,
+			wantPaid: 70000,
+		},
+		{
+			name: "partial refund with a platform-funded voucher keeps the subsidy share",
+			edit: func(o *JobOffer) {
+				o.DiscountAmount, o.DiscountFundedBy = 20000, VoucherFundedByPlatform
+				o.RefundedAmount = 20000
+			},
+			wantNet:  67500,
+			wantFee:  7500,
+			wantPaid: 60000,
+		},
+		{
+			name: "partial refund with a freelancer-funded voucher",
+			edit: func(o *JobOffer) {
+				o.DiscountAmount, o.DiscountFundedBy = 20000, VoucherFundedByFreelancer
+				o.RefundedAmount = 20000
+			},
+			wantNet:  52500,
+			wantFee:  7500,
+			wantPaid: 60000,
+		},
+		{
+			name: "paid extras and their commission",
+			edit: func(o *JobOffer) {
+				o.ExtrasAmount, o.ExtrasFee = 20000, 2000
+			},
+			wantNet:  108000,
+			wantFee:  12000,
+			wantPaid: 120000,
+		},
+		{
+			name: "fully refunded",
+			edit: func(o *JobOffer) {
+				o.RefundedAmount = 100000
+			},
+			wantNet:  0,
+			wantFee:  0,
+			wantPaid: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := offer100k()
+			tt.edit(&o)
+
+			net, fee := o.EscrowRelease()
+			if net != tt.wantNet || fee != tt.wantFee {
+				t.Fatalf("EscrowRelease() = (%d, %d), want (%d, %d)", net, fee, tt.wantNet, tt.wantFee)
+			}
+
+			// Releasing the whole order at once matches EscrowRelease
+			share := o.NextRelease(o.Price)
+			want := ReleaseShare{Escrow: tt.wantPaid, Net: tt.wantNet, Fee: tt.wantFee}
+			if share != want {
+				t.Fatalf("NextRelease(Price) = %+v, want %+v", share, want)
+			}
+		})
+	}
+}
+
+func TestNextReleaseMilestones(t *testing.T) {
+	type step struct {
+		fund      int64 // Deferred amount funded before this release
+		completed int64 // Sum of the completed milestones
+		want      ReleaseShare
+	}
+
+	tests := []struct {
+		name  string
+		offer JobOffer
+		steps []step
+	}{
+		{
+			name:  "rounding of thirds is settled by the last release",
+			offer: offer100k(),
+			steps: []step{
+				{completed: 33333, want: ReleaseShare{Escrow: 33333, Net: 29999, Fee: 3333}},
+				{completed: 66666, want: ReleaseShare{Escrow: 33333, Net: 30000, Fee: 3333}},
+				{completed: 100000, want: ReleaseShare{Escrow: 33334, Net: 30001, Fee: 3334}},
+			},
+		},
+		{
+			name: "releases after a partial refund",
+			offer: func() JobOffer {
+				o := offer100k()
+				o.RefundedAmount = 10000
+				return o
+			}(),
+			steps: []step{
+				{completed: 50000, want: ReleaseShare{Escrow: 45000, Net: 40500, Fee: 4500}},
+				{completed: 100000, want: ReleaseShare{Escrow: 45000, Net: 40500, Fee: 4500}},
+			},
+		},
+		{
+			name: "per-milestone funding with a platform-funded voucher on the first milestone",
+			offer: JobOffer{
+				Price: 90000, PlatformFee: 9000, NetAmount: 81000,
+				MilestonePayment: MilestonePaymentPerMilestone, DeferredAmount: 60000,
+				DiscountAmount: 10000, DiscountFundedBy: VoucherFundedByPlatform,
+			},
+			steps: []step{
+				// Only 20.000 is in escrow: promotions bridges the rest of the first milestone
+				{completed: 30000, want: ReleaseShare{Escrow: 20000, Net: 27000, Fee: 3000}},
+				{fund: 30000, completed: 60000, want: ReleaseShare{Escrow: 30000, Net: 27000, Fee: 3000}},
+				{fund: 30000, completed: 90000, want: ReleaseShare{Escrow: 30000, Net: 27000, Fee: 3000}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := tt.offer
+			var total ReleaseShare
+			for i, s := range tt.steps {
+				o.DeferredFunded += s.fund
+				got := o.NextRelease(s.completed)
+				if got != s.want {
+					t.Fatalf("release %d: NextRelease(%d) = %+v, want %+v", i+1, s.completed, got, s.want)
+				}
+				if got.Escrow > o.EscrowRemaining() {
+					t.Fatalf("release %d takes %d out of escrow, only %d left", i+1, got.Escrow, o.EscrowRemaining())
+				}
+				o.MarkReleased(got)
+				total.Escrow += got.Escrow
+				total.Net += got.Net
+				total.Fee += got.Fee
+			}
+
+			// Once every milestone is complete the releases add up to the whole order
+			net, fee := o.EscrowRelease()
+			if total.Net != net || total.Fee != fee || total.Escrow != o.AmountPaid()-o.RefundedAmount {
+				t.Fatalf("releases add up to %+v, want escrow %d, net %d, fee %d", total, o.AmountPaid()-o.RefundedAmount, net, fee)
+			}
+			if o.EscrowRemaining() != 0 {
+				t.Fatalf("escrow left after the last release = %d, want 0", o.EscrowRemaining())
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type LedgerAccountType string
+
+const (
+	LedgerAsset     LedgerAccountType = "asset"     // Kas / dana di payment gateway
+	LedgerLiability LedgerAccountType = "liability" // Dana milik pengguna (saldo, escrow, hold)
+	LedgerRevenue   LedgerAccountType = "revenue"   // Pendapatan platform
+	LedgerExpense   LedgerAccountType = "expense"   // Biaya (fee payment gateway)
+)
+
+// IsDebitNormal reports whether the account balance grows with debits (assets and expenses).
+func (t LedgerAccountType) IsDebitNormal() bool {
+	return t == LedgerAsset || t == LedgerExpense
+}
+
+// LedgerAccount is a double-entry account. Balance is kept on the account's normal side
+// (debit-positive for assets/expenses, credit-positive for liabilities/revenue).
+type LedgerAccount struct {
+	ID      uuid.UUID         `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	Code    string            `gorm:"type:varchar(80);uniqueIndex;not null" json:"code"` // e.g. PLATFORM_ESCROW, CLIENT_WALLET:{userID}
+	Name    string            `gorm:"type:varchar(150);not null" json:"name"`
+	Type    LedgerAccountType `gorm:"type:varchar(20);not null;index" json:"type"`
+	OwnerID *uuid.UUID        `gorm:"type:uuid;index" json:"owner_id,omitempty"`
+	Balance int64             `gorm:"not null;default:0" json:"balance"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// JournalEntry groups balanced debit/credit lines for one business event.
+type JournalEntry struct {
+	ID            uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	ReferenceType string     `gorm:"type:varchar(30);index:idx_journal_reference" json:"reference_type"` // job_offer, transaction, withdrawal
+	ReferenceID   *uuid.UUID `gorm:"type:uuid;index:idx_journal_reference" json:"reference_id,omitempty"`
+	Description   string     `gorm:"type:text" json:"description"`
+	CreatedAt     time.Time  `json:"created_at"`
+
+	Lines []JournalLine `gorm:"foreignKey:EntryID" json:"lines,omitempty"`
+}
+
+// JournalLine is one side of a journal entry. BalanceAfter snapshots the account
+// balance right after this line was applied.
+type JournalLine struct {
+	ID           uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	EntryID      uuid.UUID `gorm:"type:uuid;index;not null" json:"entry_id"`
+	AccountID    uuid.UUID `gorm:"type:uuid;index;not null" json:"account_id"`
+	Debit        int64     `gorm:"not null;default:0" json:"debit"`
+	Credit       int64     `gorm:"not null;default:0" json:"credit"`
+	BalanceAfter int64     `gorm:"not null" json:"balance_after"`
+	CreatedAt    time.Time `json:"created_at"`
+
+	Account *LedgerAccount `gorm:"foreignKey:AccountID" json:"account,omitempty"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MilestonePayment tells how the milestones of an order are funded
+type MilestonePayment string
+
+const (
+	MilestonePaymentUpfront      MilestonePayment = "upfront"       // Seluruh harga dibayar saat checkout
+	MilestonePaymentPerMilestone MilestonePayment = "per_milestone" // Checkout membayar milestone pertama, sisanya didanai satu per satu dari saldo
+)
+
+type MilestoneStatus string
+
+const (
+	MilestoneStatusPending   MilestoneStatus = "pending"   // Belum didanai
+	MilestoneStatusFunded    MilestoneStatus = "funded"    // Dana di escrow, sedang dikerjakan
+	MilestoneStatusDelivered MilestoneStatus = "delivered" // Menunggu persetujuan pembeli
+	MilestoneStatusCompleted MilestoneStatus = "completed" // Disetujui, dana dilepas
+	MilestoneStatusCancelled MilestoneStatus = "cancelled"
+)
+
+// Milestone is one part (e.g. a thesis chapter) of a larger order, with its own amount,
+// due date and deliverables. The amounts of an order's milestones add up to its price.
+type Milestone struct {
+	ID           uuid.UUID       `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	JobOfferID   uuid.UUID       `gorm:"type:uuid;index;not null" json:"job_offer_id"`
+	Sequence     int             `gorm:"not null" json:"sequence"` // 1-based
+	Title        string          `gorm:"type:varchar(200);not null" json:"title"`
+	Description  string          `gorm:"type:text" json:"description"`
+	Deliverables string          `gorm:"type:text" json:"deliverables"`
+	Amount       int64           `gorm:"not null" json:"amount"`
+	DueDate      time.Time       `json:"due_date"`
+	Status       MilestoneStatus `gorm:"type:varchar(20);not null;default:'pending';index" json:"status"`
+
+	RevisionCount     int `gorm:"not null;default:0" json:"revision_count"`
+	UsedRevisionCount int `gorm:"not null;default:0" json:"used_revision_count"`
+
+	WorkDeliveryLink  string `gorm:"type:text" json:"work_delivery_link"`
+	WorkDeliveryFiles string `gorm:"type:text" json:"work_delivery_files"` // JSON array of file URLs
+
+	FundedAt    *time.Time `json:"funded_at,omitempty"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+type PaymentCallbackOutcome string
+
+const (
+	CallbackOutcomeApplied          PaymentCallbackOutcome = "applied"           // Status change applied
+	CallbackOutcomeIgnored          PaymentCallbackOutcome = "ignored"           // Duplicate / out of order / unknown reference
+	CallbackOutcomeInvalidSignature PaymentCallbackOutcome = "invalid_signature" // Rejected, signature mismatch
+	CallbackOutcomeInvalidPayload   PaymentCallbackOutcome = "invalid_payload"   // Rejected, body could not be parsed
+	CallbackOutcomeError            PaymentCallbackOutcome = "error"             // Processing failed (see Error)
+)
+
+// PaymentCallback is the raw journal of every payment gateway callback received,
+// kept for debugging and for replaying a callback after a bug fix.
+type PaymentCallback struct {
+	ID      uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	Gateway string    `gorm:"type:varchar(20)" json:"gateway"`
+
+	Headers        datatypes.JSON `json:"headers"`
+	RawBody        string         `gorm:"type:text" json:"raw_body"`
+	Signature      string         `gorm:"type:varchar(255)" json:"signature"`
+	SignatureValid bool           `json:"signature_valid"`
+
+	// Parsed from the body when possible
+	Reference   string `gorm:"type:varchar(50);index" json:"reference"`
+	MerchantRef string `gorm:"type:varchar(50);index" json:"merchant_ref"`
+	Status      string `gorm:"type:varchar(20)" json:"status"`
+
+	Outcome     PaymentCallbackOutcome `gorm:"type:varchar(20);index" json:"outcome"`
+	Event       string                 `gorm:"type:varchar(30)" json:"event,omitempty"` // What it meant for the order (paid, expired, ...)
+	Error       string                 `gorm:"type:text" json:"error,omitempty"`
+	ReplayOf    *uuid.UUID             `gorm:"type:uuid;index" json:"replay_of,omitempty"`
+	ProcessedAt *time.Time             `json:"processed_at,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type PayoutAccountType string
+
+const (
+	PayoutAccountBank    PayoutAccountType = "bank"
+	PayoutAccountEWallet PayoutAccountType = "ewallet"
+)
+
+// PayoutProviders lists the supported payout destinations per account type,
+// keyed by provider code with the display name as value.
+var PayoutProviders = map[PayoutAccountType]map[string]string{
+	PayoutAccountBank: {
+		"BCA":     "Bank Central Asia",
+		"BNI":     "Bank Negara Indonesia",
+		"BRI":     "Bank Rakyat Indonesia",
+		"MANDIRI": "Bank Mandiri",
+		"BSI":     "Bank Syariah Indonesia",
+		"BTN":     "Bank Tabungan Negara",
+		"CIMB":    "CIMB Niaga",
+		"PERMATA": "Bank Permata",
+		"DANAMON": "Bank Danamon",
+	},
+	PayoutAccountEWallet: {
+		"DANA":      "DANA",
+		"OVO":       "OVO",
+		"GOPAY":     "GoPay",
+		"SHOPEEPAY": "ShopeePay",
+		"LINKAJA":   "LinkAja",
+	},
+}
+
+// PayoutAccount is a bank account or e-wallet where a freelancer receives withdrawals.
+// The account number is stored encrypted and must only ever be returned masked.
+type PayoutAccount struct {
+	ID     uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID uuid.UUID `gorm:"type:uuid;index;not null" json:"user_id"`
+
+	Type         PayoutAccountType `gorm:"type:varchar(20);not null" json:"type"`
+	ProviderCode string            `gorm:"type:varchar(30);not null" json:"provider_code"` // e.g. BCA, DANA
+	HolderName   string            `gorm:"type:varchar(120);not null" json:"holder_name"`
+
+	AccountNumberEnc    string `gorm:"type:text;not null" json:"-"`
+	AccountNumberMasked string `gorm:"type:varchar(50)" json:"account_number_masked"`
+
+	IsPrimary bool `gorm:"not null;default:false" json:"is_primary"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PayoutAccountView is the audit trail of admins revealing the full account number of a
+// withdrawal or refund destination.
+type PayoutAccountView struct {
+	ID          uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	ViewerID    uuid.UUID `gorm:"type:uuid;index;not null" json:"viewer_id"`
+	SubjectType string    `gorm:"type:varchar(20);not null;index:idx_payout_view_subject" json:"subject_type"` // withdrawal | refund
+	SubjectID   uuid.UUID `gorm:"type:uuid;not null;index:idx_payout_view_subject" json:"subject_id"`
+	IPAddress   string    `gorm:"type:varchar(64)" json:"ip_address"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
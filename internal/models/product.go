@@ -22,6 +22,7 @@ type Product struct {
 	// Paket & Portofolio disimpan sebagai JSON biar fleksibel dulu
 	Packages  datatypes.JSON `json:"packages"`  // { basic: {...}, standard: {...}, premium: {...} }
 	Portfolio datatypes.JSON `json:"portfolio"` // { video_url: "...", images: [...] }
+	Addons    datatypes.JSON `json:"addons"`    // [{ id, title, price, delivery_days, revisions }], tambahan opsional saat order
 
 	Status string `gorm:"type:varchar(20);default:'draft'" json:"status"` // draft | review | published, dll
 
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+type ReconciliationStatus string
+
+const (
+	ReconciliationRunning ReconciliationStatus = "running"
+	ReconciliationClean   ReconciliationStatus = "clean"  // Tidak ada selisih
+	ReconciliationDrift   ReconciliationStatus = "drift"  // Ada selisih / anomali
+	ReconciliationFailed  ReconciliationStatus = "failed" // Job error
+)
+
+// ReconciliationReport stores the outcome of one wallet/escrow reconciliation run.
+type ReconciliationReport struct {
+	ID      uuid.UUID            `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	Trigger string               `gorm:"type:varchar(20);not null" json:"trigger"` // scheduled | manual | cli
+	Status  ReconciliationStatus `gorm:"type:varchar(20);not null;index" json:"status"`
+
+	IssueCount int            `gorm:"not null;default:0" json:"issue_count"`
+	Summary    datatypes.JSON `json:"summary"` // { check_name: issue_count }
+	Issues     datatypes.JSON `json:"issues"`  // []ReconciliationIssue
+	Error      string         `gorm:"type:text" json:"error,omitempty"`
+
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type RefundStatus string
+
+const (
+	RefundStatusAwaitingClient RefundStatus = "awaiting_client" // Menunggu klien memilih tujuan pengembalian
+	RefundStatusPending        RefundStatus = "pending"         // Menunggu persetujuan admin/finance
+	RefundStatusProcessing     RefundStatus = "processing"      // Disetujui, refund sedang dikirim ke payment gateway
+	RefundStatusApproved       RefundStatus = "approved"        // Disetujui, menunggu transfer manual
+	RefundStatusCompleted      RefundStatus = "completed"       // Dana sudah dikembalikan
+)
+
+type RefundDestination string
+
+const (
+	RefundToBalance  RefundDestination = "balance"  // Saldo Jokiin klien (langsung)
+	RefundToOriginal RefundDestination = "original" // Metode pembayaran asal (via payment gateway)
+	RefundToBank     RefundDestination = "bank"     // Rekening bank / e-wallet klien
+)
+
+// RefundRequest returns money held in escrow for an order to the client.
+// The refunded amount leaves escrow when the request is opened and waits in the
+// refunds-payable ledger account until it is paid out to the chosen destination.
+type RefundRequest struct {
+	ID            uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	JobOfferID    uuid.UUID  `gorm:"type:uuid;index;not null" json:"job_offer_id"`
+	TransactionID *uuid.UUID `gorm:"type:char(36);index" json:"transaction_id,omitempty"` // Original payment
+	ClientID      uuid.UUID  `gorm:"type:uuid;index;not null" json:"client_id"`
+
+	Amount      int64  `gorm:"not null" json:"amount"`                 // Part of the order price refunded
+	FeeRefund   int64  `gorm:"not null;default:0" json:"fee_refund"`   // Payment fee paid by the client that is also refunded
+	TransferFee int64  `gorm:"not null;default:0" json:"transfer_fee"` // Deducted for bank transfers
+	Reason      string `gorm:"type:text" json:"reason"`
+
+	Destination         RefundDestination `gorm:"type:varchar(20)" json:"destination"`
+	DestinationType     PayoutAccountType `gorm:"type:varchar(20)" json:"destination_type,omitempty"`
+	ProviderCode        string            `gorm:"type:varchar(30)" json:"provider_code,omitempty"`
+	AccountHolder       string            `gorm:"type:varchar(120)" json:"account_holder,omitempty"`
+	AccountNumberEnc    string            `gorm:"type:text" json:"-"`
+	AccountNumberMasked string            `gorm:"type:varchar(50)" json:"account_number_masked,omitempty"`
+
+	Status RefundStatus `gorm:"type:varchar(20);not null;default:'awaiting_client';index" json:"status"`
+
+	AdminNote       string     `gorm:"type:text" json:"admin_note"`
+	RefundReference string     `gorm:"type:varchar(100)" json:"refund_reference"` // Gateway refund id or bank transfer reference
+	RequestedBy     *uuid.UUID `gorm:"type:uuid" json:"requested_by,omitempty"`
+	ProcessedBy     *uuid.UUID `gorm:"type:uuid" json:"processed_by,omitempty"`
+	ProcessedAt     *time.Time `json:"processed_at,omitempty"`
+	CompletedAt     *time.Time `json:"completed_at,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Relations
+	JobOffer *JobOffer `gorm:"foreignKey:JobOfferID" json:"job_offer,omitempty"`
+	Client   *User     `gorm:"foreignKey:ClientID" json:"client,omitempty"`
+}
+
+// PayoutAmount is what actually reaches the client
+func (r *RefundRequest) PayoutAmount() int64 {
+	return r.Amount + r.FeeRefund - r.TransferFee
+}
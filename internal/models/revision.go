@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type RevisionStatus string
+
+const (
+	RevisionStatusOpen     RevisionStatus = "open"     // Freelancer sedang mengerjakan revisi
+	RevisionStatusResolved RevisionStatus = "resolved" // Sudah dijawab dengan pengiriman baru
+)
+
+// Revision is one revision request of a client on a delivered order
+type Revision struct {
+	ID          uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	JobOfferID  uuid.UUID      `gorm:"type:uuid;index;not null" json:"job_offer_id"`
+	MilestoneID *uuid.UUID     `gorm:"type:uuid;index" json:"milestone_id,omitempty"` // Set on milestone orders
+	Number      int            `gorm:"not null" json:"number"`                        // 1-based, per order (or milestone)
+	RequestedBy uuid.UUID      `gorm:"type:uuid;not null" json:"requested_by"`
+	Reason      string         `gorm:"type:text;not null" json:"reason"`
+	Attachments string         `gorm:"type:text" json:"attachments"` // JSON array of file URLs
+	Status      RevisionStatus `gorm:"type:varchar(20);not null;default:'open';index" json:"status"`
+	RequestedAt time.Time      `json:"requested_at"`
+
+	// The delivery that answered the revision
+	DeliveryID    *uuid.UUID `gorm:"type:uuid" json:"delivery_id,omitempty"`
+	ResolvedAt    *time.Time `json:"resolved_at,omitempty"`
+	DeliveryLink  string     `gorm:"type:text" json:"delivery_link,omitempty"`
+	DeliveryFiles string     `gorm:"type:text" json:"delivery_files,omitempty"`
+}
+
+type RevisionPurchaseStatus string
+
+const (
+	RevisionPurchaseOffered  RevisionPurchaseStatus = "offered"  // Menunggu persetujuan pembeli
+	RevisionPurchaseAccepted RevisionPurchaseStatus = "accepted" // Dibayar dari saldo, revisi ditambahkan
+	RevisionPurchaseDeclined RevisionPurchaseStatus = "declined"
+)
+
+// RevisionPurchase is a set of extra revisions the freelancer grants beyond the package limit.
+// Paid ones are charged to the client's balance and held in escrow with the order.
+type RevisionPurchase struct {
+	ID          uuid.UUID              `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	JobOfferID  uuid.UUID              `gorm:"type:uuid;index;not null" json:"job_offer_id"`
+	Count       int                    `gorm:"not null" json:"count"`
+	Price       int64                  `gorm:"not null;default:0" json:"price"`        // 0 = free
+	PlatformFee int64                  `gorm:"not null;default:0" json:"platform_fee"` // Commission on Price, at the order's rate
+	Note        string                 `gorm:"type:text" json:"note"`
+	Status      RevisionPurchaseStatus `gorm:"type:varchar(20);not null;default:'offered';index" json:"status"`
+	OfferedBy   uuid.UUID              `gorm:"type:uuid;not null" json:"offered_by"`
+	RespondedAt *time.Time             `json:"responded_at,omitempty"`
+	CreatedAt   time.Time              `json:"created_at"`
+	UpdatedAt   time.Time              `json:"updated_at"`
+}
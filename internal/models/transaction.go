@@ -15,27 +15,53 @@ const (
 	TransactionStatusFailed  TransactionStatus = "FAILED"
 	TransactionStatusExpired TransactionStatus = "EXPIRED"
 	TransactionStatusRefund  TransactionStatus = "REFUND"
+	// Local only: an UNPAID attempt replaced by a newer payment attempt for the same offer
+	TransactionStatusSuperseded TransactionStatus = "SUPERSEDED"
 )
 
+// TransactionPurpose tells what a gateway payment is for
+type TransactionPurpose string
+
+const (
+	TransactionPurposeOrder TransactionPurpose = "order" // Pembayaran pesanan (INV-...)
+	TransactionPurposeTopUp TransactionPurpose = "topup" // Isi saldo klien (TOP-...)
+)
+
+// TopUpRefPrefix starts the merchant ref of every wallet top-up
+const TopUpRefPrefix = "TOP-"
+
+// PaymentMethodBalance marks a transaction (or part of it) settled from the client's wallet balance
+const PaymentMethodBalance = "BALANCE"
+
 type Transaction struct {
-	ID                uuid.UUID         `gorm:"type:char(36);primaryKey" json:"id"`
-	JobOfferID        uuid.UUID         `gorm:"type:char(36);index" json:"job_offer_id"`
-	JobOffer          JobOffer          `gorm:"foreignKey:JobOfferID" json:"job_offer"`
-	Reference         string            `gorm:"type:varchar(50);uniqueIndex" json:"reference"`    // Tripay Reference
-	MerchantRef       string            `gorm:"type:varchar(50);uniqueIndex" json:"merchant_ref"` // INV-{OrderCode}
-	PaymentMethod     string            `gorm:"type:varchar(50)" json:"payment_method"`
-	PaymentMethodCode string            `gorm:"type:varchar(50)" json:"payment_method_code"`
-	TotalAmount       int64             `json:"total_amount"`
-	FeeMerchant       int64             `json:"fee_merchant"`
-	FeeCustomer       int64             `json:"fee_customer"`
-	TotalFee          int64             `json:"total_fee"`
-	AmountReceived    int64             `json:"amount_received"`
-	CheckoutURL       string            `gorm:"type:text" json:"checkout_url"`
-	Status            TransactionStatus `gorm:"type:varchar(20);default:'UNPAID'" json:"status"`
-	PaidAt            *time.Time        `json:"paid_at"`
-	Note              string            `gorm:"type:text" json:"note"`
-	CreatedAt         time.Time         `json:"created_at"`
-	UpdatedAt         time.Time         `json:"updated_at"`
+	ID                uuid.UUID          `gorm:"type:char(36);primaryKey" json:"id"`
+	Purpose           TransactionPurpose `gorm:"type:varchar(20);not null;default:'order';index" json:"purpose"`
+	JobOfferID        *uuid.UUID         `gorm:"type:char(36);index" json:"job_offer_id"` // Set for order payments
+	JobOffer          JobOffer           `gorm:"foreignKey:JobOfferID" json:"job_offer"`
+	UserID            *uuid.UUID         `gorm:"type:uuid;index" json:"user_id,omitempty"`         // Client topping up (top-ups only)
+	Reference         string             `gorm:"type:varchar(50);uniqueIndex" json:"reference"`    // Tripay Reference
+	MerchantRef       string             `gorm:"type:varchar(50);uniqueIndex" json:"merchant_ref"` // INV-{OrderCode}-{n} or TOP-{code}
+	PaymentMethod     string             `gorm:"type:varchar(50)" json:"payment_method"`
+	PaymentMethodCode string             `gorm:"type:varchar(50)" json:"payment_method_code"`
+	TotalAmount       int64              `json:"total_amount"`
+	FeeMerchant       int64              `json:"fee_merchant"`
+	FeeCustomer       int64              `json:"fee_customer"`
+	TotalFee          int64              `json:"total_fee"`
+	AmountReceived    int64              `json:"amount_received"`
+	BalanceAmount     int64              `gorm:"not null;default:0" json:"balance_amount"`          // Portion of the offer price paid from client wallet
+	ReturnedToBalance bool               `gorm:"not null;default:false" json:"returned_to_balance"` // Paid, but credited to the client's wallet instead of escrow
+	RefundShortfall   int64              `gorm:"not null;default:0" json:"refund_shortfall"`        // Top-up refunded by the gateway after the client spent it: part not taken back from the balance
+	VoucherID         *uuid.UUID         `gorm:"type:uuid" json:"voucher_id,omitempty"`
+	VoucherCode       string             `gorm:"type:varchar(40)" json:"voucher_code,omitempty"`
+	DiscountAmount    int64              `gorm:"not null;default:0" json:"discount_amount"` // Voucher discount off the offer price
+	DiscountFundedBy  VoucherFunding     `gorm:"type:varchar(20)" json:"discount_funded_by,omitempty"`
+	CheckoutURL       string             `gorm:"type:text" json:"checkout_url"`
+	Status            TransactionStatus  `gorm:"type:varchar(20);default:'UNPAID'" json:"status"`
+	PaidAt            *time.Time         `json:"paid_at"`
+	ExpiredAt         *time.Time         `json:"expired_at"` // Payment deadline at the gateway
+	Note              string             `gorm:"type:text" json:"note"`
+	CreatedAt         time.Time          `json:"created_at"`
+	UpdatedAt         time.Time          `json:"updated_at"`
 }
 
 func (t *Transaction) BeforeCreate(tx *gorm.DB) (err error) {
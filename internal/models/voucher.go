@@ -0,0 +1,88 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type VoucherFunding string
+
+const (
+	VoucherFundedByPlatform   VoucherFunding = "platform"   // Diskon ditanggung platform (mengurangi komisi)
+	VoucherFundedByFreelancer VoucherFunding = "freelancer" // Diskon ditanggung freelancer (mengurangi pendapatan)
+)
+
+type VoucherType string
+
+const (
+	VoucherTypePercent VoucherType = "percent"
+	VoucherTypeFixed   VoucherType = "fixed"
+)
+
+// Voucher is a discount code applied to an order at payment time
+type Voucher struct {
+	ID          uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	Code        string    `gorm:"type:varchar(40);uniqueIndex;not null" json:"code"` // Stored upper case
+	Description string    `gorm:"type:text" json:"description"`
+
+	FundedBy     VoucherFunding `gorm:"type:varchar(20);not null" json:"funded_by"`
+	FreelancerID *uuid.UUID     `gorm:"type:uuid;index" json:"freelancer_id,omitempty"` // Freelancer-funded: only valid on this freelancer's orders
+
+	Type        VoucherType `gorm:"type:varchar(20);not null" json:"type"`
+	Value       int64       `gorm:"not null" json:"value"`                  // Percent (1-100) or fixed amount
+	MaxDiscount int64       `gorm:"not null;default:0" json:"max_discount"` // Cap for percent vouchers, 0 = no cap
+	MinSpend    int64       `gorm:"not null;default:0" json:"min_spend"`
+
+	UsageLimit   int `gorm:"not null;default:0" json:"usage_limit"`    // Total redemptions, 0 = unlimited
+	PerUserLimit int `gorm:"not null;default:1" json:"per_user_limit"` // Redemptions per client, 0 = unlimited
+
+	// Scope, empty = any order
+	Category  string `gorm:"type:varchar(100)" json:"category"`
+	ProductID *uint  `json:"product_id,omitempty"`
+
+	StartsAt  *time.Time `json:"starts_at,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	IsActive  bool       `gorm:"not null;default:true" json:"is_active"`
+
+	CreatedBy *uuid.UUID `gorm:"type:uuid" json:"created_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// DiscountFor computes the discount on price, never more than the price itself
+func (v *Voucher) DiscountFor(price int64) int64 {
+	discount := v.Value
+	if v.Type == VoucherTypePercent {
+		discount = price * v.Value / 100
+		if v.MaxDiscount > 0 && discount > v.MaxDiscount {
+			discount = v.MaxDiscount
+		}
+	}
+	if discount > price {
+		discount = price
+	}
+	return discount
+}
+
+type VoucherRedemptionStatus string
+
+const (
+	VoucherRedemptionReserved VoucherRedemptionStatus = "reserved" // Payment attempt still open
+	VoucherRedemptionUsed     VoucherRedemptionStatus = "used"     // Order paid
+	VoucherRedemptionReleased VoucherRedemptionStatus = "released" // Attempt expired/failed/superseded
+)
+
+// VoucherRedemption ties a voucher to one payment attempt. Reserved and used
+// redemptions count towards the usage limits.
+type VoucherRedemption struct {
+	ID            uuid.UUID               `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	VoucherID     uuid.UUID               `gorm:"type:uuid;index;not null" json:"voucher_id"`
+	UserID        uuid.UUID               `gorm:"type:uuid;index;not null" json:"user_id"`
+	JobOfferID    uuid.UUID               `gorm:"type:uuid;index;not null" json:"job_offer_id"`
+	TransactionID uuid.UUID               `gorm:"type:uuid;uniqueIndex;not null" json:"transaction_id"`
+	Discount      int64                   `gorm:"not null" json:"discount"`
+	Status        VoucherRedemptionStatus `gorm:"type:varchar(20);not null;index" json:"status"`
+	CreatedAt     time.Time               `json:"created_at"`
+	UpdatedAt     time.Time               `json:"updated_at"`
+}
@@ -12,11 +12,22 @@ const (
 	WalletTrxCredit WalletTrxType = "credit" // Pendapatan masuk
 	WalletTrxDebit  WalletTrxType = "debit"  // Penarikan/Pengurangan
 	WalletTrxRefund WalletTrxType = "refund" // Pengembalian dana
+	WalletTrxTopUp  WalletTrxType = "topup"  // Isi saldo via payment gateway
+)
+
+// WalletKind tells which balance a ledger row belongs to, since one user can hold
+// both a client balance (User.Balance) and a freelancer balance (FreelancerProfile.Balance).
+type WalletKind string
+
+const (
+	WalletClient     WalletKind = "client"
+	WalletFreelancer WalletKind = "freelancer"
 )
 
 type WalletTransaction struct {
 	ID          uuid.UUID     `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
 	UserID      uuid.UUID     `gorm:"type:uuid;index;not null" json:"user_id"`
+	Wallet      WalletKind    `gorm:"type:varchar(20);not null;default:'freelancer';index" json:"wallet"`
 	Amount      int64         `gorm:"not null" json:"amount"`
 	Type        WalletTrxType `gorm:"type:varchar(20);not null" json:"type"`
 	Description string        `gorm:"type:text" json:"description"`
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type WithdrawalStatus string
+
+const (
+	WithdrawalStatusPending     WithdrawalStatus = "pending"     // Menunggu persetujuan admin
+	WithdrawalStatusApproved    WithdrawalStatus = "approved"    // Disetujui, menunggu transfer
+	WithdrawalStatusTransferred WithdrawalStatus = "transferred" // Dana sudah ditransfer
+	WithdrawalStatusRejected    WithdrawalStatus = "rejected"    // Ditolak admin, dana dikembalikan
+	WithdrawalStatusFailed      WithdrawalStatus = "failed"      // Transfer gagal, dana dikembalikan
+	WithdrawalStatusCancelled   WithdrawalStatus = "cancelled"   // Dibatalkan freelancer, dana dikembalikan
+)
+
+// Withdrawal is a freelancer's request to cash out part of their wallet balance.
+// The amount is debited (held) from FreelancerProfile.Balance when the request is made
+// and credited back if the request is rejected, cancelled or the transfer fails.
+type Withdrawal struct {
+	ID     uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID uuid.UUID `gorm:"type:uuid;index;not null" json:"user_id"`
+	Amount int64     `gorm:"not null" json:"amount"`
+
+	// Tujuan transfer (snapshot of the PayoutAccount at request time)
+	PayoutAccountID     *uuid.UUID        `gorm:"type:uuid;index" json:"payout_account_id,omitempty"`
+	DestinationType     PayoutAccountType `gorm:"type:varchar(20)" json:"destination_type"`
+	ProviderCode        string            `gorm:"type:varchar(30)" json:"provider_code"`
+	AccountHolder       string            `gorm:"type:varchar(120)" json:"account_holder"`
+	AccountNumberEnc    string            `gorm:"type:text" json:"-"`
+	AccountNumberMasked string            `gorm:"type:varchar(50)" json:"account_number_masked"`
+
+	Status WithdrawalStatus `gorm:"type:varchar(20);not null;default:'pending';index" json:"status"`
+
+	AdminNote         string     `gorm:"type:text" json:"admin_note"`
+	FailureReason     string     `gorm:"type:text" json:"failure_reason"`
+	TransferReference string     `gorm:"type:varchar(100)" json:"transfer_reference"`
+	ProcessedBy       *uuid.UUID `gorm:"type:uuid" json:"processed_by,omitempty"`
+	ProcessedAt       *time.Time `json:"processed_at,omitempty"`
+	TransferredAt     *time.Time `json:"transferred_at,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Relation
+	User *User `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+// IsOpen reports whether the withdrawal still holds funds that may be returned.
+func (w *Withdrawal) IsOpen() bool {
+	return w.Status == WithdrawalStatusPending || w.Status == WithdrawalStatusApproved
+}
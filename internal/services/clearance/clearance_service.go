@@ -0,0 +1,179 @@
+package clearance
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/models"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/ledger"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/wallet"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrAlreadyCleared is returned by Reverse when part of the earnings already reached the
+// freelancer's withdrawable balance
+var ErrAlreadyCleared = errors.New("earnings of this order have already cleared")
+
+// ClearanceService keeps completed-order earnings in the freelancer's pending balance for a
+// clearing period, leaving room to claw them back on a dispute or chargeback, and then
+// moves them to the withdrawable balance.
+type ClearanceService struct {
+	DB     *gorm.DB
+	Wallet *wallet.WalletService
+	Ledger *ledger.LedgerService
+	Period time.Duration
+}
+
+func NewClearanceService(db *gorm.DB, walletService *wallet.WalletService, ledgerService *ledger.LedgerService, period time.Duration) *ClearanceService {
+	return &ClearanceService{DB: db, Wallet: walletService, Ledger: ledgerService, Period: period}
+}
+
+// Hold puts the net earnings of a completed order in the freelancer's pending balance.
+// The caller posts the escrow release first, in the same DB transaction.
+func (s *ClearanceService) Hold(tx *gorm.DB, offer *models.JobOffer, amount int64, description string) (*models.EarningClearance, error) {
+	if amount <= 0 {
+		// Nothing to clear (e.g. fully refunded before completion)
+		return nil, nil
+	}
+
+	c := models.EarningClearance{
+		UserID:      offer.FreelancerID,
+		JobOfferID:  offer.ID,
+		OrderCode:   offer.OrderCode,
+		Amount:      amount,
+		Description: description,
+		Status:      models.ClearancePending,
+		AvailableAt: time.Now().Add(s.Period),
+	}
+	if err := tx.Create(&c).Error; err != nil {
+		return nil, err
+	}
+	if err := s.Wallet.HoldFreelancerPending(tx, c.UserID, amount); err != nil {
+		return nil, err
+	}
+
+	// Without a clearing period the earnings are available right away
+	if s.Period <= 0 {
+		if err := s.release(tx, &c); err != nil {
+			return nil, err
+		}
+	}
+	return &c, nil
+}
+
+// ReleaseDue moves every clearance whose period is over to the withdrawable balance
+func (s *ClearanceService) ReleaseDue() (int, error) {
+	var due []models.EarningClearance
+	if err := s.DB.Where("status = ? AND available_at <= ?", models.ClearancePending, time.Now()).
+		Order("available_at ASC").Find(&due).Error; err != nil {
+		return 0, err
+	}
+
+	released := 0
+	for _, c := range due {
+		err := s.DB.Transaction(func(tx *gorm.DB) error {
+			var current models.EarningClearance
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, "id = ?", c.ID).Error; err != nil {
+				return err
+			}
+			// Idempotency: reversed or released in the meantime
+			if current.Status != models.ClearancePending {
+				return nil
+			}
+			if err := s.release(tx, &current); err != nil {
+				return err
+			}
+			released++
+			return nil
+		})
+		if err != nil {
+			log.Printf("[ClearanceWorker] Failed to release earnings of order %s: %v", c.OrderCode, err)
+		}
+	}
+	return released, nil
+}
+
+func (s *ClearanceService) release(tx *gorm.DB, c *models.EarningClearance) error {
+	if err := s.Wallet.ClearFreelancerPending(tx, c.UserID, c.Amount, c.JobOfferID, c.Description); err != nil {
+		return err
+	}
+	if err := s.Ledger.RecordEarningCleared(tx, c); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	c.Status = models.ClearanceReleased
+	c.ReleasedAt = &now
+	return tx.Save(c).Error
+}
+
+// Reverse takes back every escrow release of an order whose earnings have not cleared yet
+// (e.g. the payment was charged back): the freelancer's pending earnings and the platform fee
+// go back to escrow and the clearances are marked reversed. The offer's released totals are
+// reset; the caller saves the offer and must hold a lock on its row. Nothing is changed when
+// part of the earnings already cleared (ErrAlreadyCleared).
+func (s *ClearanceService) Reverse(tx *gorm.DB, offer *models.JobOffer, description string) error {
+	if offer.ReleasedAmount == 0 && offer.ReleasedNet == 0 && offer.ReleasedFee == 0 {
+		return nil
+	}
+
+	var clearances []models.EarningClearance
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("job_offer_id = ? AND status <> ?", offer.ID, models.ClearanceReversed).
+		Find(&clearances).Error; err != nil {
+		return err
+	}
+
+	var pending int64
+	for _, c := range clearances {
+		if c.Status != models.ClearancePending {
+			return ErrAlreadyCleared
+		}
+		pending += c.Amount
+	}
+	if pending != offer.ReleasedNet {
+		return fmt.Errorf("pending earnings %d of order %s do not match the released net %d", pending, offer.OrderCode, offer.ReleasedNet)
+	}
+
+	if pending > 0 {
+		if err := s.Wallet.ReverseFreelancerPending(tx, offer.FreelancerID, pending); err != nil {
+			return err
+		}
+	}
+	share := models.ReleaseShare{Escrow: offer.ReleasedAmount, Net: offer.ReleasedNet, Fee: offer.ReleasedFee}
+	if err := s.Ledger.RecordEscrowReleaseReversal(tx, offer, share, description); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for i := range clearances {
+		clearances[i].Status = models.ClearanceReversed
+		clearances[i].ReversedAt = &now
+		if err := tx.Save(&clearances[i]).Error; err != nil {
+			return err
+		}
+	}
+
+	offer.ReleasedAmount, offer.ReleasedNet, offer.ReleasedFee = 0, 0, 0
+	return nil
+}
+
+// StartWorker periodically releases earnings whose clearing period is over
+func (s *ClearanceService) StartWorker(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			n, err := s.ReleaseDue()
+			if err != nil {
+				log.Printf("[ClearanceWorker] Error fetching due clearances: %v", err)
+				continue
+			}
+			if n > 0 {
+				log.Printf("[ClearanceWorker] Released %d cleared earnings", n)
+			}
+		}
+	}()
+}
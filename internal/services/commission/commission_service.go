@@ -0,0 +1,148 @@
+package commission
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DefaultRateBps is used when no commission rule matches (the old hard-coded 10%)
+const DefaultRateBps = 1000
+
+type CommissionService struct {
+	DB             *gorm.DB
+	DefaultRateBps int
+}
+
+func NewCommissionService(db *gorm.DB, defaultRateBps int) *CommissionService {
+	if defaultRateBps < 0 {
+		defaultRateBps = DefaultRateBps
+	}
+	return &CommissionService{DB: db, DefaultRateBps: defaultRateBps}
+}
+
+// Criteria describes the offer a commission is resolved for
+type Criteria struct {
+	FreelancerID    uuid.UUID
+	FreelancerLevel models.FreelancerLevel
+	Category        string
+	Price           int64
+	At              time.Time
+}
+
+// Quote is the resolved commission, ready to be snapshotted on an offer
+type Quote struct {
+	Rule        *models.CommissionRule `json:"rule,omitempty"` // nil means the default rate was used
+	RateBps     int                    `json:"rate_bps"`
+	FlatFee     int64                  `json:"flat_fee"`
+	PlatformFee int64                  `json:"platform_fee"`
+	NetAmount   int64                  `json:"net_amount"`
+}
+
+// Fee computes the platform fee for a price, never more than the price itself
+func Fee(price int64, rateBps int, flatFee int64) int64 {
+	fee := price*int64(rateBps)/10000 + flatFee
+	if fee > price {
+		fee = price
+	}
+	if fee < 0 {
+		fee = 0
+	}
+	return fee
+}
+
+// CriteriaFor loads the freelancer level and product category used to match rules for an offer
+func (s *CommissionService) CriteriaFor(freelancerID uuid.UUID, productID *uint, price int64) (Criteria, error) {
+	cr := Criteria{FreelancerID: freelancerID, Price: price, At: time.Now()}
+
+	var profile models.FreelancerProfile
+	err := s.DB.Select("level").Where("user_id = ?", freelancerID).First(&profile).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return cr, err
+	}
+	cr.FreelancerLevel = profile.Level
+
+	if productID != nil {
+		var product models.Product
+		err := s.DB.Select("category").Where("id = ?", *productID).First(&product).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return cr, err
+		}
+		cr.Category = product.Category
+	}
+	return cr, nil
+}
+
+// Resolve picks the matching rule: a per-freelancer override first, then the highest
+// priority, then the most specific rule, then the newest one.
+func (s *CommissionService) Resolve(cr Criteria) (*Quote, error) {
+	var rules []models.CommissionRule
+	err := s.DB.
+		Where("is_active").
+		Where("freelancer_id IS NULL OR freelancer_id = ?", cr.FreelancerID).
+		Where("category = '' OR category IS NULL OR LOWER(category) = LOWER(?)", cr.Category).
+		Where("freelancer_level = '' OR freelancer_level IS NULL OR freelancer_level = ?", cr.FreelancerLevel).
+		Where("min_price <= ? AND (max_price = 0 OR max_price >= ?)", cr.Price, cr.Price).
+		Where("(starts_at IS NULL OR starts_at <= ?) AND (ends_at IS NULL OR ends_at > ?)", cr.At, cr.At).
+		Find(&rules).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rules) == 0 {
+		return &Quote{
+			RateBps:     s.DefaultRateBps,
+			PlatformFee: Fee(cr.Price, s.DefaultRateBps, 0),
+			NetAmount:   cr.Price - Fee(cr.Price, s.DefaultRateBps, 0),
+		}, nil
+	}
+
+	sort.SliceStable(rules, func(i, j int) bool {
+		a, b := &rules[i], &rules[j]
+		if (a.FreelancerID != nil) != (b.FreelancerID != nil) {
+			return a.FreelancerID != nil
+		}
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		if a.Specificity() != b.Specificity() {
+			return a.Specificity() > b.Specificity()
+		}
+		return a.CreatedAt.After(b.CreatedAt)
+	})
+
+	rule := rules[0]
+	fee := Fee(cr.Price, rule.RateBps, rule.FlatFee)
+	return &Quote{
+		Rule:        &rule,
+		RateBps:     rule.RateBps,
+		FlatFee:     rule.FlatFee,
+		PlatformFee: fee,
+		NetAmount:   cr.Price - fee,
+	}, nil
+}
+
+// QuoteOffer resolves the commission for an offer's current freelancer, product and price
+func (s *CommissionService) QuoteOffer(offer *models.JobOffer) (*Quote, error) {
+	cr, err := s.CriteriaFor(offer.FreelancerID, offer.ProductID, offer.Price)
+	if err != nil {
+		return nil, err
+	}
+	return s.Resolve(cr)
+}
+
+// Apply snapshots a quote onto an offer
+func (q *Quote) Apply(offer *models.JobOffer) {
+	offer.PlatformFee = q.PlatformFee
+	offer.NetAmount = q.NetAmount
+	offer.CommissionRateBps = q.RateBps
+	offer.CommissionFlatFee = q.FlatFee
+	offer.CommissionRuleID = nil
+	if q.Rule != nil {
+		offer.CommissionRuleID = &q.Rule.ID
+	}
+}
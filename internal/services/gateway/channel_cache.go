@@ -0,0 +1,88 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ChannelCache wraps a PaymentGateway and caches its channel list in Redis for TTL.
+// The last list fetched successfully is kept (in Redis without expiry and in memory),
+// so checkout keeps working with the previous fees when the gateway is unreachable.
+type ChannelCache struct {
+	PaymentGateway
+	RDB *redis.Client
+	TTL time.Duration
+
+	mu       sync.Mutex
+	lastGood []PaymentChannel
+}
+
+func NewChannelCache(inner PaymentGateway, rdb *redis.Client, ttl time.Duration) *ChannelCache {
+	return &ChannelCache{PaymentGateway: inner, RDB: rdb, TTL: ttl}
+}
+
+func (c *ChannelCache) cacheKey() string {
+	return "payment_channels:" + c.Name()
+}
+
+func (c *ChannelCache) fallbackKey() string {
+	return "payment_channels:" + c.Name() + ":last_good"
+}
+
+func (c *ChannelCache) GetPaymentChannels() ([]PaymentChannel, error) {
+	ctx := context.Background()
+
+	if channels, ok := c.read(ctx, c.cacheKey()); ok {
+		return channels, nil
+	}
+
+	channels, err := c.PaymentGateway.GetPaymentChannels()
+	if err != nil {
+		if fallback, ok := c.read(ctx, c.fallbackKey()); ok {
+			log.Printf("[ChannelCache] %s unreachable (%v), using last known channel list", c.Name(), err)
+			return fallback, nil
+		}
+		c.mu.Lock()
+		fallback := c.lastGood
+		c.mu.Unlock()
+		if fallback != nil {
+			log.Printf("[ChannelCache] %s unreachable (%v), using in-memory channel list", c.Name(), err)
+			return fallback, nil
+		}
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.lastGood = channels
+	c.mu.Unlock()
+
+	if data, err := json.Marshal(channels); err == nil {
+		if err := c.RDB.Set(ctx, c.cacheKey(), data, c.TTL).Err(); err != nil {
+			log.Printf("[ChannelCache] Failed to cache channel list: %v", err)
+		}
+		c.RDB.Set(ctx, c.fallbackKey(), data, 0)
+	}
+	return channels, nil
+}
+
+// Invalidate drops the cached list so the next call fetches it from the gateway
+func (c *ChannelCache) Invalidate() error {
+	return c.RDB.Del(context.Background(), c.cacheKey()).Err()
+}
+
+func (c *ChannelCache) read(ctx context.Context, key string) ([]PaymentChannel, bool) {
+	data, err := c.RDB.Get(ctx, key).Bytes()
+	if err != nil {
+		return nil, false
+	}
+	var channels []PaymentChannel
+	if err := json.Unmarshal(data, &channels); err != nil {
+		return nil, false
+	}
+	return channels, true
+}
@@ -0,0 +1,99 @@
+// Package gateway defines the payment gateway abstraction used by the payment handler.
+// Tripay implements it for real payments, Simulator for offline development.
+package gateway
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"time"
+)
+
+type PaymentGateway interface {
+	// Name identifies the gateway (e.g. "tripay", "simulator")
+	Name() string
+	GetPaymentChannels() ([]PaymentChannel, error)
+	CreateTransaction(req CreateTransactionRequest) (*CreatedTransaction, error)
+	// GetTransactionStatus fetches the current state of a transaction, shaped like a callback
+	GetTransactionStatus(reference string) (*CallbackPayload, error)
+	// ValidateCallback verifies the signature sent with a callback body
+	ValidateCallback(signature string, body []byte) bool
+}
+
+// Refunder is implemented by gateways that can refund (part of) a paid transaction
+// back to the original payment method through their API. Calls with the same
+// idempotencyKey refund only once and return the same refund reference.
+type Refunder interface {
+	Refund(reference string, amount int64, reason, idempotencyKey string) (refundReference string, err error)
+}
+
+type ChannelFee struct {
+	Flat    float64 `json:"flat"`
+	Percent float64 `json:"percent"`
+}
+
+type PaymentChannel struct {
+	Group   string     `json:"group"`
+	Code    string     `json:"code"`
+	Name    string     `json:"name"`
+	Type    string     `json:"type"`
+	Fee     ChannelFee `json:"total_fee"`
+	IconURL string     `json:"icon_url"`
+	Active  bool       `json:"active"`
+}
+
+// CustomerFee computes the fee charged to the customer using the channel's flat + percent rule
+func (ch PaymentChannel) CustomerFee(amount int64) int64 {
+	totalFee := ch.Fee.Flat + (float64(amount) * ch.Fee.Percent / 100)
+	return int64(math.Ceil(totalFee))
+}
+
+type CreateTransactionRequest struct {
+	MerchantRef   string
+	Method        string
+	Amount        int64 // Total charged to the customer (fee included)
+	CustomerFee   int64 // Fee part of Amount
+	CustomerName  string
+	CustomerEmail string
+	CustomerPhone string
+	ItemName      string
+	ReturnURL     string
+	ExpiredAt     time.Time // Payment deadline
+}
+
+type CreatedTransaction struct {
+	Reference   string
+	MerchantRef string
+	CheckoutURL string
+	Amount      int64
+}
+
+// CallbackPayload is the payment notification body (Tripay format, also used by the simulator)
+type CallbackPayload struct {
+	Reference         string `json:"reference"`
+	MerchantRef       string `json:"merchant_ref"`
+	PaymentMethod     string `json:"payment_method"`
+	PaymentMethodCode string `json:"payment_method_code"`
+	TotalAmount       int64  `json:"total_amount"`
+	FeeMerchant       int64  `json:"fee_merchant"`
+	FeeCustomer       int64  `json:"fee_customer"`
+	TotalFee          int64  `json:"total_fee"`
+	AmountReceived    int64  `json:"amount_received"`
+	IsClosedPayment   int    `json:"is_closed_payment"`
+	Status            string `json:"status"` // UNPAID, PAID, EXPIRED, FAILED, REFUND
+	PaidAt            int64  `json:"paid_at"`
+	Note              string `json:"note"`
+}
+
+// Sign returns the hex HMAC-SHA256 of data, the signature scheme shared by Tripay and the simulator
+func Sign(key string, data []byte) string {
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// VerifySignature compares a signature against the HMAC of data in constant time
+func VerifySignature(key, signature string, data []byte) bool {
+	return hmac.Equal([]byte(Sign(key, data)), []byte(signature))
+}
@@ -0,0 +1,232 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Simulator is an offline PaymentGateway for local development and automated tests.
+// Transactions live in memory, the checkout page is served by the API itself and
+// completing a payment fires a signed callback exactly like Tripay would.
+type Simulator struct {
+	Client      *http.Client
+	Secret      string // HMAC key for callback signatures
+	BaseURL     string // Public base URL of this API, used to build checkout URLs
+	CallbackURL string
+
+	mu           sync.Mutex
+	transactions map[string]*SimulatedTransaction
+	refunds      map[string]string // Idempotency key -> refund reference
+}
+
+var (
+	_ PaymentGateway = (*Simulator)(nil)
+	_ Refunder       = (*Simulator)(nil)
+)
+
+type SimulatedTransaction struct {
+	CreateTransactionRequest
+	Reference   string
+	ChannelName string
+	Status      string
+	Refunded    int64 // Refunded through Refund, the transaction itself stays PAID
+	PaidAt      *time.Time
+	CreatedAt   time.Time
+}
+
+var simulatorChannels = []PaymentChannel{
+	{Group: "Virtual Account", Code: "BRIVA", Name: "BRI Virtual Account (Simulator)", Type: "DIRECT", Fee: ChannelFee{Flat: 4250}, Active: true},
+	{Group: "Virtual Account", Code: "BCAVA", Name: "BCA Virtual Account (Simulator)", Type: "DIRECT", Fee: ChannelFee{Flat: 5500}, Active: true},
+	{Group: "E-Wallet", Code: "QRIS", Name: "QRIS (Simulator)", Type: "DIRECT", Fee: ChannelFee{Flat: 750, Percent: 0.7}, Active: true},
+	{Group: "E-Wallet", Code: "OVO", Name: "OVO (Simulator)", Type: "REDIRECT", Fee: ChannelFee{Percent: 3}, Active: true},
+}
+
+func NewSimulator(baseURL, callbackURL, secret string) *Simulator {
+	return &Simulator{
+		Client:       &http.Client{Timeout: 15 * time.Second},
+		Secret:       secret,
+		BaseURL:      strings.TrimRight(baseURL, "/"),
+		CallbackURL:  callbackURL,
+		transactions: map[string]*SimulatedTransaction{},
+		refunds:      map[string]string{},
+	}
+}
+
+func (s *Simulator) Name() string {
+	return "simulator"
+}
+
+func (s *Simulator) GetPaymentChannels() ([]PaymentChannel, error) {
+	channels := make([]PaymentChannel, len(simulatorChannels))
+	copy(channels, simulatorChannels)
+	return channels, nil
+}
+
+func (s *Simulator) CreateTransaction(req CreateTransactionRequest) (*CreatedTransaction, error) {
+	var channel *PaymentChannel
+	for i := range simulatorChannels {
+		if simulatorChannels[i].Code == req.Method {
+			channel = &simulatorChannels[i]
+			break
+		}
+	}
+	if channel == nil {
+		return nil, fmt.Errorf("simulator: unknown payment method %s", req.Method)
+	}
+
+	trx := &SimulatedTransaction{
+		CreateTransactionRequest: req,
+		Reference:                "SIM-" + strings.ToUpper(strings.ReplaceAll(uuid.NewString(), "-", "")[:12]),
+		ChannelName:              channel.Name,
+		Status:                   "UNPAID",
+		CreatedAt:                time.Now(),
+	}
+
+	s.mu.Lock()
+	s.transactions[trx.Reference] = trx
+	s.mu.Unlock()
+
+	return &CreatedTransaction{
+		Reference:   trx.Reference,
+		MerchantRef: req.MerchantRef,
+		CheckoutURL: s.BaseURL + "/simulator/checkout/" + trx.Reference,
+		Amount:      req.Amount,
+	}, nil
+}
+
+func (s *Simulator) GetTransactionStatus(reference string) (*CallbackPayload, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	trx, ok := s.transactions[reference]
+	if !ok {
+		return nil, fmt.Errorf("simulator: transaction %s not found", reference)
+	}
+	return trx.payload(), nil
+}
+
+func (s *Simulator) ValidateCallback(signature string, body []byte) bool {
+	return VerifySignature(s.Secret, signature, body)
+}
+
+// Refund simulates a (partial) refund to the original payment method
+func (s *Simulator) Refund(reference string, amount int64, reason, idempotencyKey string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if refundRef, ok := s.refunds[idempotencyKey]; ok && idempotencyKey != "" {
+		return refundRef, nil
+	}
+
+	trx, ok := s.transactions[reference]
+	if !ok {
+		return "", fmt.Errorf("simulator: transaction %s not found", reference)
+	}
+	if trx.Status != "PAID" {
+		return "", fmt.Errorf("simulator: transaction %s is %s, not PAID", reference, trx.Status)
+	}
+	if amount <= 0 || trx.Refunded+amount > trx.Amount {
+		return "", fmt.Errorf("simulator: refund of %d exceeds the refundable amount", amount)
+	}
+
+	trx.Refunded += amount
+	refundRef := "SIMRF-" + strings.ToUpper(strings.ReplaceAll(uuid.NewString(), "-", "")[:12])
+	if idempotencyKey != "" {
+		s.refunds[idempotencyKey] = refundRef
+	}
+	return refundRef, nil
+}
+
+// Get returns a copy of a simulated transaction (for the checkout page)
+func (s *Simulator) Get(reference string) (SimulatedTransaction, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	trx, ok := s.transactions[reference]
+	if !ok {
+		return SimulatedTransaction{}, false
+	}
+	return *trx, true
+}
+
+// Complete moves a simulated transaction to status (PAID, EXPIRED, FAILED or REFUND)
+// and delivers the signed callback. Completing again re-sends the callback, which is
+// handy for testing idempotency.
+func (s *Simulator) Complete(reference, status string) error {
+	switch status {
+	case "PAID", "EXPIRED", "FAILED", "REFUND":
+	default:
+		return fmt.Errorf("simulator: unsupported status %s", status)
+	}
+
+	s.mu.Lock()
+	trx, ok := s.transactions[reference]
+	if !ok {
+		s.mu.Unlock()
+		return fmt.Errorf("simulator: transaction %s not found", reference)
+	}
+	trx.Status = status
+	if status == "PAID" && trx.PaidAt == nil {
+		now := time.Now()
+		trx.PaidAt = &now
+	}
+	payload := trx.payload()
+	s.mu.Unlock()
+
+	return s.sendCallback(payload)
+}
+
+func (s *Simulator) sendCallback(payload *CallbackPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", s.CallbackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Callback-Event", "payment_status")
+	req.Header.Set("X-Callback-Signature", Sign(s.Secret, body))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("simulator: callback returned HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// payload builds the Tripay-style callback body. The simulator charges no merchant fee,
+// so the whole customer fee goes to the "gateway" and the rest is received.
+func (t *SimulatedTransaction) payload() *CallbackPayload {
+	p := &CallbackPayload{
+		Reference:         t.Reference,
+		MerchantRef:       t.MerchantRef,
+		PaymentMethod:     t.ChannelName,
+		PaymentMethodCode: t.Method,
+		TotalAmount:       t.Amount,
+		FeeCustomer:       t.CustomerFee,
+		TotalFee:          t.CustomerFee,
+		AmountReceived:    t.Amount - t.CustomerFee,
+		IsClosedPayment:   1,
+		Status:            t.Status,
+		Note:              "Simulated payment",
+	}
+	if t.PaidAt != nil {
+		p.PaidAt = t.PaidAt.Unix()
+	}
+	return p
+}
@@ -0,0 +1,227 @@
+package invoice
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrNotPaid = errors.New("order has not been paid")
+
+type InvoiceService struct {
+	DB *gorm.DB
+}
+
+func NewInvoiceService(db *gorm.DB) *InvoiceService {
+	return &InvoiceService{DB: db}
+}
+
+// nextNumber allocates the next invoice number of the month. It runs inside the payment
+// transaction, so a rolled back payment also gives its number back and there are no gaps.
+func nextNumber(tx *gorm.DB, at time.Time) (string, error) {
+	period := at.Format("2006/01")
+
+	var n int
+	err := tx.Raw(`INSERT INTO invoice_sequences (period, last_number) VALUES (?, 1)
+		ON CONFLICT (period) DO UPDATE SET last_number = invoice_sequences.last_number + 1
+		RETURNING last_number`, period).Scan(&n).Error
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("INV/%s/%04d", period, n), nil
+}
+
+// Issue creates the invoice for a PAID order transaction. Issuing again for the same
+// transaction returns the existing invoice.
+func (s *InvoiceService) Issue(tx *gorm.DB, trx *models.Transaction, offer *models.JobOffer) (*models.Invoice, error) {
+	var existing models.Invoice
+	err := tx.Where("transaction_id = ?", trx.ID).First(&existing).Error
+	if err == nil {
+		return &existing, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var client, freelancer models.User
+	if err := tx.Select("id", "name", "email").First(&client, "id = ?", offer.ClientID).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Select("id", "name").First(&freelancer, "id = ?", offer.FreelancerID).Error; err != nil {
+		return nil, err
+	}
+
+	issuedAt := time.Now()
+	if trx.PaidAt != nil {
+		issuedAt = *trx.PaidAt
+	}
+	number, err := nextNumber(tx, issuedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	description := offer.Title + " (#" + offer.OrderCode + ")"
+	if offer.DeferredAmount > 0 {
+		description += " - milestone pertama"
+	}
+	items := []models.InvoiceItem{{
+		Description: description,
+		Quantity:    1,
+		UnitPrice:   offer.CheckoutAmount(),
+		Amount:      offer.CheckoutAmount(),
+	}}
+	if trx.DiscountAmount > 0 {
+		items = append(items, models.InvoiceItem{
+			Description: "Diskon voucher " + trx.VoucherCode,
+			Quantity:    1,
+			UnitPrice:   -trx.DiscountAmount,
+			Amount:      -trx.DiscountAmount,
+		})
+	}
+	if trx.FeeCustomer > 0 {
+		items = append(items, models.InvoiceItem{
+			Description: "Biaya layanan pembayaran " + trx.PaymentMethod,
+			Quantity:    1,
+			UnitPrice:   trx.FeeCustomer,
+			Amount:      trx.FeeCustomer,
+		})
+	}
+	itemsJSON, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+
+	inv := models.Invoice{
+		ID:               uuid.New(),
+		Number:           number,
+		JobOfferID:       offer.ID,
+		TransactionID:    trx.ID,
+		OrderCode:        offer.OrderCode,
+		ClientID:         offer.ClientID,
+		ClientName:       client.Name,
+		ClientEmail:      client.Email,
+		FreelancerID:     offer.FreelancerID,
+		FreelancerName:   freelancer.Name,
+		Items:            itemsJSON,
+		Subtotal:         offer.CheckoutAmount(),
+		Discount:         trx.DiscountAmount,
+		PaymentFee:       trx.FeeCustomer,
+		Total:            offer.CheckoutAmount() - trx.DiscountAmount + trx.FeeCustomer,
+		BalanceAmount:    trx.BalanceAmount,
+		PaymentMethod:    paymentMethodLabel(trx),
+		PaymentReference: trx.Reference,
+		IssuedAt:         issuedAt,
+	}
+	if err := tx.Create(&inv).Error; err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
+// ForOffer returns the invoice of a paid order, issuing it first for orders paid before
+// invoices existed.
+func (s *InvoiceService) ForOffer(offer *models.JobOffer) (*models.Invoice, error) {
+	var trx models.Transaction
+	err := s.DB.Where("job_offer_id = ? AND status IN ? AND NOT returned_to_balance", offer.ID,
+		[]models.TransactionStatus{models.TransactionStatusPaid, models.TransactionStatusRefund}).
+		Order("paid_at DESC").First(&trx).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotPaid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var inv *models.Invoice
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		inv, err = s.Issue(tx, &trx, offer)
+		return err
+	})
+	return inv, err
+}
+
+// Items decodes the invoice line items
+func Items(inv *models.Invoice) []models.InvoiceItem {
+	var items []models.InvoiceItem
+	_ = json.Unmarshal(inv.Items, &items)
+	return items
+}
+
+func paymentMethodLabel(trx *models.Transaction) string {
+	if trx.PaymentMethod == models.PaymentMethodBalance {
+		return "Saldo Jokiin"
+	}
+	if trx.BalanceAmount > 0 {
+		return "Saldo Jokiin + " + trx.PaymentMethod
+	}
+	return trx.PaymentMethod
+}
+
+// EarningsReceipt shows the freelancer what an order earns after the platform fee
+type EarningsReceipt struct {
+	Number             string    `json:"number"`
+	OrderCode          string    `json:"order_code"`
+	Title              string    `json:"title"`
+	FreelancerName     string    `json:"freelancer_name"`
+	ClientName         string    `json:"client_name"`
+	Price              int64     `json:"price"`
+	ExtrasAmount       int64     `json:"extras_amount"`       // Paid extras such as extra revisions
+	FreelancerDiscount int64     `json:"freelancer_discount"` // Voucher discount funded by the freelancer
+	RefundedAmount     int64     `json:"refunded_amount"`
+	CommissionRateBps  int       `json:"commission_rate_bps"`
+	CommissionFlatFee  int64     `json:"commission_flat_fee"`
+	PlatformFee        int64     `json:"platform_fee"`
+	NetAmount          int64     `json:"net_amount"`
+	Status             string    `json:"status"` // in_escrow, released, cancelled
+	IssuedAt           time.Time `json:"issued_at"`
+}
+
+// Receipt builds the earnings receipt of a paid order (with Client and Freelancer preloaded)
+func Receipt(offer *models.JobOffer) EarningsReceipt {
+	net, fee := offer.EscrowRelease()
+
+	var freelancerName, clientName string
+	if offer.Freelancer != nil {
+		freelancerName = offer.Freelancer.Name
+	}
+	if offer.Client != nil {
+		clientName = offer.Client.Name
+	}
+
+	status := "in_escrow"
+	switch offer.Status {
+	case models.OfferStatusCompleted:
+		status = "released"
+	case models.OfferStatusCancelled:
+		status = "cancelled"
+	}
+
+	var freelancerDiscount int64
+	if offer.DiscountFundedBy == models.VoucherFundedByFreelancer {
+		freelancerDiscount = offer.DiscountAmount
+	}
+
+	return EarningsReceipt{
+		Number:             "RCP/" + offer.OrderCode,
+		OrderCode:          offer.OrderCode,
+		Title:              offer.Title,
+		FreelancerName:     freelancerName,
+		ClientName:         clientName,
+		Price:              offer.Price,
+		ExtrasAmount:       offer.ExtrasAmount,
+		FreelancerDiscount: freelancerDiscount,
+		RefundedAmount:     offer.RefundedAmount,
+		CommissionRateBps:  offer.CommissionRateBps,
+		CommissionFlatFee:  offer.CommissionFlatFee,
+		PlatformFee:        fee,
+		NetAmount:          net,
+		Status:             status,
+		IssuedAt:           time.Now(),
+	}
+}
@@ -0,0 +1,280 @@
+package invoice
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/models"
+)
+
+// A minimal single-page PDF writer (A4, built-in Helvetica fonts), enough for invoices
+// and receipts without pulling in a PDF library.
+
+const (
+	pageWidth  = 595.0
+	pageHeight = 842.0
+	marginX    = 50.0
+)
+
+type pdfPage struct {
+	content bytes.Buffer
+	y       float64 // Current baseline, moving down the page
+}
+
+func newPDFPage() *pdfPage {
+	return &pdfPage{y: pageHeight - 60}
+}
+
+func (p *pdfPage) text(x, y, size float64, bold bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(&p.content, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, y, pdfEscape(s))
+}
+
+// textRight draws s so that it ends at x
+func (p *pdfPage) textRight(x, y, size float64, bold bool, s string) {
+	p.text(x-textWidth(s, size), y, size, bold, s)
+}
+
+func (p *pdfPage) line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(&p.content, "%.2f %.2f m %.2f %.2f l S\n", x1, y1, x2, y2)
+}
+
+func (p *pdfPage) rule() {
+	p.line(marginX, p.y, pageWidth-marginX, p.y)
+	p.y -= 16
+}
+
+// row draws a label on the left and a value aligned on the right
+func (p *pdfPage) row(label, value string, bold bool) {
+	p.text(marginX, p.y, 10, bold, label)
+	p.textRight(pageWidth-marginX, p.y, 10, bold, value)
+	p.y -= 16
+}
+
+// bytes assembles the PDF objects and cross-reference table
+func (p *pdfPage) bytes() []byte {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 4 0 R /F2 5 0 R >> >> /Contents 6 0 R >>", pageWidth, pageHeight),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", p.content.Len(), p.content.String()),
+	}
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return out.Bytes()
+}
+
+// pdfEscape escapes string delimiters and drops characters the WinAnsi fonts can't show
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 32 && r < 127:
+			b.WriteRune(r)
+		case r >= 160 && r <= 255:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// textWidth approximates the Helvetica width of s, exact for the characters used in amounts
+func textWidth(s string, size float64) float64 {
+	var units float64
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			units += 556
+		case r == '.' || r == ',' || r == ' ':
+			units += 278
+		case r == '-':
+			units += 333
+		case r == 'R':
+			units += 722
+		default:
+			units += 556
+		}
+	}
+	return units * size / 1000
+}
+
+// wrap splits s into lines of at most width characters
+func wrap(s string, width int) []string {
+	var lines []string
+	var line string
+	for _, word := range strings.Fields(s) {
+		if line != "" && len(line)+1+len(word) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	if line != "" || len(lines) == 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Rupiah formats an amount as "Rp 1.500.000"
+func Rupiah(n int64) string {
+	sign := ""
+	if n < 0 {
+		sign = "-"
+		n = -n
+	}
+	digits := strconv.FormatInt(n, 10)
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(d)
+	}
+	return sign + "Rp " + b.String()
+}
+
+func (p *pdfPage) header(title, number string) {
+	p.text(marginX, p.y, 20, true, title)
+	p.textRight(pageWidth-marginX, p.y, 12, true, "Jokiin")
+	p.y -= 20
+	p.text(marginX, p.y, 10, false, number)
+	p.y -= 28
+}
+
+// RenderInvoicePDF renders an invoice as a PDF document
+func RenderInvoicePDF(inv *models.Invoice) []byte {
+	p := newPDFPage()
+	p.header("INVOICE", inv.Number)
+
+	p.row("Tanggal", inv.IssuedAt.Format("02 Jan 2006 15:04"), false)
+	p.row("Kode Pesanan", "#"+inv.OrderCode, false)
+	p.row("Status", "LUNAS", true)
+	p.y -= 8
+
+	p.text(marginX, p.y, 10, true, "Ditagihkan kepada")
+	p.text(pageWidth/2, p.y, 10, true, "Penjual")
+	p.y -= 14
+	p.text(marginX, p.y, 10, false, inv.ClientName)
+	p.text(pageWidth/2, p.y, 10, false, inv.FreelancerName)
+	p.y -= 14
+	p.text(marginX, p.y, 10, false, inv.ClientEmail)
+	p.y -= 24
+
+	const (
+		colQty   = 360.0
+		colPrice = 460.0
+	)
+	p.text(marginX, p.y, 10, true, "Deskripsi")
+	p.textRight(colQty, p.y, 10, true, "Qty")
+	p.textRight(colPrice, p.y, 10, true, "Harga")
+	p.textRight(pageWidth-marginX, p.y, 10, true, "Jumlah")
+	p.y -= 8
+	p.rule()
+
+	for _, item := range Items(inv) {
+		lines := wrap(item.Description, 50)
+		p.text(marginX, p.y, 10, false, lines[0])
+		p.textRight(colQty, p.y, 10, false, strconv.Itoa(item.Quantity))
+		p.textRight(colPrice, p.y, 10, false, Rupiah(item.UnitPrice))
+		p.textRight(pageWidth-marginX, p.y, 10, false, Rupiah(item.Amount))
+		p.y -= 14
+		for _, l := range lines[1:] {
+			p.text(marginX, p.y, 10, false, l)
+			p.y -= 14
+		}
+		p.y -= 4
+	}
+	p.rule()
+
+	p.row("Subtotal", Rupiah(inv.Subtotal), false)
+	if inv.Discount > 0 {
+		p.row("Diskon voucher", "-"+Rupiah(inv.Discount), false)
+	}
+	p.row("Biaya pembayaran", Rupiah(inv.PaymentFee), false)
+	p.row("Total", Rupiah(inv.Total), true)
+	p.y -= 8
+	p.row("Metode pembayaran", inv.PaymentMethod, false)
+	if inv.BalanceAmount > 0 {
+		p.row("Dibayar dengan saldo", Rupiah(inv.BalanceAmount), false)
+	}
+	p.row("Referensi pembayaran", inv.PaymentReference, false)
+
+	return p.bytes()
+}
+
+// RenderReceiptPDF renders a freelancer earnings receipt as a PDF document
+func RenderReceiptPDF(r *EarningsReceipt) []byte {
+	p := newPDFPage()
+	p.header("BUKTI PENDAPATAN", r.Number)
+
+	p.row("Tanggal", r.IssuedAt.Format("02 Jan 2006 15:04"), false)
+	p.row("Kode Pesanan", "#"+r.OrderCode, false)
+	p.row("Freelancer", r.FreelancerName, false)
+	p.row("Klien", r.ClientName, false)
+	for _, l := range wrap(r.Title, 80) {
+		p.text(marginX, p.y, 10, false, l)
+		p.y -= 14
+	}
+	p.y -= 8
+	p.rule()
+
+	p.row("Harga pesanan", Rupiah(r.Price), false)
+	if r.ExtrasAmount > 0 {
+		p.row("Tambahan (revisi ekstra)", Rupiah(r.ExtrasAmount), false)
+	}
+	if r.FreelancerDiscount > 0 {
+		p.row("Diskon voucher (ditanggung freelancer)", "-"+Rupiah(r.FreelancerDiscount), false)
+	}
+	if r.RefundedAmount > 0 {
+		p.row("Dikembalikan ke klien", "-"+Rupiah(r.RefundedAmount), false)
+	}
+	p.row("Komisi platform ("+commissionLabel(r)+")", "-"+Rupiah(r.PlatformFee), false)
+	p.rule()
+	p.row("Pendapatan bersih", Rupiah(r.NetAmount), true)
+	p.y -= 8
+
+	status := map[string]string{
+		"in_escrow": "Ditahan di escrow sampai pesanan selesai",
+		"released":  "Sudah masuk ke saldo",
+		"cancelled": "Pesanan dibatalkan",
+	}[r.Status]
+	p.row("Status dana", status, false)
+
+	return p.bytes()
+}
+
+func commissionLabel(r *EarningsReceipt) string {
+	label := strconv.FormatFloat(float64(r.CommissionRateBps)/100, 'f', -1, 64) + "%"
+	if r.CommissionFlatFee > 0 {
+		label += " + " + Rupiah(r.CommissionFlatFee)
+	}
+	return label
+}
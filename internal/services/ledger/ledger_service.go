@@ -0,0 +1,431 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Platform account codes
+const (
+	AccountPlatformCash    = "PLATFORM_CASH"    // Dana yang sudah diterima dari payment gateway
+	AccountPlatformEscrow  = "PLATFORM_ESCROW"  // Dana pesanan yang ditahan sampai selesai/batal
+	AccountPlatformRevenue = "PLATFORM_REVENUE" // Komisi platform
+	AccountGatewayFees     = "GATEWAY_FEES"     // Biaya payment gateway yang ditanggung platform
+	AccountPaymentHolds    = "PAYMENT_HOLDS"    // Saldo klien yang ditahan untuk pembayaran split
+	AccountPayoutsPending  = "PAYOUTS_PENDING"  // Penarikan freelancer yang belum ditransfer
+	AccountRefundsPayable  = "REFUNDS_PAYABLE"  // Refund klien yang belum dibayarkan
+	AccountPromotions      = "PROMOTIONS"       // Diskon voucher yang ditanggung platform
+	AccountClientShortfall = "CLIENT_SHORTFALL" // Refund isi saldo yang sudah terpakai klien (piutang)
+)
+
+// Reference types for journal entries
+const (
+	RefJobOffer    = "job_offer"
+	RefTransaction = "transaction"
+	RefWithdrawal  = "withdrawal"
+	RefRefund      = "refund_request"
+)
+
+var ErrUnbalanced = errors.New("journal entry is not balanced")
+
+// AccountRef identifies an account and carries what is needed to open it on first use.
+type AccountRef struct {
+	Code    string
+	Name    string
+	Type    models.LedgerAccountType
+	OwnerID *uuid.UUID
+}
+
+var platformAccounts = map[string]AccountRef{
+	AccountPlatformCash:    {Code: AccountPlatformCash, Name: "Kas Platform (Payment Gateway)", Type: models.LedgerAsset},
+	AccountPlatformEscrow:  {Code: AccountPlatformEscrow, Name: "Escrow Pesanan", Type: models.LedgerLiability},
+	AccountPlatformRevenue: {Code: AccountPlatformRevenue, Name: "Pendapatan Komisi Platform", Type: models.LedgerRevenue},
+	AccountGatewayFees:     {Code: AccountGatewayFees, Name: "Biaya Payment Gateway", Type: models.LedgerExpense},
+	AccountPaymentHolds:    {Code: AccountPaymentHolds, Name: "Saldo Klien Ditahan", Type: models.LedgerLiability},
+	AccountPayoutsPending:  {Code: AccountPayoutsPending, Name: "Penarikan Dalam Proses", Type: models.LedgerLiability},
+	AccountRefundsPayable:  {Code: AccountRefundsPayable, Name: "Refund Klien Dalam Proses", Type: models.LedgerLiability},
+	AccountPromotions:      {Code: AccountPromotions, Name: "Biaya Promo Voucher", Type: models.LedgerExpense},
+	AccountClientShortfall: {Code: AccountClientShortfall, Name: "Piutang Refund Isi Saldo Klien", Type: models.LedgerAsset},
+}
+
+// Platform returns the ref of a platform-level account
+func Platform(code string) AccountRef {
+	return platformAccounts[code]
+}
+
+// ClientWallet returns the ref of a user's client balance (User.Balance)
+func ClientWallet(userID uuid.UUID) AccountRef {
+	return AccountRef{
+		Code:    "CLIENT_WALLET:" + userID.String(),
+		Name:    "Saldo Klien " + userID.String(),
+		Type:    models.LedgerLiability,
+		OwnerID: &userID,
+	}
+}
+
+// FreelancerWallet returns the ref of a user's freelancer balance (FreelancerProfile.Balance)
+func FreelancerWallet(userID uuid.UUID) AccountRef {
+	return AccountRef{
+		Code:    "FREELANCER_WALLET:" + userID.String(),
+		Name:    "Saldo Freelancer " + userID.String(),
+		Type:    models.LedgerLiability,
+		OwnerID: &userID,
+	}
+}
+
+// FreelancerPending returns the ref of a user's pending (not yet cleared) freelancer earnings
+// (FreelancerProfile.PendingBalance)
+func FreelancerPending(userID uuid.UUID) AccountRef {
+	return AccountRef{
+		Code:    "FREELANCER_PENDING:" + userID.String(),
+		Name:    "Saldo Tertunda Freelancer " + userID.String(),
+		Type:    models.LedgerLiability,
+		OwnerID: &userID,
+	}
+}
+
+// Line is one debit or credit of a journal entry. Exactly one of Debit/Credit should be set;
+// zero-amount lines are skipped.
+type Line struct {
+	Account AccountRef
+	Debit   int64
+	Credit  int64
+}
+
+func Debit(account AccountRef, amount int64) Line  { return Line{Account: account, Debit: amount} }
+func Credit(account AccountRef, amount int64) Line { return Line{Account: account, Credit: amount} }
+
+type LedgerService struct {
+	DB *gorm.DB
+}
+
+func NewLedgerService(db *gorm.DB) *LedgerService {
+	return &LedgerService{DB: db}
+}
+
+// Post writes a balanced journal entry and updates each account balance, snapshotting
+// balance_after on every line. This should be called within the same DB transaction
+// as the business change it records.
+func (s *LedgerService) Post(tx *gorm.DB, refType string, refID uuid.UUID, description string, lines ...Line) (*models.JournalEntry, error) {
+	active, err := balancedLines(description, lines)
+	if err != nil || len(active) == 0 {
+		return nil, err
+	}
+
+	entry := models.JournalEntry{
+		ID:            uuid.New(),
+		ReferenceType: refType,
+		ReferenceID:   &refID,
+		Description:   description,
+	}
+	if err := tx.Create(&entry).Error; err != nil {
+		return nil, err
+	}
+
+	for _, l := range active {
+		account, err := s.ensureAccount(tx, l.Account)
+		if err != nil {
+			return nil, err
+		}
+
+		delta := l.Credit - l.Debit
+		if account.Type.IsDebitNormal() {
+			delta = l.Debit - l.Credit
+		}
+
+		// Atomic increment; RETURNING gives the balance right after this line
+		if err := tx.Model(account).
+			Clauses(clause.Returning{Columns: []clause.Column{{Name: "balance"}}}).
+			Where("id = ?", account.ID).
+			Update("balance", gorm.Expr("balance + ?", delta)).Error; err != nil {
+			return nil, err
+		}
+
+		line := models.JournalLine{
+			ID:           uuid.New(),
+			EntryID:      entry.ID,
+			AccountID:    account.ID,
+			Debit:        l.Debit,
+			Credit:       l.Credit,
+			BalanceAfter: account.Balance,
+		}
+		if err := tx.Create(&line).Error; err != nil {
+			return nil, err
+		}
+		entry.Lines = append(entry.Lines, line)
+	}
+
+	return &entry, nil
+}
+
+// balancedLines drops the zero-amount lines of an entry and checks that the rest balance
+func balancedLines(description string, lines []Line) ([]Line, error) {
+	var totalDebit, totalCredit int64
+	active := make([]Line, 0, len(lines))
+	for _, l := range lines {
+		if l.Debit < 0 || l.Credit < 0 {
+			return nil, fmt.Errorf("negative amount on account %s", l.Account.Code)
+		}
+		if l.Debit == 0 && l.Credit == 0 {
+			continue
+		}
+		totalDebit += l.Debit
+		totalCredit += l.Credit
+		active = append(active, l)
+	}
+
+	if len(active) > 0 && totalDebit != totalCredit {
+		return nil, fmt.Errorf("%w: debit %d, credit %d (%s)", ErrUnbalanced, totalDebit, totalCredit, description)
+	}
+	return active, nil
+}
+
+// ensureAccount opens the account on first use and returns it
+func (s *LedgerService) ensureAccount(tx *gorm.DB, ref AccountRef) (*models.LedgerAccount, error) {
+	if ref.Code == "" {
+		return nil, errors.New("ledger account code is required")
+	}
+
+	account := models.LedgerAccount{
+		ID:      uuid.New(),
+		Code:    ref.Code,
+		Name:    ref.Name,
+		Type:    ref.Type,
+		OwnerID: ref.OwnerID,
+	}
+	if err := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "code"}}, DoNothing: true}).
+		Create(&account).Error; err != nil {
+		return nil, err
+	}
+
+	if err := tx.Where("code = ?", ref.Code).First(&account).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// ===== Domain postings =====
+
+// RecordOrderPayment posts a PAID order transaction into escrow. The wallet portion comes
+// from the client's balance (directly, or from the hold of a split payment) and the
+// gateway portion from cash, net of gateway fees.
+func (s *LedgerService) RecordOrderPayment(tx *gorm.DB, trx *models.Transaction, offer *models.JobOffer, fromHold bool) error {
+	desc := "Pembayaran pesanan #" + offer.OrderCode + " masuk escrow"
+	escrow := Platform(AccountPlatformEscrow)
+
+	lines := []Line{}
+	if trx.BalanceAmount > 0 {
+		source := ClientWallet(offer.ClientID)
+		if fromHold {
+			source = Platform(AccountPaymentHolds)
+		}
+		lines = append(lines, Debit(source, trx.BalanceAmount), Credit(escrow, trx.BalanceAmount))
+	}
+
+	if trx.PaymentMethod != models.PaymentMethodBalance {
+		lines = append(lines, gatewayLines(trx, escrow)...)
+	}
+
+	_, err := s.Post(tx, RefTransaction, trx.ID, desc, lines...)
+	return err
+}
+
+// RecordUnmatchedPayment books a gateway payment that cannot go to escrow (e.g. a second
+// attempt paid for an order that is already paid) straight to the client's balance.
+func (s *LedgerService) RecordUnmatchedPayment(tx *gorm.DB, trx *models.Transaction, offer *models.JobOffer) error {
+	_, err := s.Post(tx, RefTransaction, trx.ID, "Pembayaran pesanan #"+offer.OrderCode+" tidak dapat diproses, dikembalikan ke saldo klien",
+		gatewayLines(trx, ClientWallet(offer.ClientID))...)
+	return err
+}
+
+// RecordTopUp books a paid wallet top-up: the gateway portion goes to the client's balance
+func (s *LedgerService) RecordTopUp(tx *gorm.DB, trx *models.Transaction) error {
+	_, err := s.Post(tx, RefTransaction, trx.ID, "Isi saldo klien ("+trx.MerchantRef+")",
+		gatewayLines(trx, ClientWallet(*trx.UserID))...)
+	return err
+}
+
+// RecordTopUpRefund reverses a top-up the gateway refunded to the customer. The part the
+// client had already spent (trx.RefundShortfall) is booked as owed to the platform instead
+// of being taken from their balance.
+func (s *LedgerService) RecordTopUpRefund(tx *gorm.DB, trx *models.Transaction) error {
+	lines := reversed(gatewayLines(trx, ClientWallet(*trx.UserID)))
+	if trx.RefundShortfall > 0 {
+		lines[len(lines)-1].Debit -= trx.RefundShortfall
+		lines = append(lines, Debit(Platform(AccountClientShortfall), trx.RefundShortfall))
+	}
+	_, err := s.Post(tx, RefTransaction, trx.ID, "Refund isi saldo klien ("+trx.MerchantRef+")", lines...)
+	return err
+}
+
+// RecordGatewayRefund reverses the gateway part of an order payment that the gateway refunded
+// to the customer: the escrow goes back out of platform cash and the fee booking is undone.
+func (s *LedgerService) RecordGatewayRefund(tx *gorm.DB, trx *models.Transaction, offer *models.JobOffer) error {
+	_, err := s.Post(tx, RefTransaction, trx.ID, "Refund payment gateway pesanan #"+offer.OrderCode,
+		reversed(gatewayLines(trx, Platform(AccountPlatformEscrow)))...)
+	return err
+}
+
+// gatewayLines books money collected by the gateway into target.
+// Cash received + fees paid to the gateway = what the client paid (price part + customer fee).
+// The customer fee offsets the gateway cost, so only the net fee is an expense.
+func gatewayLines(trx *models.Transaction, target AccountRef) []Line {
+	gatewayPortion := trx.TotalAmount - trx.FeeCustomer
+	netFee := (trx.TotalAmount - trx.AmountReceived) - trx.FeeCustomer
+
+	lines := []Line{Debit(Platform(AccountPlatformCash), trx.AmountReceived)}
+	if netFee >= 0 {
+		lines = append(lines, Debit(Platform(AccountGatewayFees), netFee))
+	} else {
+		lines = append(lines, Credit(Platform(AccountGatewayFees), -netFee))
+	}
+	return append(lines, Credit(target, gatewayPortion))
+}
+
+// reversed swaps the debit and credit side of every line
+func reversed(lines []Line) []Line {
+	out := make([]Line, len(lines))
+	for i, l := range lines {
+		out[i] = Line{Account: l.Account, Debit: l.Credit, Credit: l.Debit}
+	}
+	return out
+}
+
+// RecordPaymentHold moves part of the client's balance into holds for a split payment
+func (s *LedgerService) RecordPaymentHold(tx *gorm.DB, offer *models.JobOffer, amount int64) error {
+	_, err := s.Post(tx, RefJobOffer, offer.ID, "Saldo ditahan untuk pembayaran pesanan #"+offer.OrderCode,
+		Debit(ClientWallet(offer.ClientID), amount),
+		Credit(Platform(AccountPaymentHolds), amount),
+	)
+	return err
+}
+
+// RecordPaymentHoldRelease returns a split-payment hold back to the client's balance
+func (s *LedgerService) RecordPaymentHoldRelease(tx *gorm.DB, offer *models.JobOffer, amount int64) error {
+	_, err := s.Post(tx, RefJobOffer, offer.ID, "Saldo ditahan dikembalikan untuk pesanan #"+offer.OrderCode,
+		Debit(Platform(AccountPaymentHolds), amount),
+		Credit(ClientWallet(offer.ClientID), amount),
+	)
+	return err
+}
+
+// RecordEscrowDeposit moves money paid after checkout (extra revisions, a milestone) from the
+// client's balance into the order's escrow
+func (s *LedgerService) RecordEscrowDeposit(tx *gorm.DB, offer *models.JobOffer, amount int64, description string) error {
+	_, err := s.Post(tx, RefJobOffer, offer.ID, description,
+		Debit(ClientWallet(offer.ClientID), amount),
+		Credit(Platform(AccountPlatformEscrow), amount),
+	)
+	return err
+}
+
+// RecordEscrowRelease pays a completed order (or milestone) out of escrow: net amount to the
+// freelancer's pending balance, platform fee to revenue (see JobOffer.NextRelease). A
+// platform-funded voucher discount is paid from promotions on top of the escrow; rounding
+// between milestone shares can flow back the other way.
+func (s *LedgerService) RecordEscrowRelease(tx *gorm.DB, offer *models.JobOffer, r models.ReleaseShare, description string) error {
+	promotions := Debit(Platform(AccountPromotions), r.Promotions())
+	if r.Promotions() < 0 {
+		promotions = Credit(Platform(AccountPromotions), -r.Promotions())
+	}
+	_, err := s.Post(tx, RefJobOffer, offer.ID, description,
+		Debit(Platform(AccountPlatformEscrow), r.Escrow),
+		promotions,
+		Credit(FreelancerPending(offer.FreelancerID), r.Net),
+		Credit(Platform(AccountPlatformRevenue), r.Fee),
+	)
+	return err
+}
+
+// RecordEscrowReleaseReversal puts released earnings back into escrow: the exact mirror of
+// RecordEscrowRelease
+func (s *LedgerService) RecordEscrowReleaseReversal(tx *gorm.DB, offer *models.JobOffer, r models.ReleaseShare, description string) error {
+	promotions := Credit(Platform(AccountPromotions), r.Promotions())
+	if r.Promotions() < 0 {
+		promotions = Debit(Platform(AccountPromotions), -r.Promotions())
+	}
+	_, err := s.Post(tx, RefJobOffer, offer.ID, description,
+		Debit(FreelancerPending(offer.FreelancerID), r.Net),
+		Debit(Platform(AccountPlatformRevenue), r.Fee),
+		promotions,
+		Credit(Platform(AccountPlatformEscrow), r.Escrow),
+	)
+	return err
+}
+
+// RecordEarningCleared moves cleared earnings from the freelancer's pending balance to the withdrawable one
+func (s *LedgerService) RecordEarningCleared(tx *gorm.DB, c *models.EarningClearance) error {
+	_, err := s.Post(tx, RefJobOffer, c.JobOfferID, "Kliring pendapatan pesanan #"+c.OrderCode,
+		Debit(FreelancerPending(c.UserID), c.Amount),
+		Credit(FreelancerWallet(c.UserID), c.Amount),
+	)
+	return err
+}
+
+// RecordEscrowRefund returns a cancelled order's escrow to the client's balance
+func (s *LedgerService) RecordEscrowRefund(tx *gorm.DB, offer *models.JobOffer, amount int64) error {
+	_, err := s.Post(tx, RefJobOffer, offer.ID, "Pengembalian escrow pesanan #"+offer.OrderCode+" ke saldo klien",
+		Debit(Platform(AccountPlatformEscrow), amount),
+		Credit(ClientWallet(offer.ClientID), amount),
+	)
+	return err
+}
+
+// RecordRefundReserve moves the refunded part of an order out of escrow into refunds payable.
+// A refunded payment fee is absorbed by the platform as a gateway cost.
+func (s *LedgerService) RecordRefundReserve(tx *gorm.DB, r *models.RefundRequest, offer *models.JobOffer) error {
+	_, err := s.Post(tx, RefRefund, r.ID, "Refund pesanan #"+offer.OrderCode+" diajukan",
+		Debit(Platform(AccountPlatformEscrow), r.Amount),
+		Debit(Platform(AccountGatewayFees), r.FeeRefund),
+		Credit(Platform(AccountRefundsPayable), r.Amount+r.FeeRefund),
+	)
+	return err
+}
+
+// RecordRefundPayout pays a refund out, either to the client's balance or out of platform
+// cash. The transfer fee kept by the platform offsets its transfer costs.
+func (s *LedgerService) RecordRefundPayout(tx *gorm.DB, r *models.RefundRequest) error {
+	destination := Platform(AccountPlatformCash)
+	if r.Destination == models.RefundToBalance {
+		destination = ClientWallet(r.ClientID)
+	}
+
+	_, err := s.Post(tx, RefRefund, r.ID, "Refund dibayarkan ("+string(r.Destination)+")",
+		Debit(Platform(AccountRefundsPayable), r.Amount+r.FeeRefund),
+		Credit(destination, r.PayoutAmount()),
+		Credit(Platform(AccountGatewayFees), r.TransferFee),
+	)
+	return err
+}
+
+// RecordWithdrawalHold moves a requested withdrawal out of the freelancer balance
+func (s *LedgerService) RecordWithdrawalHold(tx *gorm.DB, w *models.Withdrawal) error {
+	_, err := s.Post(tx, RefWithdrawal, w.ID, "Penarikan saldo freelancer diajukan",
+		Debit(FreelancerWallet(w.UserID), w.Amount),
+		Credit(Platform(AccountPayoutsPending), w.Amount),
+	)
+	return err
+}
+
+// RecordWithdrawalReversal returns a rejected/cancelled/failed withdrawal to the freelancer balance
+func (s *LedgerService) RecordWithdrawalReversal(tx *gorm.DB, w *models.Withdrawal) error {
+	_, err := s.Post(tx, RefWithdrawal, w.ID, "Penarikan saldo freelancer dikembalikan ("+string(w.Status)+")",
+		Debit(Platform(AccountPayoutsPending), w.Amount),
+		Credit(FreelancerWallet(w.UserID), w.Amount),
+	)
+	return err
+}
+
+// RecordWithdrawalPayout records the money leaving the platform for a transferred withdrawal
+func (s *LedgerService) RecordWithdrawalPayout(tx *gorm.DB, w *models.Withdrawal) error {
+	_, err := s.Post(tx, RefWithdrawal, w.ID, "Penarikan saldo freelancer ditransfer ("+w.TransferReference+")",
+		Debit(Platform(AccountPayoutsPending), w.Amount),
+		Credit(Platform(AccountPlatformCash), w.Amount),
+	)
+	return err
+}
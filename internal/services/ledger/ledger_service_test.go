@@ -0,0 +1,95 @@
+package ledger
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/models"
+	"github.com/google/uuid"
+)
+
+func TestPostRejectsUnbalancedEntries(t *testing.T) {
+	escrow := Platform(AccountPlatformEscrow)
+	cash := Platform(AccountPlatformCash)
+	client := ClientWallet(uuid.New())
+
+	tests := []struct {
+		name  string
+		lines []Line
+	}{
+		{"debit only", []Line{Debit(cash, 100)}},
+		{"credit only", []Line{Credit(escrow, 100)}},
+		{"debit above credit", []Line{Debit(cash, 100), Credit(escrow, 99)}},
+		{"credit above debit", []Line{Debit(cash, 100), Credit(escrow, 60), Credit(client, 50)}},
+		{"zero lines do not hide a gap", []Line{Debit(cash, 100), Credit(escrow, 0), Credit(client, 90)}},
+	}
+
+	s := &LedgerService{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The entry is rejected before the DB is touched, hence the nil tx
+			entry, err := s.Post(nil, RefJobOffer, uuid.New(), tt.name, tt.lines...)
+			if !errors.Is(err, ErrUnbalanced) {
+				t.Fatalf("Post() error = %v, want ErrUnbalanced", err)
+			}
+			if entry != nil {
+				t.Fatalf("Post() entry = %+v, want nil", entry)
+			}
+		})
+	}
+}
+
+func TestBalancedLines(t *testing.T) {
+	escrow := Platform(AccountPlatformEscrow)
+	cash := Platform(AccountPlatformCash)
+	fees := Platform(AccountGatewayFees)
+
+	tests := []struct {
+		name       string
+		lines      []Line
+		wantActive int
+		wantErr    bool
+	}{
+		{"balanced", []Line{Debit(cash, 95), Debit(fees, 5), Credit(escrow, 100)}, 3, false},
+		{"zero-amount lines are dropped", []Line{Debit(cash, 100), Debit(fees, 0), Credit(escrow, 100)}, 2, false},
+		{"nothing to post", []Line{Debit(cash, 0), Credit(escrow, 0)}, 0, false},
+		{"negative debit", []Line{Debit(cash, -100), Credit(escrow, -100)}, 0, true},
+		{"negative credit", []Line{Debit(cash, 0), Credit(escrow, -1)}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			active, err := balancedLines(tt.name, tt.lines)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("balancedLines() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(active) != tt.wantActive {
+				t.Fatalf("balancedLines() kept %d lines, want %d", len(active), tt.wantActive)
+			}
+		})
+	}
+}
+
+func TestGatewayLinesBalance(t *testing.T) {
+	client := ClientWallet(uuid.New())
+
+	tests := []struct {
+		name string
+		trx  models.Transaction
+	}{
+		{"fee paid by the platform", models.Transaction{TotalAmount: 100000, FeeCustomer: 0, AmountReceived: 95750}},
+		{"fee paid by the customer", models.Transaction{TotalAmount: 104250, FeeCustomer: 4250, AmountReceived: 100000}},
+		{"customer fee above the gateway cost", models.Transaction{TotalAmount: 105000, FeeCustomer: 5000, AmountReceived: 101000}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := balancedLines(tt.name, gatewayLines(&tt.trx, client)); err != nil {
+				t.Fatalf("gatewayLines() not balanced: %v", err)
+			}
+			if _, err := balancedLines(tt.name, reversed(gatewayLines(&tt.trx, client))); err != nil {
+				t.Fatalf("reversed gatewayLines() not balanced: %v", err)
+			}
+		})
+	}
+}
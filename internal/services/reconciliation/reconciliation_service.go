@@ -0,0 +1,397 @@
+package reconciliation
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/models"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/ledger"
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Check names, used as Issue.Check and as keys of the report summary
+const (
+	CheckFreelancerBalance  = "freelancer_balance_drift"
+	CheckFreelancerPending  = "freelancer_pending_drift"
+	CheckClientBalance      = "client_balance_drift"
+	CheckEscrowStuck        = "escrow_stuck_in_paid"
+	CheckPaidOfferPending   = "paid_transaction_offer_pending"
+	CheckOrphanTransaction  = "transaction_without_offer"
+	CheckPaymentAmount      = "payment_amount_mismatch"
+	CheckEscrowLedgerTotals = "escrow_ledger_mismatch"
+	CheckTopUpShortfall     = "topup_refund_shortfall"
+)
+
+// Issue is a single drift or anomaly found by a run
+type Issue struct {
+	Check    string `json:"check"`
+	EntityID string `json:"entity_id"`
+	Expected int64  `json:"expected"`
+	Actual   int64  `json:"actual"`
+	Diff     int64  `json:"diff"`
+	Detail   string `json:"detail"`
+}
+
+type ReconciliationService struct {
+	DB *gorm.DB
+	// StuckAfter is how long an order may sit in "paid" (funds in escrow, work not started)
+	// before it is reported.
+	StuckAfter time.Duration
+}
+
+func NewReconciliationService(db *gorm.DB, stuckAfter time.Duration) *ReconciliationService {
+	return &ReconciliationService{DB: db, StuckAfter: stuckAfter}
+}
+
+// Run executes every check and stores the report. The report is returned even when a check fails.
+func (s *ReconciliationService) Run(trigger string) (*models.ReconciliationReport, error) {
+	report := models.ReconciliationReport{
+		ID:        uuid.New(),
+		Trigger:   trigger,
+		Status:    models.ReconciliationRunning,
+		StartedAt: time.Now(),
+	}
+	if err := s.DB.Create(&report).Error; err != nil {
+		return nil, err
+	}
+
+	checks := []struct {
+		name string
+		fn   func() ([]Issue, error)
+	}{
+		{CheckFreelancerBalance, s.checkFreelancerBalances},
+		{CheckFreelancerPending, s.checkFreelancerPending},
+		{CheckClientBalance, s.checkClientBalances},
+		{CheckEscrowStuck, s.checkStuckEscrow},
+		{CheckPaidOfferPending, s.checkPaidOffersPending},
+		{CheckOrphanTransaction, s.checkOrphanTransactions},
+		{CheckPaymentAmount, s.checkPaymentAmounts},
+		{CheckEscrowLedgerTotals, s.checkEscrowLedger},
+		{CheckTopUpShortfall, s.checkTopUpShortfalls},
+	}
+
+	issues := []Issue{}
+	summary := map[string]int{}
+	var runErr error
+	for _, check := range checks {
+		found, err := check.fn()
+		if err != nil {
+			runErr = fmt.Errorf("%s: %w", check.name, err)
+			break
+		}
+		summary[check.name] = len(found)
+		issues = append(issues, found...)
+	}
+
+	now := time.Now()
+	report.FinishedAt = &now
+	report.IssueCount = len(issues)
+	report.Summary, _ = json.Marshal(summary)
+	report.Issues, _ = json.Marshal(issues)
+
+	switch {
+	case runErr != nil:
+		report.Status = models.ReconciliationFailed
+		report.Error = runErr.Error()
+	case len(issues) > 0:
+		report.Status = models.ReconciliationDrift
+	default:
+		report.Status = models.ReconciliationClean
+	}
+
+	if err := s.DB.Save(&report).Error; err != nil {
+		return &report, err
+	}
+
+	log.Printf("[Reconciliation] Run %s (%s) finished: status=%s issues=%d", report.ID, trigger, report.Status, report.IssueCount)
+	return &report, runErr
+}
+
+// StartNightlyWorker runs the reconciliation every day at the given local hour
+func (s *ReconciliationService) StartNightlyWorker(hour int) {
+	go func() {
+		for {
+			now := time.Now()
+			next := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+			if !next.After(now) {
+				next = next.AddDate(0, 0, 1)
+			}
+			time.Sleep(time.Until(next))
+
+			log.Println("[Reconciliation] Starting nightly wallet reconciliation...")
+			if _, err := s.Run("scheduled"); err != nil {
+				log.Printf("[Reconciliation] Nightly run failed: %v", err)
+			}
+		}
+	}()
+}
+
+// ===== Checks =====
+
+type balanceRow struct {
+	UserID   uuid.UUID
+	Balance  int64
+	Computed int64
+}
+
+// walletSumSQL computes a wallet balance from its ledger rows: credits and refunds add, debits subtract
+const walletSumSQL = `COALESCE(SUM(CASE WHEN wt.type = 'debit' THEN -wt.amount ELSE wt.amount END), 0)`
+
+func (s *ReconciliationService) checkFreelancerBalances() ([]Issue, error) {
+	var rows []balanceRow
+	err := s.DB.Raw(`
+		SELECT fp.user_id, fp.balance, `+walletSumSQL+` AS computed
+		FROM freelancer_profiles fp
+		LEFT JOIN wallet_transactions wt ON wt.user_id = fp.user_id AND wt.wallet = ?
+		GROUP BY fp.user_id, fp.balance
+		HAVING fp.balance <> `+walletSumSQL, models.WalletFreelancer).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return balanceIssues(CheckFreelancerBalance, "FreelancerProfile.Balance", rows), nil
+}
+
+func (s *ReconciliationService) checkFreelancerPending() ([]Issue, error) {
+	var rows []balanceRow
+	err := s.DB.Raw(`
+		SELECT fp.user_id, fp.pending_balance AS balance, COALESCE(SUM(ec.amount), 0) AS computed
+		FROM freelancer_profiles fp
+		LEFT JOIN earning_clearances ec ON ec.user_id = fp.user_id AND ec.status = ?
+		GROUP BY fp.user_id, fp.pending_balance
+		HAVING fp.pending_balance <> COALESCE(SUM(ec.amount), 0)`, models.ClearancePending).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return balanceIssues(CheckFreelancerPending, "FreelancerProfile.PendingBalance", rows), nil
+}
+
+func (s *ReconciliationService) checkClientBalances() ([]Issue, error) {
+	var rows []balanceRow
+	err := s.DB.Raw(`
+		SELECT u.id AS user_id, u.balance, `+walletSumSQL+` AS computed
+		FROM users u
+		LEFT JOIN wallet_transactions wt ON wt.user_id = u.id AND wt.wallet = ?
+		GROUP BY u.id, u.balance
+		HAVING u.balance <> `+walletSumSQL, models.WalletClient).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return balanceIssues(CheckClientBalance, "User.Balance", rows), nil
+}
+
+func balanceIssues(check, field string, rows []balanceRow) []Issue {
+	issues := make([]Issue, 0, len(rows))
+	for _, r := range rows {
+		issues = append(issues, Issue{
+			Check:    check,
+			EntityID: r.UserID.String(),
+			Expected: r.Computed,
+			Actual:   r.Balance,
+			Diff:     r.Balance - r.Computed,
+			Detail:   field + " does not match the sum of its wallet transactions",
+		})
+	}
+	return issues
+}
+
+func (s *ReconciliationService) checkStuckEscrow() ([]Issue, error) {
+	var offers []models.JobOffer
+	if err := s.DB.Where("status = ? AND updated_at <= ?", models.OfferStatusPaid, time.Now().Add(-s.StuckAfter)).
+		Find(&offers).Error; err != nil {
+		return nil, err
+	}
+
+	issues := make([]Issue, 0, len(offers))
+	for _, o := range offers {
+		issues = append(issues, Issue{
+			Check:    CheckEscrowStuck,
+			EntityID: o.ID.String(),
+			Expected: 0,
+			Actual:   o.Price,
+			Diff:     o.Price,
+			Detail:   fmt.Sprintf("Order #%s has been paid (in escrow) since %s without progress", o.OrderCode, o.UpdatedAt.Format(time.RFC3339)),
+		})
+	}
+	return issues, nil
+}
+
+type transactionOfferRow struct {
+	TransactionID  string
+	Reference      string
+	JobOfferID     string
+	OfferFound     bool
+	OrderCode      string
+	OfferStatus    string
+	Price          int64 // Checkout amount, see JobOffer.CheckoutAmount
+	DiscountAmount int64
+	PaymentMethod  string
+	TotalAmount    int64
+	FeeCustomer    int64
+	BalanceAmount  int64
+}
+
+func (s *ReconciliationService) paidTransactions() ([]transactionOfferRow, error) {
+	var rows []transactionOfferRow
+	err := s.DB.Raw(`
+		SELECT t.id AS transaction_id, t.reference, t.job_offer_id,
+			(o.id IS NOT NULL) AS offer_found, o.order_code, o.status AS offer_status, COALESCE(o.price - o.deferred_amount, 0) AS price,
+			t.discount_amount, t.payment_method, t.total_amount, t.fee_customer, t.balance_amount
+		FROM transactions t
+		LEFT JOIN job_offers o ON o.id::text = t.job_offer_id
+		WHERE t.status = ? AND t.purpose = ? AND NOT t.returned_to_balance`, models.TransactionStatusPaid, models.TransactionPurposeOrder).
+		Scan(&rows).Error
+	return rows, err
+}
+
+func (s *ReconciliationService) checkPaidOffersPending() ([]Issue, error) {
+	rows, err := s.paidTransactions()
+	if err != nil {
+		return nil, err
+	}
+
+	issues := []Issue{}
+	for _, r := range rows {
+		if r.OfferFound && r.OfferStatus == string(models.OfferStatusPending) {
+			issues = append(issues, Issue{
+				Check:    CheckPaidOfferPending,
+				EntityID: r.TransactionID,
+				Expected: r.Price,
+				Actual:   0,
+				Diff:     -r.Price,
+				Detail:   fmt.Sprintf("Transaction %s is PAID but order #%s is still pending", r.Reference, r.OrderCode),
+			})
+		}
+	}
+	return issues, nil
+}
+
+func (s *ReconciliationService) checkOrphanTransactions() ([]Issue, error) {
+	rows, err := s.paidTransactions()
+	if err != nil {
+		return nil, err
+	}
+
+	issues := []Issue{}
+	for _, r := range rows {
+		if !r.OfferFound {
+			issues = append(issues, Issue{
+				Check:    CheckOrphanTransaction,
+				EntityID: r.TransactionID,
+				Expected: 0,
+				Actual:   r.TotalAmount,
+				Diff:     r.TotalAmount,
+				Detail:   fmt.Sprintf("PAID transaction %s points to job offer %s which does not exist", r.Reference, r.JobOfferID),
+			})
+		}
+	}
+	return issues, nil
+}
+
+func (s *ReconciliationService) checkPaymentAmounts() ([]Issue, error) {
+	rows, err := s.paidTransactions()
+	if err != nil {
+		return nil, err
+	}
+
+	issues := []Issue{}
+	for _, r := range rows {
+		if !r.OfferFound {
+			continue
+		}
+
+		// Price portion actually collected: wallet part + gateway part without the customer fee
+		collected := r.BalanceAmount
+		if r.PaymentMethod != models.PaymentMethodBalance {
+			collected += r.TotalAmount - r.FeeCustomer
+		}
+
+		payable := r.Price - r.DiscountAmount
+		if collected != payable {
+			issues = append(issues, Issue{
+				Check:    CheckPaymentAmount,
+				EntityID: r.TransactionID,
+				Expected: payable,
+				Actual:   collected,
+				Diff:     collected - payable,
+				Detail:   fmt.Sprintf("Transaction %s collected a different amount than order #%s (%s) price minus discount", r.Reference, r.OrderCode, r.OfferStatus),
+			})
+		}
+	}
+	return issues, nil
+}
+
+// checkTopUpShortfalls lists top-ups the gateway refunded after the client had spent part of
+// them; the shortfall has to be recovered from the client manually
+func (s *ReconciliationService) checkTopUpShortfalls() ([]Issue, error) {
+	var trxs []models.Transaction
+	if err := s.DB.Where("purpose = ? AND status = ? AND refund_shortfall > 0",
+		models.TransactionPurposeTopUp, models.TransactionStatusRefund).
+		Find(&trxs).Error; err != nil {
+		return nil, err
+	}
+
+	issues := make([]Issue, 0, len(trxs))
+	for _, t := range trxs {
+		issues = append(issues, Issue{
+			Check:    CheckTopUpShortfall,
+			EntityID: t.ID.String(),
+			Expected: t.TotalAmount - t.FeeCustomer,
+			Actual:   t.TotalAmount - t.FeeCustomer - t.RefundShortfall,
+			Diff:     -t.RefundShortfall,
+			Detail:   fmt.Sprintf("Top-up %s was refunded by the gateway but Rp %d had already been spent by user %s", t.Reference, t.RefundShortfall, t.UserID),
+		})
+	}
+	return issues, nil
+}
+
+func (s *ReconciliationService) checkEscrowLedger() ([]Issue, error) {
+	var expected int64
+	if err := s.DB.Model(&models.JobOffer{}).
+		Where("status IN ?", []models.JobOfferStatus{
+			models.OfferStatusPaid,
+			models.OfferStatusWorking,
+			models.OfferStatusDelivered,
+			models.OfferStatusDisputed,
+		}).
+		Select("COALESCE(SUM(price - discount_amount + extras_amount - (deferred_amount - deferred_funded) - refunded_amount - released_amount), 0)").
+		Scan(&expected).Error; err != nil {
+		return nil, err
+	}
+
+	var account models.LedgerAccount
+	if err := s.DB.Where("code = ?", ledger.AccountPlatformEscrow).Limit(1).Find(&account).Error; err != nil {
+		return nil, err
+	}
+
+	if account.Balance == expected {
+		return nil, nil
+	}
+
+	return []Issue{{
+		Check:    CheckEscrowLedgerTotals,
+		EntityID: ledger.AccountPlatformEscrow,
+		Expected: expected,
+		Actual:   account.Balance,
+		Diff:     account.Balance - expected,
+		Detail:   "Escrow ledger balance does not match the (unrefunded) price of orders currently held in escrow",
+	}}, nil
+}
+
+// DecodeIssues unmarshals the stored issues of a report
+func DecodeIssues(raw datatypes.JSON) []Issue {
+	issues := []Issue{}
+	if len(raw) > 0 {
+		_ = json.Unmarshal(raw, &issues)
+	}
+	return issues
+}
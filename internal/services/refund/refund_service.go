@@ -0,0 +1,93 @@
+package refund
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/models"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/ledger"
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/wallet"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrExceedsEscrow = errors.New("refund amount exceeds the escrow left for this order")
+
+type RefundService struct {
+	DB     *gorm.DB
+	Wallet *wallet.WalletService
+	Ledger *ledger.LedgerService
+}
+
+func NewRefundService(db *gorm.DB, walletService *wallet.WalletService, ledgerService *ledger.LedgerService) *RefundService {
+	return &RefundService{DB: db, Wallet: walletService, Ledger: ledgerService}
+}
+
+// Open takes amount out of the order's escrow and creates a refund request waiting for the
+// client to choose a destination. The caller must hold a lock on the offer row.
+//
+// Fee rule: the payment fee the client paid is only refunded (refundFee) for a full refund
+// of an order that is cancelled on the seller side; partial refunds never include it.
+func (s *RefundService) Open(tx *gorm.DB, offer *models.JobOffer, amount int64, refundFee bool, reason string, requestedBy *uuid.UUID) (*models.RefundRequest, error) {
+	if amount <= 0 || amount > offer.EscrowRemaining() {
+		return nil, ErrExceedsEscrow
+	}
+
+	r := models.RefundRequest{
+		ID:          uuid.New(),
+		JobOfferID:  offer.ID,
+		ClientID:    offer.ClientID,
+		Amount:      amount,
+		Reason:      reason,
+		Status:      models.RefundStatusAwaitingClient,
+		RequestedBy: requestedBy,
+	}
+
+	var trx models.Transaction
+	err := tx.Where("job_offer_id = ? AND status = ? AND NOT returned_to_balance", offer.ID, models.TransactionStatusPaid).
+		Order("paid_at DESC").First(&trx).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err == nil {
+		r.TransactionID = &trx.ID
+		if refundFee {
+			r.FeeRefund = trx.FeeCustomer
+		}
+	}
+
+	if err := tx.Create(&r).Error; err != nil {
+		return nil, err
+	}
+
+	offer.RefundedAmount += amount
+	if err := tx.Model(&models.JobOffer{}).Where("id = ?", offer.ID).
+		UpdateColumn("refunded_amount", offer.RefundedAmount).Error; err != nil {
+		return nil, err
+	}
+
+	if err := s.Ledger.RecordRefundReserve(tx, &r, offer); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Complete pays the refund out to its destination and closes the request
+func (s *RefundService) Complete(tx *gorm.DB, r *models.RefundRequest, reference string) error {
+	if r.Destination == models.RefundToBalance {
+		desc := "Pengembalian dana pesanan (refund)"
+		if err := s.Wallet.CreditClient(tx, r.ClientID, r.PayoutAmount(), r.JobOfferID, desc); err != nil {
+			return err
+		}
+	}
+
+	if err := s.Ledger.RecordRefundPayout(tx, r); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	r.Status = models.RefundStatusCompleted
+	r.RefundReference = reference
+	r.CompletedAt = &now
+	return nil
+}
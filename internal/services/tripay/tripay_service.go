@@ -2,15 +2,16 @@ package tripay
 
 import (
 	"bytes"
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"time"
+
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/services/gateway"
 )
 
 type TripayService struct {
@@ -19,9 +20,14 @@ type TripayService struct {
 	PrivateKey   string
 	MerchantCode string
 	BaseURL      string
+	// CallbackURL is sent with every transaction. When empty, Tripay falls back to
+	// the callback URL configured in the merchant dashboard.
+	CallbackURL string
 }
 
-func NewTripayService() *TripayService {
+var _ gateway.PaymentGateway = (*TripayService)(nil)
+
+func NewTripayService(callbackURL string) *TripayService {
 	baseURL := "https://tripay.co.id/api-sandbox" // Default to sandbox
 	if os.Getenv("TRIPAY_ENV") == "production" {
 		baseURL = "https://tripay.co.id/api"
@@ -33,9 +39,14 @@ func NewTripayService() *TripayService {
 		PrivateKey:   os.Getenv("TRIPAY_PRIVATE_KEY"),
 		MerchantCode: os.Getenv("TRIPAY_MERCHANT_CODE"),
 		BaseURL:      baseURL,
+		CallbackURL:  callbackURL,
 	}
 }
 
+func (s *TripayService) Name() string {
+	return "tripay"
+}
+
 type OrderItem struct {
 	Name     string `json:"name"`
 	Price    int64  `json:"price"`
@@ -50,7 +61,7 @@ type TransactionRequest struct {
 	CustomerEmail string      `json:"customer_email"`
 	CustomerPhone string      `json:"customer_phone"`
 	OrderItems    []OrderItem `json:"order_items"`
-	Callback      string      `json:"callback_url"`
+	Callback      string      `json:"callback_url,omitempty"`
 	ReturnUrl     string      `json:"return_url"`
 	ExpiredTime   int64       `json:"expired_time"` // Unix timestamp
 	Signature     string      `json:"signature"`
@@ -67,100 +78,61 @@ type TransactionResponse struct {
 	} `json:"data"`
 }
 
-func (s *TripayService) CreateTransaction(
-	merchantRef string,
-	amount int64,
-	customerName, customerEmail, customerPhone string,
-	itemName string,
-	method string,
-	returnUrl string,
-) (*TransactionResponse, error) {
-
+// CreateTransaction requests a Closed Payment transaction. Tripay returns a
+// 'checkout_url' that displays the payment instructions for the chosen method.
+func (s *TripayService) CreateTransaction(req gateway.CreateTransactionRequest) (*gateway.CreatedTransaction, error) {
 	// 1. Calculate Signature
 	// HMAC-SHA256( merchant_code + merchant_ref + amount, private_key )
-	sigData := fmt.Sprintf("%s%s%d", s.MerchantCode, merchantRef, amount)
-	signature := s.generateSignature(sigData)
+	sigData := fmt.Sprintf("%s%s%d", s.MerchantCode, req.MerchantRef, req.Amount)
+	signature := gateway.Sign(s.PrivateKey, []byte(sigData))
 
 	// 2. Prepare Request
-	// Defaulting to "BCAVA" or any valid method, usually closed payment requires method.
-	// However, for "Closed Payment" in Tripay, we typically assume specific method or allow user to choose.
-	// If we use "Open Payment" creating a generic checkout URL might be different.
-	// For simplicity in this flow, we might try to use their "Closed Payment" but getting a Checkout URL usually implies
-	// creating a transaction with a method OR using their payment page if supported.
-	// Let's assume we want to redirect to their hosted checkout if possible, or we pick a default method for now
-	// OR we might need to ask the user to pick a method first?
-	// The user requirement says "redirect to tripay", so usually that means getting a checkout_url.
-	// For Closed Payment, Tripay returns a 'checkout_url' that displays payment instructions.
-
-	// Hardcoding 'BCAVA' for now as placeholder if needed, or maybe Tripay allows empty method for hosted?
-	// Actually Tripay API 'request transaction' needs 'method'.
-	// Let's use a popular one or make it dynamic if we had a frontend selector.
-	// For "Pay button" that redirects, maybe we use a general method or just "QRIS" as default.
-	// method := "QRIS"
-	// Use method passed in argument function signature (but wait, signature previously didn't have method)
-
-	baseURL := os.Getenv("BASE_URL")
-	if baseURL == "" {
-		baseURL = os.Getenv("APP_BASE_URL")
-	}
-	if baseURL == "" {
-		baseURL = "https://78829e04e3b8.ngrok-free.app" // Default local backend
-	}
-
-	frontendURL := os.Getenv("FRONTEND_URL")
-	if frontendURL == "" {
-		frontendURL = "http://127.0.0.1:3000" // Default local frontend
-	}
-
 	reqBody := TransactionRequest{
-		Method:        method,
-		MerchantRef:   merchantRef,
-		Amount:        amount,
-		CustomerName:  customerName,
-		CustomerEmail: customerEmail,
-		CustomerPhone: customerPhone,
+		Method:        req.Method,
+		MerchantRef:   req.MerchantRef,
+		Amount:        req.Amount,
+		CustomerName:  req.CustomerName,
+		CustomerEmail: req.CustomerEmail,
+		CustomerPhone: req.CustomerPhone,
 		OrderItems: []OrderItem{
 			{
-				Name:     itemName,
-				Price:    amount,
+				Name:     req.ItemName,
+				Price:    req.Amount,
 				Quantity: 1,
 			},
 		},
-		Callback:    baseURL + "/tripay/callback",
-		ReturnUrl:   returnUrl,
-		ExpiredTime: time.Now().Add(24 * time.Hour).Unix(),
+		Callback:    s.CallbackURL,
+		ReturnUrl:   req.ReturnURL,
+		ExpiredTime: req.ExpiredAt.Unix(),
 		Signature:   signature,
 	}
 
 	jsonBody, _ := json.Marshal(reqBody)
 
 	// 3. Send Request
-	req, err := http.NewRequest("POST", s.BaseURL+"/transaction/create", bytes.NewBuffer(jsonBody))
+	httpReq, err := http.NewRequest("POST", s.BaseURL+"/transaction/create", bytes.NewBuffer(jsonBody))
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("Authorization", "Bearer "+s.APIKey)
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := s.Client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	bodyBytes, _ := io.ReadAll(resp.Body)
+	httpReq.Header.Set("Authorization", "Bearer "+s.APIKey)
+	httpReq.Header.Set("Content-Type", "application/json")
 
 	var apiResp TransactionResponse
-	if err := json.Unmarshal(bodyBytes, &apiResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %v", err)
+	if err := s.do(httpReq, &apiResp); err != nil {
+		return nil, err
 	}
 
 	if !apiResp.Success {
 		return nil, fmt.Errorf("tripay error: %s", apiResp.Message)
 	}
 
-	return &apiResp, nil
+	return &gateway.CreatedTransaction{
+		Reference:   apiResp.Data.Reference,
+		MerchantRef: apiResp.Data.MerchantRef,
+		CheckoutURL: apiResp.Data.CheckoutURL,
+		Amount:      apiResp.Data.Amount,
+	}, nil
 }
 
 type PaymentChannel struct {
@@ -173,6 +145,7 @@ type PaymentChannel struct {
 		Percent interface{} `json:"percent"`
 	} `json:"total_fee"`
 	IconURL string `json:"icon_url"`
+	Active  bool   `json:"active"`
 }
 
 type ChannelResponse struct {
@@ -181,7 +154,7 @@ type ChannelResponse struct {
 	Data    []PaymentChannel `json:"data"`
 }
 
-func (s *TripayService) GetPaymentChannels() ([]PaymentChannel, error) {
+func (s *TripayService) GetPaymentChannels() ([]gateway.PaymentChannel, error) {
 	req, err := http.NewRequest("GET", s.BaseURL+"/merchant/payment-channel", nil)
 	if err != nil {
 		return nil, err
@@ -189,37 +162,120 @@ func (s *TripayService) GetPaymentChannels() ([]PaymentChannel, error) {
 
 	req.Header.Set("Authorization", "Bearer "+s.APIKey)
 
-	resp, err := s.Client.Do(req)
+	var apiResp ChannelResponse
+	if err := s.do(req, &apiResp); err != nil {
+		return nil, err
+	}
+
+	if !apiResp.Success {
+		return nil, fmt.Errorf("tripay error: %s", apiResp.Message)
+	}
+
+	channels := make([]gateway.PaymentChannel, 0, len(apiResp.Data))
+	for _, ch := range apiResp.Data {
+		channels = append(channels, gateway.PaymentChannel{
+			Group:   ch.Group,
+			Code:    ch.Code,
+			Name:    ch.Name,
+			Type:    ch.Type,
+			Fee:     gateway.ChannelFee{Flat: toFloat(ch.Fee.Flat), Percent: toFloat(ch.Fee.Percent)},
+			IconURL: ch.IconURL,
+			Active:  ch.Active,
+		})
+	}
+	return channels, nil
+}
+
+type TransactionDetailResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+	Data    struct {
+		Reference      string `json:"reference"`
+		MerchantRef    string `json:"merchant_ref"`
+		PaymentMethod  string `json:"payment_method"` // channel code
+		PaymentName    string `json:"payment_name"`
+		Amount         int64  `json:"amount"`
+		FeeMerchant    int64  `json:"fee_merchant"`
+		FeeCustomer    int64  `json:"fee_customer"`
+		TotalFee       int64  `json:"total_fee"`
+		AmountReceived int64  `json:"amount_received"`
+		IsClosed       int    `json:"is_closed_payment"`
+		Status         string `json:"status"`
+		PaidAt         *int64 `json:"paid_at"`
+		Note           string `json:"note"`
+	} `json:"data"`
+}
+
+// GetTransactionStatus reads a transaction through the detail endpoint and maps it to the callback shape
+func (s *TripayService) GetTransactionStatus(reference string) (*gateway.CallbackPayload, error) {
+	req, err := http.NewRequest("GET", s.BaseURL+"/transaction/detail?reference="+url.QueryEscape(reference), nil)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	bodyBytes, _ := io.ReadAll(resp.Body)
+	req.Header.Set("Authorization", "Bearer "+s.APIKey)
 
-	var apiResp ChannelResponse
-	if err := json.Unmarshal(bodyBytes, &apiResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %v", err)
+	var apiResp TransactionDetailResponse
+	if err := s.do(req, &apiResp); err != nil {
+		return nil, err
 	}
 
 	if !apiResp.Success {
 		return nil, fmt.Errorf("tripay error: %s", apiResp.Message)
 	}
 
-	return apiResp.Data, nil
+	d := apiResp.Data
+	payload := &gateway.CallbackPayload{
+		Reference:         d.Reference,
+		MerchantRef:       d.MerchantRef,
+		PaymentMethod:     d.PaymentName,
+		PaymentMethodCode: d.PaymentMethod,
+		TotalAmount:       d.Amount,
+		FeeMerchant:       d.FeeMerchant,
+		FeeCustomer:       d.FeeCustomer,
+		TotalFee:          d.TotalFee,
+		AmountReceived:    d.AmountReceived,
+		IsClosedPayment:   d.IsClosed,
+		Status:            d.Status,
+		Note:              d.Note,
+	}
+	if d.PaidAt != nil {
+		payload.PaidAt = *d.PaidAt
+	}
+	return payload, nil
 }
 
-func (s *TripayService) generateSignature(data string) string {
-	h := hmac.New(sha256.New, []byte(s.PrivateKey))
-	h.Write([]byte(data))
-	return hex.EncodeToString(h.Sum(nil))
+// ValidateCallback checks the X-Callback-Signature header.
+// Tripay Callback Signature: HMAC-SHA256( JSON_BODY, private_key )
+func (s *TripayService) ValidateCallback(signature string, body []byte) bool {
+	return gateway.VerifySignature(s.PrivateKey, signature, body)
+}
+
+func (s *TripayService) do(req *http.Request, out interface{}) error {
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, _ := io.ReadAll(resp.Body)
+	if err := json.Unmarshal(bodyBytes, out); err != nil {
+		return fmt.Errorf("failed to parse response: %v", err)
+	}
+	return nil
 }
 
-func (s *TripayService) ValidateSignature(incomingSig, jsonBody string) bool {
-	// Validating callback signature
-	// Tripay Callback Signature: HMAC-SHA256( JSON_BODY, private_key )
-	h := hmac.New(sha256.New, []byte(s.PrivateKey))
-	h.Write([]byte(jsonBody))
-	calculated := hex.EncodeToString(h.Sum(nil))
-	return calculated == incomingSig
+// Helper to safely parse interface{} (Tripay sends numbers or numeric strings) to float64
+func toFloat(v interface{}) float64 {
+	switch val := v.(type) {
+	case float64:
+		return val
+	case int:
+		return float64(val)
+	case string:
+		if f, err := strconv.ParseFloat(val, 64); err == nil {
+			return f
+		}
+	}
+	return 0
 }
@@ -0,0 +1,176 @@
+package voucher
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInvalidVoucher wraps every reason a voucher can't be used on an order
+var ErrInvalidVoucher = errors.New("voucher cannot be used")
+
+type VoucherService struct {
+	DB *gorm.DB
+}
+
+func NewVoucherService(db *gorm.DB) *VoucherService {
+	return &VoucherService{DB: db}
+}
+
+// Application is a voucher validated against an order
+type Application struct {
+	Voucher  *models.Voucher
+	Discount int64
+}
+
+func invalid(reason string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidVoucher, reason)
+}
+
+// NormalizeCode trims and upper-cases a voucher code
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Validate checks that code can be used by clientID on offer and computes the discount.
+// Usage limits are checked again under lock by Redeem.
+func (s *VoucherService) Validate(code string, offer *models.JobOffer, clientID uuid.UUID) (*Application, error) {
+	var v models.Voucher
+	if err := s.DB.Where("code = ?", NormalizeCode(code)).First(&v).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, invalid("voucher not found")
+		}
+		return nil, err
+	}
+	return s.check(s.DB, &v, offer, clientID)
+}
+
+func (s *VoucherService) check(db *gorm.DB, v *models.Voucher, offer *models.JobOffer, clientID uuid.UUID) (*Application, error) {
+	now := time.Now()
+	if !v.IsActive {
+		return nil, invalid("voucher is not active")
+	}
+	if v.StartsAt != nil && now.Before(*v.StartsAt) {
+		return nil, invalid("voucher is not valid yet")
+	}
+	if v.ExpiresAt != nil && !now.Before(*v.ExpiresAt) {
+		return nil, invalid("voucher has expired")
+	}
+	if offer.CheckoutAmount() < v.MinSpend {
+		return nil, invalid(fmt.Sprintf("minimum spend is %d", v.MinSpend))
+	}
+	if v.FreelancerID != nil && *v.FreelancerID != offer.FreelancerID {
+		return nil, invalid("voucher is not valid for this freelancer")
+	}
+	if v.ProductID != nil && (offer.ProductID == nil || *offer.ProductID != *v.ProductID) {
+		return nil, invalid("voucher is not valid for this service")
+	}
+	if v.Category != "" {
+		var product models.Product
+		if offer.ProductID == nil || db.Select("category").First(&product, "id = ?", *offer.ProductID).Error != nil ||
+			!strings.EqualFold(product.Category, v.Category) {
+			return nil, invalid("voucher is not valid for this category")
+		}
+	}
+
+	if err := s.checkLimits(db, v, clientID); err != nil {
+		return nil, err
+	}
+
+	discount := v.DiscountFor(offer.CheckoutAmount())
+	// A freelancer can't fund more than they earn from the order
+	if v.FundedBy == models.VoucherFundedByFreelancer && discount > offer.NetAmount {
+		discount = offer.NetAmount
+	}
+	if discount <= 0 {
+		return nil, invalid("voucher gives no discount on this order")
+	}
+	return &Application{Voucher: v, Discount: discount}, nil
+}
+
+func (s *VoucherService) checkLimits(db *gorm.DB, v *models.Voucher, clientID uuid.UUID) error {
+	active := []models.VoucherRedemptionStatus{models.VoucherRedemptionReserved, models.VoucherRedemptionUsed}
+
+	if v.UsageLimit > 0 {
+		var used int64
+		if err := db.Model(&models.VoucherRedemption{}).
+			Where("voucher_id = ? AND status IN ?", v.ID, active).Count(&used).Error; err != nil {
+			return err
+		}
+		if used >= int64(v.UsageLimit) {
+			return invalid("voucher quota has run out")
+		}
+	}
+	if v.PerUserLimit > 0 {
+		var used int64
+		if err := db.Model(&models.VoucherRedemption{}).
+			Where("voucher_id = ? AND user_id = ? AND status IN ?", v.ID, clientID, active).Count(&used).Error; err != nil {
+			return err
+		}
+		if used >= int64(v.PerUserLimit) {
+			return invalid("you have already used this voucher")
+		}
+	}
+	return nil
+}
+
+// Redeem re-validates the voucher with its row locked (SELECT ... FOR UPDATE), reserves it for
+// the payment attempt and records it on trx. Concurrent redemptions of the same voucher wait for
+// the lock, so the usage counts they check include the reservations committed before them.
+// Call it before trx is created.
+func (s *VoucherService) Redeem(tx *gorm.DB, app *Application, offer *models.JobOffer, trx *models.Transaction) error {
+	var v models.Voucher
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&v, "id = ?", app.Voucher.ID).Error; err != nil {
+		return err
+	}
+	checked, err := s.check(tx, &v, offer, offer.ClientID)
+	if err != nil {
+		return err
+	}
+	if checked.Discount != app.Discount {
+		return invalid("voucher changed, please try again")
+	}
+
+	if trx.ID == uuid.Nil {
+		trx.ID = uuid.New()
+	}
+	trx.VoucherID = &v.ID
+	trx.VoucherCode = v.Code
+	trx.DiscountAmount = app.Discount
+	trx.DiscountFundedBy = v.FundedBy
+
+	return tx.Create(&models.VoucherRedemption{
+		ID:            uuid.New(),
+		VoucherID:     v.ID,
+		UserID:        offer.ClientID,
+		JobOfferID:    offer.ID,
+		TransactionID: trx.ID,
+		Discount:      app.Discount,
+		Status:        models.VoucherRedemptionReserved,
+	}).Error
+}
+
+// Confirm marks the voucher of a paid attempt as used
+func (s *VoucherService) Confirm(tx *gorm.DB, trx *models.Transaction) error {
+	return s.setStatus(tx, trx, models.VoucherRedemptionUsed)
+}
+
+// Release gives the voucher back when its payment attempt did not go through
+func (s *VoucherService) Release(tx *gorm.DB, trx *models.Transaction) error {
+	return s.setStatus(tx, trx, models.VoucherRedemptionReleased)
+}
+
+func (s *VoucherService) setStatus(tx *gorm.DB, trx *models.Transaction, status models.VoucherRedemptionStatus) error {
+	if trx.VoucherID == nil {
+		return nil
+	}
+	return tx.Model(&models.VoucherRedemption{}).
+		Where("transaction_id = ? AND status <> ?", trx.ID, status).
+		Update("status", status).Error
+}
@@ -7,8 +7,12 @@ import (
 	"github.com/Windi-Fikriyansyah/platfrom_be_joki/internal/models"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrInsufficientBalance is returned when a debit would make a wallet balance negative.
+var ErrInsufficientBalance = errors.New("insufficient balance")
+
 type WalletService struct {
 	DB *gorm.DB
 }
@@ -40,6 +44,7 @@ func (s *WalletService) CreditFreelancer(tx *gorm.DB, userID uuid.UUID, amount i
 	ledger := models.WalletTransaction{
 		ID:          uuid.New(),
 		UserID:      userID,
+		Wallet:      models.WalletFreelancer,
 		Amount:      amount,
 		Type:        models.WalletTrxCredit,
 		Description: description,
@@ -53,9 +58,98 @@ func (s *WalletService) CreditFreelancer(tx *gorm.DB, userID uuid.UUID, amount i
 	return nil
 }
 
+// HoldFreelancerPending adds completed-order earnings to freelancer's pending balance.
+// No wallet transaction is written yet: that happens when the earnings clear.
+// This should be called within a DB transaction.
+func (s *WalletService) HoldFreelancerPending(tx *gorm.DB, userID uuid.UUID, amount int64) error {
+	if amount <= 0 {
+		return errors.New("amount to hold must be greater than zero")
+	}
+
+	result := tx.Model(&models.FreelancerProfile{}).
+		Where("user_id = ?", userID).
+		Update("pending_balance", gorm.Expr("pending_balance + ?", amount))
+
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("freelancer profile not found for user %s", userID)
+	}
+	return nil
+}
+
+// ReverseFreelancerPending takes earnings back out of freelancer's pending balance before they
+// clear (e.g. the order payment was charged back). Like HoldFreelancerPending, no wallet
+// transaction is written. This should be called within a DB transaction.
+func (s *WalletService) ReverseFreelancerPending(tx *gorm.DB, userID uuid.UUID, amount int64) error {
+	if amount <= 0 {
+		return errors.New("amount to reverse must be greater than zero")
+	}
+
+	result := tx.Model(&models.FreelancerProfile{}).
+		Where("user_id = ? AND pending_balance >= ?", userID, amount).
+		Update("pending_balance", gorm.Expr("pending_balance - ?", amount))
+
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("pending balance of freelancer %s does not cover %d", userID, amount)
+	}
+	return nil
+}
+
+// ClearFreelancerPending moves cleared earnings from freelancer's pending balance to the
+// withdrawable balance and creates the ledger entry.
+// This should be called within a DB transaction.
+func (s *WalletService) ClearFreelancerPending(tx *gorm.DB, userID uuid.UUID, amount int64, referenceID uuid.UUID, description string) error {
+	if amount <= 0 {
+		return errors.New("amount to clear must be greater than zero")
+	}
+
+	// 1. Move the amount in one guarded update so the pending balance never goes negative
+	result := tx.Model(&models.FreelancerProfile{}).
+		Where("user_id = ? AND pending_balance >= ?", userID, amount).
+		Updates(map[string]interface{}{
+			"pending_balance": gorm.Expr("pending_balance - ?", amount),
+			"balance":         gorm.Expr("balance + ?", amount),
+		})
+
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("pending balance of freelancer %s does not cover %d", userID, amount)
+	}
+
+	// 2. Create WalletTransaction (Ledger)
+	ledger := models.WalletTransaction{
+		ID:          uuid.New(),
+		UserID:      userID,
+		Wallet:      models.WalletFreelancer,
+		Amount:      amount,
+		Type:        models.WalletTrxCredit,
+		Description: description,
+		ReferenceID: &referenceID,
+	}
+
+	return tx.Create(&ledger).Error
+}
+
 // CreditClient adds funds to client's balance (e.g., for refunds) and creates a ledger entry.
 // This should be called within a DB transaction.
 func (s *WalletService) CreditClient(tx *gorm.DB, userID uuid.UUID, amount int64, referenceID uuid.UUID, description string) error {
+	return s.creditClient(tx, userID, amount, referenceID, description, models.WalletTrxRefund) // Use refund type for client credits
+}
+
+// TopUpClient adds a paid top-up to the client's balance.
+// This should be called within a DB transaction.
+func (s *WalletService) TopUpClient(tx *gorm.DB, userID uuid.UUID, amount int64, referenceID uuid.UUID, description string) error {
+	return s.creditClient(tx, userID, amount, referenceID, description, models.WalletTrxTopUp)
+}
+
+func (s *WalletService) creditClient(tx *gorm.DB, userID uuid.UUID, amount int64, referenceID uuid.UUID, description string, trxType models.WalletTrxType) error {
 	if amount <= 0 {
 		return errors.New("amount to credit must be greater than zero")
 	}
@@ -76,8 +170,9 @@ func (s *WalletService) CreditClient(tx *gorm.DB, userID uuid.UUID, amount int64
 	ledger := models.WalletTransaction{
 		ID:          uuid.New(),
 		UserID:      userID,
+		Wallet:      models.WalletClient,
 		Amount:      amount,
-		Type:        models.WalletTrxRefund, // Use refund type for client credits
+		Type:        trxType,
 		Description: description,
 		ReferenceID: &referenceID,
 	}
@@ -96,15 +191,15 @@ func (s *WalletService) DebitClient(tx *gorm.DB, userID uuid.UUID, amount int64,
 		return errors.New("amount to debit must be greater than zero")
 	}
 
-	// 1. Check current balance first to ensure it doesn't go negative (if needed)
-	// For additional security, we can use a check constraint in DB, but here we do it in code too.
+	// 1. Lock the user row (SELECT ... FOR UPDATE) so concurrent debits are serialized, then make
+	// sure the balance does not go negative
 	var user models.User
-	if err := tx.Set("gorm:query_option", "FOR UPDATE").First(&user, "id = ?", userID).Error; err != nil {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", userID).Error; err != nil {
 		return err
 	}
 
 	if user.Balance < amount {
-		return errors.New("insufficient balance")
+		return ErrInsufficientBalance
 	}
 
 	// 2. Update User balance atomically
@@ -123,6 +218,7 @@ func (s *WalletService) DebitClient(tx *gorm.DB, userID uuid.UUID, amount int64,
 	ledger := models.WalletTransaction{
 		ID:          uuid.New(),
 		UserID:      userID,
+		Wallet:      models.WalletClient,
 		Amount:      amount,
 		Type:        models.WalletTrxDebit,
 		Description: description,
@@ -135,3 +231,87 @@ func (s *WalletService) DebitClient(tx *gorm.DB, userID uuid.UUID, amount int64,
 
 	return nil
 }
+
+// DebitFreelancer deducts funds from freelancer's balance (e.g., to hold a withdrawal) and creates a ledger entry.
+// The balance can never go negative: the update only succeeds when enough balance is left.
+// This should be called within a DB transaction.
+func (s *WalletService) DebitFreelancer(tx *gorm.DB, userID uuid.UUID, amount int64, referenceID uuid.UUID, description string) error {
+	if amount <= 0 {
+		return errors.New("amount to debit must be greater than zero")
+	}
+
+	// 1. Lock the profile row (SELECT ... FOR UPDATE) so concurrent withdrawals are serialized
+	var profile models.FreelancerProfile
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&profile, "user_id = ?", userID).Error; err != nil {
+		return err
+	}
+
+	if profile.Balance < amount {
+		return ErrInsufficientBalance
+	}
+
+	// 2. Update FreelancerProfile balance atomically (guarded so it never goes below zero)
+	result := tx.Model(&models.FreelancerProfile{}).
+		Where("user_id = ? AND balance >= ?", userID, amount).
+		Update("balance", gorm.Expr("balance - ?", amount))
+
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInsufficientBalance
+	}
+
+	// 3. Create WalletTransaction (Ledger)
+	ledger := models.WalletTransaction{
+		ID:          uuid.New(),
+		UserID:      userID,
+		Wallet:      models.WalletFreelancer,
+		Amount:      amount,
+		Type:        models.WalletTrxDebit,
+		Description: description,
+		ReferenceID: &referenceID,
+	}
+
+	if err := tx.Create(&ledger).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RefundFreelancer returns previously debited funds (e.g., a rejected or failed withdrawal)
+// to freelancer's balance and creates a ledger entry.
+// This should be called within a DB transaction.
+func (s *WalletService) RefundFreelancer(tx *gorm.DB, userID uuid.UUID, amount int64, referenceID uuid.UUID, description string) error {
+	if amount <= 0 {
+		return errors.New("amount to refund must be greater than zero")
+	}
+
+	result := tx.Model(&models.FreelancerProfile{}).
+		Where("user_id = ?", userID).
+		Update("balance", gorm.Expr("balance + ?", amount))
+
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("freelancer profile not found for user %s", userID)
+	}
+
+	ledger := models.WalletTransaction{
+		ID:          uuid.New(),
+		UserID:      userID,
+		Wallet:      models.WalletFreelancer,
+		Amount:      amount,
+		Type:        models.WalletTrxRefund,
+		Description: description,
+		ReferenceID: &referenceID,
+	}
+
+	if err := tx.Create(&ledger).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
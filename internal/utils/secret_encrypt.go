@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// EncryptString encrypts sensitive text (e.g. bank account numbers) with AES-GCM.
+// The nonce is prepended to the ciphertext and the result is base64 encoded.
+func EncryptString(plain string, key string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to read random nonce: %w", err)
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(plain), nil)
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptString reverses EncryptString.
+func DecryptString(enc string, key string) (string, error) {
+	if enc == "" {
+		return "", fmt.Errorf("empty encrypted value")
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(enc)
+	if err != nil {
+		return "", fmt.Errorf("decode base64 failed: %w", err)
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", fmt.Errorf("ciphertext too short: len=%d", len(data))
+	}
+
+	nonce, body := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, body, nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypt failed: %w", err)
+	}
+
+	return string(plain), nil
+}
+
+func newGCM(key string) (cipher.AEAD, error) {
+	k := []byte(key)
+	if len(k) != 16 && len(k) != 24 && len(k) != 32 {
+		return nil, fmt.Errorf("invalid key length: %d (must be 16/24/32)", len(k))
+	}
+
+	block, err := aes.NewCipher(k)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// MaskAccountNumber keeps only the last 4 digits visible, e.g. "1234567890" -> "******7890".
+func MaskAccountNumber(number string) string {
+	if len(number) <= 4 {
+		return strings.Repeat("*", len(number))
+	}
+	return strings.Repeat("*", len(number)-4) + number[len(number)-4:]
+}
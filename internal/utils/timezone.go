@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"time"
+	_ "time/tzdata" // Asia/Jakarta must resolve on hosts without a zoneinfo database
+)
+
+// Jakarta is the platform's business time zone (WIB). Order dates are calendar days in it.
+var Jakarta = loadJakarta()
+
+func loadJakarta() *time.Location {
+	loc, err := time.LoadLocation("Asia/Jakarta")
+	if err != nil {
+		return time.FixedZone("WIB", 7*60*60)
+	}
+	return loc
+}
+
+// ParseDate parses a YYYY-MM-DD date as midnight in Jakarta
+func ParseDate(s string) (time.Time, error) {
+	return time.ParseInLocation("2006-01-02", s, Jakarta)
+}
+
+// FormatDate formats t as a YYYY-MM-DD date in Jakarta
+func FormatDate(t time.Time) string {
+	return t.In(Jakarta).Format("2006-01-02")
+}
+
+// StartOfDay returns midnight in Jakarta of the day t falls on there
+func StartOfDay(t time.Time) time.Time {
+	t = t.In(Jakarta)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, Jakarta)
+}
+
+// Deadline is the moment a due date has passed: the end of that day in Jakarta
+func Deadline(date time.Time) time.Time {
+	return StartOfDay(date).AddDate(0, 0, 1)
+}